@@ -5,34 +5,125 @@ const HelpText = `Usage: ec2ssh [options] [user@]destination [command [args...]]
        ec2scp [options] source target
        ec2sftp [options] [user@]destination[:path]
        ec2ssm [options] destination [command [args...]]
-       ec2list [options]
+       ec2list [options] [query]
+       ec2ssh --proxy [options] user@host:port
+       ec2ssh --export-ssh-config [options]
+       ec2ssh --inventory [options] --list | --host <name>
+       ec2ssh --known-hosts prune [options] [file...]
+       ec2ssh --forward [laddr:]lport:rhost:rport [options] destination
+       ec2ssh --eice-listen [laddr:]lport:rport [options] destination
+       ec2ssh --socks [laddr:]lport [options] destination
+
+In a terminal, omitting the destination (ec2ssh/ec2sftp/ec2ssm) or matching
+several instances opens an interactive picker over running instances.
+Without user@ or -l, the login is that of the instance's AMI (ubuntu, admin,
+ec2-user, ...), shown with --debug.
+Instance tags ec2ssh:user, ec2ssh:port, ec2ssh:transport, ec2ssh:address-type,
+ec2ssh:eice-id and ec2ssh:key-type set what the command line leaves open.
 
 Intents (first argument or binary name ec2ssh/ec2scp/ec2sftp/ec2ssm/ec2list):
   --ssh (default), --scp, --sftp, --ssm, --list
+  --proxy                 Connect stdin/stdout to the instance's SSH port, for
+                          use as ProxyCommand ec2ssh --proxy %r@%h:%p in
+                          ~/.ssh/config; pushes the key of -i (default: first
+                          of ~/.ssh/id_rsa, id_ecdsa, id_ed25519, ...)
+  --export-ssh-config     Print a Host block per instance that connects through
+                          ec2ssh --proxy, for Include in ~/.ssh/config; takes
+                          the AWS options, --filter, -l, -i and transport flags
+  --inventory             Ansible dynamic inventory (JSON) of the same hosts,
+                          grouped by tag, AZ, VPC and instance type; takes
+                          the options of --export-ssh-config
+  --known-hosts prune     Remove known_hosts entries of terminated instances
+                          (default files: ~/.ssh/known_hosts and
+                          ~/.ssh/ec2ssh_known_hosts; all regions unless
+                          --region/--regions)
+  --forward <spec>        Forward [laddr:]lport:rhost:rport through the instance
+                          with SSM, without SSH, until interrupted; repeatable
+                          (laddr default: localhost, rhost as seen from the
+                          instance)
+  --eice-listen <spec>    Tunnel connections to [laddr:]lport to the instance's
+                          rport through EICE, a fresh tunnel each, until
+                          interrupted; takes --eice-id, --address-type and
+                          --max-connections <n> (default: 10)
+  --socks <spec>          SOCKS5 proxy on [laddr:]lport whose connections go
+                          to VPC-private IPs through EICE (default) or, with
+                          --use-ssm, to any host the instance reaches; takes
+                          --eice-id and --max-connections <n> (default: 10)
 
 AWS Options:
   --region <region>       AWS region (default: SDK config)
+  --regions <list>        Search these regions concurrently; comma-separated
+                          names or "all" for every enabled region
   --profile <profile>     AWS profile (default: SDK config)
+  --profiles <glob>       Search configured profiles matching the glob
+                          concurrently; the owning profile is used to connect
+  --all-profiles          Search all configured profiles (same as --profiles '*')
 
 Connection Options:
   --use-eice              Use EC2 Instance Connect Endpoint (default: false)
   --use-ssm               Use SSM Session Manager for tunneling (default: false)
   --eice-id <id>          EICE ID (implies --use-eice, default: autodetect)
+  --transport <t>         Try transports in order, use the first that works
+                          Values: auto (= direct,eice,ssm) or a comma-separated
+                          order of direct|eice|ssm
   --destination-type <t>  How to interpret destination (default: auto)
-                          Values: id|private_ip|public_ip|ipv6|private_dns|name_tag
+                          Values: id|private_ip|public_ip|ipv6|private_dns|name_tag|tags
   --address-type <type>   Address for connection (default: auto)
                           Values: private|public|ipv6
+  --select <s>            Pick one of several matching instances (default: picker
+                          in a terminal, otherwise fail and list them)
+                          Values: newest|oldest|N (as destination#N)
   --no-send-keys          Skip EC2 Instance Connect key push (default: false)
+  --agent <mode>          Use ssh-agent instead of a key file (not with -i)
+                          Values: add (ephemeral key added for 15m, reused
+                          while valid) | use (first ed25519/RSA identity)
+  --key-type <type>       Type of the ephemeral key (default: ec2ssh:key-type
+                          tag, then $EC2SSH_KEY_TYPE, then ed25519)
+                          Values: ed25519|rsa[:2048|4096]|ecdsa[:256|384|521]
+                          (rsa defaults to 2048; Instance Connect rejects ecdsa)
+  --ca-key <key>          Sign the key with this SSH CA for the login instead of
+                          pushing it (5m certificate, not with --proxy)
+                          Values: path | agent | agent:<fingerprint|comment>
+  --key-delivery <d>      How the public key reaches the instance (default: auto,
+                          Instance Connect, SSM RunCommand where unavailable)
+                          Values: auto|instance-connect|ssm
+  --host-keys <source>    Check host keys fetched from AWS and kept in
+                          ~/.ssh/ec2ssh_known_hosts, not trust on first use
+                          Values: auto (console, then ssm)|console|ssm
 
 List Options:
+  query                   Show instances whose name, ID or IP contains query
+                          (case-insensitive), or matches /regex/
+  --filter <name=value>   EC2 API filter, e.g. tag:Env=prod or
+                          instance-type=t3.micro,t3.small (repeatable)
+  --state <states>        Instance states, e.g. running,stopped (default: all)
+  --page-size <n>         Instances per DescribeInstances call, 5-1000
+                          (default: 1000)
   --list-columns <cols>   Columns to display
                           Default: ID,NAME,STATE,PRIVATE-IP,PUBLIC-IP
-                          Available: ID,NAME,STATE,TYPE,AZ,PRIVATE-IP,
-                                     PUBLIC-IP,IPV6,PRIVATE-DNS,PUBLIC-DNS
+                          Default with --regions adds REGION, with
+                          --profiles/--all-profiles adds PROFILE,ACCOUNT
+                          Available: ID,NAME,STATE,TYPE,AZ,REGION,PROFILE,
+                                     ACCOUNT,PRIVATE-IP,PUBLIC-IP,IPV6,
+                                     PRIVATE-DNS,PUBLIC-DNS,VPC,SUBNET,
+                                     LAUNCH-TIME,UPTIME,AMI,KEY-NAME,
+                                     PLATFORM,ARCH,IAM-PROFILE,LIFECYCLE,
+                                     SECURITY-GROUPS,TAG:<key>,
+                                     CONNECT,SSM-PING,EICE-ID
+  --sort [-]<column>      Sort by column, descending with "-" (default: API order)
+  --group-by <column>     Count instances per column value
+  --output <format>       Output format (default: table)
+                          Values: table|json|jsonl|csv|tsv|yaml
+  --no-headers            Omit the header line (table, csv, tsv)
 
 SSM Command Options:
   --timeout <duration>    Timeout for command completion (default: 60s)
 
+Known Hosts Options:
+  --dry-run               Report stale entries without removing them
+  --remove-missing        Also remove instances not found (default with
+                          --all-profiles in all regions)
+
 Other:
   --help, --version       Show help or version
   --debug                 Enable debug logging (default: false)
@@ -44,9 +135,32 @@ Examples:
   ec2scp -r --region us-west-2 ./logs admin@10.0.1.5:/backup/
   ec2sftp -P 2222 user@app01:/var/log
   ec2ssm my-bastion-host
+  ec2ssh --transport auto web-server
+  ec2ssh --agent add -A bastion
+  ec2ssh --key-type rsa:4096 legacy-host
+  ec2scp --ca-key agent:company-ca ./app.tar admin@hardened-host:/tmp/
+  ec2ssh --use-ssm --key-delivery ssm admin@legacy-host
+  ec2ssh --host-keys auto web-server
+  ec2ssh --proxy --transport auto -i ~/.ssh/id_ed25519 %r@%h:%p
+  ec2ssh --export-ssh-config --all-profiles --regions all > ~/.ssh/ec2.conf
+  ec2ssh --inventory --transport auto --list
+  ec2ssh --known-hosts prune --all-profiles --dry-run
+  ec2ssh --select newest web-server
+  ec2ssh ec2-user@web-server#2
+  ec2ssh ec2-user@Env=prod,Role=api
   ec2ssm i-0123456789abcdef0 whoami
   ec2ssm --timeout 5m i-xxx -- ./long-running-script.sh
+  ec2ssh --regions us-east-1,eu-west-1 web-server
   ec2list --profile prod --list-columns ID,NAME,STATE
+  ec2list --regions all
+  ec2list --state running --filter tag:Env=prod web
+  ec2list '/^api-[0-9]+$/'
+  ec2list --output jsonl --list-columns ID,NAME,PRIVATE-IP
+  ec2list --list-columns ID,NAME,TAG:Env,UPTIME --sort=-UPTIME
+  ec2list --group-by TYPE
+  ec2list --state running --list-columns ID,NAME,CONNECT,SSM-PING,EICE-ID
+  ec2ssh --profiles 'prod-*' web-server
+  ec2list --all-profiles
 
 All standard ssh/scp/sftp options are passed through to the underlying command.
 `
@@ -76,6 +76,32 @@ func (r *Runner) Run() int {
 		}
 	case intent.IntentList:
 		err = app.RunList(args)
+	case intent.IntentExportSSHConfig:
+		err = app.RunExportSSHConfig(args)
+	case intent.IntentInventory:
+		err = app.RunInventory(args)
+	case intent.IntentKnownHosts:
+		err = app.RunKnownHosts(args)
+	case intent.IntentForward:
+		var session *app.ForwardSession
+		if session, err = app.NewForwardSession(args); err == nil {
+			err = session.Run()
+		}
+	case intent.IntentEICEListen:
+		var session *app.EICEListenSession
+		if session, err = app.NewEICEListenSession(args); err == nil {
+			err = session.Run()
+		}
+	case intent.IntentSOCKS:
+		var session *app.SOCKSSession
+		if session, err = app.NewSOCKSSession(args); err == nil {
+			err = session.Run()
+		}
+	case intent.IntentProxy:
+		var session *app.ProxySession
+		if session, err = app.NewProxySession(args); err == nil {
+			err = session.Run()
+		}
 	default:
 		return r.fatalError(fmt.Errorf("unhandled intent: %v", resolvedIntent))
 	}
@@ -104,7 +130,7 @@ func (r *Runner) usage(err error) int {
 	if err != nil {
 		_, _ = fmt.Fprintf(r.Stderr, "ec2ssh: %v\n", err)
 	}
-	_, _ = fmt.Fprint(r.Stderr, HelpText)
+	_, _ = io.WriteString(r.Stderr, HelpText)
 	return 1
 }
 
@@ -108,6 +108,10 @@ func TestRunner_IntentRouting(t *testing.T) {
 			args:        []string{"ec2ssh", "--ssm-tunnel"},
 			errContains: "missing",
 		},
+		"--proxy requires destination": {
+			args:        []string{"ec2ssh", "--proxy"},
+			errContains: "exactly one destination",
+		},
 	}
 
 	for name, tc := range tests {
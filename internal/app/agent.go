@@ -0,0 +1,79 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+)
+
+// agentKeyLifetime is how long ssh-agent keeps an ephemeral key added by --agent add.
+const agentKeyLifetime = 15 * time.Minute
+
+// Package-level hooks for ssh-agent, overridden in tests.
+var (
+	addAgentKey       = ssh.AddAgentKey
+	getAgentPublicKey = ssh.GetAgentPublicKey
+)
+
+// AgentMode selects how --agent uses ssh-agent.
+// Use a pointer to AgentMode where nil means a key file (-i or ephemeral).
+type AgentMode int
+
+const (
+	AgentAdd AgentMode = iota // Add an ephemeral key to the agent
+	AgentUse                  // Push the public key of an existing agent identity
+)
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+func (m *AgentMode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "add":
+		*m = AgentAdd
+	case "use":
+		*m = AgentUse
+	default:
+		return fmt.Errorf("unknown agent mode: %s", text)
+	}
+	return nil
+}
+
+// agentPublicKey returns the public key of the agent identity chosen by --agent.
+func (s *baseSSHSession) agentPublicKey() (string, error) {
+	switch *s.Agent {
+	case AgentAdd:
+		publicKey, err := addAgentKey(s.keyType(), agentKeyLifetime)
+		if err != nil {
+			return "", fmt.Errorf("unable to add ephemeral key to ssh-agent: %w", err)
+		}
+		return publicKey, nil
+	case AgentUse:
+		publicKey, err := getAgentPublicKey()
+		if err != nil {
+			return "", fmt.Errorf("unable to get identity from ssh-agent: %w", err)
+		}
+		return publicKey, nil
+	default:
+		panic(fmt.Sprintf("unexpected AgentMode: %d", int(*s.Agent)))
+	}
+}
+
+// setupAgentKey selects the agent identity and writes its public key to
+// tmpDir. Given as -i with IdentitiesOnly, a public key makes ssh offer just
+// the matching agent key, however many the agent holds.
+func (s *baseSSHSession) setupAgentKey(tmpDir string) error {
+	publicKey, err := s.agentPublicKey()
+	if err != nil {
+		return err
+	}
+
+	publicKeyPath := filepath.Join(tmpDir, "agent.pub")
+	if err := os.WriteFile(publicKeyPath, []byte(publicKey), 0o600); err != nil {
+		return fmt.Errorf("unable to write agent public key: %w", err)
+	}
+
+	s.privateKeyPath, s.publicKey = publicKeyPath, publicKey
+	return nil
+}
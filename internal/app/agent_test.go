@@ -0,0 +1,148 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentMode_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    AgentMode
+		wantErr bool
+	}{
+		"add":     {input: "add", want: AgentAdd},
+		"use":     {input: "use", want: AgentUse},
+		"unknown": {input: "forward", wantErr: true},
+		"empty":   {input: "", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var mode AgentMode
+			err := mode.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown agent mode")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, mode)
+		})
+	}
+}
+
+// setupAgentMocks replaces the ssh-agent hooks and fails the test if a key file would be generated.
+func setupAgentMocks(t *testing.T, publicKey string, err error) {
+	t.Helper()
+
+	origAddAgentKey := addAgentKey
+	origGetAgentPublicKey := getAgentPublicKey
+	origGenerateKeypair := generateKeypair
+	t.Cleanup(func() {
+		addAgentKey = origAddAgentKey
+		getAgentPublicKey = origGetAgentPublicKey
+		generateKeypair = origGenerateKeypair
+	})
+
+	addAgentKey = func(keyType ssh.KeyType, lifetime time.Duration) (string, error) {
+		assert.Equal(t, agentKeyLifetime, lifetime)
+		return "added " + publicKey, err
+	}
+	getAgentPublicKey = func() (string, error) {
+		return "existing " + publicKey, err
+	}
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
+		t.Fatal("unexpected key file generation")
+		return "", "", nil
+	}
+}
+
+func TestSSHSession_Run_Agent(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	tests := map[string]struct {
+		mode          string
+		wantPublicKey string
+	}{
+		"add": {mode: "add", wantPublicKey: "added ssh-ed25519 AAAA agent\n"},
+		"use": {mode: "use", wantPublicKey: "existing ssh-ed25519 AAAA agent\n"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var captured commandCapture
+			_, connectMock := setupMocksForRun(t, testInstance, &captured)
+			setupAgentMocks(t, "ssh-ed25519 AAAA agent\n", nil)
+
+			var identity string
+			executeCommand = func(cmd string, args []string, _ *log.Logger) error {
+				captured.args = args
+				for _, arg := range args {
+					if path, ok := strings.CutPrefix(arg, "-i"); ok {
+						content, err := os.ReadFile(path)
+						require.NoError(t, err)
+						identity = string(content)
+					}
+				}
+				return nil
+			}
+
+			session, err := NewSSHSession([]string{"--agent", tc.mode, "i-1234567890abcdef0"})
+			require.NoError(t, err)
+
+			err = session.Run()
+			require.NoError(t, err)
+
+			assert.Contains(t, captured.args, "-oIdentitiesOnly=yes")
+			assert.Equal(t, tc.wantPublicKey, identity, "-i should be the agent public key")
+			connectMock.AssertCalled(t, "SendSSHPublicKey", mock.Anything, mock.MatchedBy(func(input *ec2instanceconnect.SendSSHPublicKeyInput) bool {
+				return *input.SSHPublicKey == tc.wantPublicKey
+			}))
+		})
+	}
+}
+
+func TestSSHSession_Run_AgentError(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+	setupMocksForRun(t, testInstance, nil)
+	setupAgentMocks(t, "", errors.New("SSH_AUTH_SOCK is not set"))
+
+	session, err := NewSSHSession([]string{"--agent", "use", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to get identity from ssh-agent")
+	assert.Contains(t, err.Error(), "SSH_AUTH_SOCK is not set")
+}
+
+func TestProxySession_LoadPublicKey_Agent(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+	setupAgentMocks(t, "ssh-rsa AAAA agent\n", nil)
+	t.Setenv("HOME", filepath.Join(t.TempDir(), "nonexistent"))
+
+	session, err := NewProxySession([]string{"--agent", "use", "ec2-user@i-123:22"})
+	require.NoError(t, err)
+	session.initLogger()
+
+	require.NoError(t, session.loadPublicKey())
+	assert.Equal(t, "existing ssh-rsa AAAA agent\n", session.publicKey)
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+)
+
+// certValidity is the lifetime of certificates issued with --ca-key, enough
+// to connect; established sessions outlive it.
+const certValidity = 5 * time.Minute
+
+// signUserCert issues certificates for --ca-key, overridden in tests.
+var signUserCert = ssh.SignUserCert
+
+// issueCertificate signs the session's public key with the --ca-key CA for
+// the resolved login, and writes the certificate to tmpDir for CertificateFile.
+// This replaces the Instance Connect key push.
+func (s *baseSSHSession) issueCertificate(tmpDir string) error {
+	certificate, err := signUserCert(s.CAKey, ssh.CertRequest{
+		PublicKey: s.publicKey,
+		Principal: s.login,
+		KeyID:     fmt.Sprintf("ec2ssh %s@%s", s.login, *s.instance.InstanceId),
+		Validity:  certValidity,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to issue SSH certificate: %w", err)
+	}
+
+	certificatePath := filepath.Join(tmpDir, "cert.pub")
+	if err := os.WriteFile(certificatePath, []byte(certificate), 0o600); err != nil {
+		return fmt.Errorf("unable to write SSH certificate: %w", err)
+	}
+
+	s.logger.Printf("issued certificate for %s valid for %s", s.login, certValidity)
+	s.certificatePath = certificatePath
+	return nil
+}
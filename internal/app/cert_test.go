@@ -0,0 +1,147 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// setupCertMock replaces signUserCert, recording the requests it gets.
+func setupCertMock(t *testing.T, err error) *[]ssh.CertRequest {
+	t.Helper()
+
+	origSignUserCert := signUserCert
+	t.Cleanup(func() { signUserCert = origSignUserCert })
+
+	var requests []ssh.CertRequest
+	signUserCert = func(caKey string, request ssh.CertRequest) (string, error) {
+		assert.Equal(t, "/etc/ssh/user_ca", caKey)
+		requests = append(requests, request)
+		return "ssh-ed25519-cert-v01@openssh.com AAAA cert\n", err
+	}
+	return &requests
+}
+
+// certificateArg returns the CertificateFile passed to the command and its content.
+func certificateArg(t *testing.T, args []string) (string, string) {
+	t.Helper()
+
+	for _, arg := range args {
+		if path, ok := strings.CutPrefix(arg, "-oCertificateFile="); ok {
+			content, err := os.ReadFile(path)
+			require.NoError(t, err, "certificate should exist while the command runs")
+			return path, string(content)
+		}
+	}
+	t.Fatal("no -oCertificateFile argument")
+	return "", ""
+}
+
+func TestSession_Run_CAKey(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	tests := map[string]struct {
+		run           func() (string, error)
+		wantCommand   string
+		wantPrincipal string
+	}{
+		"ssh with target login": {
+			run: func() (string, error) {
+				session, err := NewSSHSession([]string{"--ca-key", "/etc/ssh/user_ca", "admin@i-1234567890abcdef0"})
+				if err != nil {
+					return "", err
+				}
+				return "ssh", session.Run()
+			},
+			wantCommand:   "ssh",
+			wantPrincipal: "admin",
+		},
+		"ssh with -l": {
+			run: func() (string, error) {
+				session, err := NewSSHSession([]string{"--ca-key", "/etc/ssh/user_ca", "-l", "ubuntu", "i-1234567890abcdef0"})
+				if err != nil {
+					return "", err
+				}
+				return "ssh", session.Run()
+			},
+			wantCommand:   "ssh",
+			wantPrincipal: "ubuntu",
+		},
+		"scp": {
+			run: func() (string, error) {
+				session, err := NewSCPSession([]string{"--ca-key", "/etc/ssh/user_ca", "file.txt", "ec2-user@i-1234567890abcdef0:/tmp/"})
+				if err != nil {
+					return "", err
+				}
+				return "scp", session.Run()
+			},
+			wantCommand:   "scp",
+			wantPrincipal: "ec2-user",
+		},
+		"sftp": {
+			run: func() (string, error) {
+				session, err := NewSFTPSession([]string{"--ca-key", "/etc/ssh/user_ca", "ec2-user@i-1234567890abcdef0"})
+				if err != nil {
+					return "", err
+				}
+				return "sftp", session.Run()
+			},
+			wantCommand:   "sftp",
+			wantPrincipal: "ec2-user",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var captured commandCapture
+			_, connectMock := setupMocksForRun(t, testInstance, nil)
+			requests := setupCertMock(t, nil)
+
+			var certPath, certContent string
+			executeCommand = func(cmd string, args []string, _ *log.Logger) error {
+				captured.command, captured.args = cmd, args
+				certPath, certContent = certificateArg(t, args)
+				return nil
+			}
+
+			_, err := tc.run()
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantCommand, captured.command)
+			assert.Contains(t, captured.args, "-i/tmp/test_key")
+			assert.Equal(t, "ssh-ed25519-cert-v01@openssh.com AAAA cert\n", certContent)
+			assert.NoFileExists(t, certPath, "certificate should be removed with the temp dir")
+
+			require.Len(t, *requests, 1)
+			request := (*requests)[0]
+			assert.Equal(t, tc.wantPrincipal, request.Principal)
+			assert.Equal(t, "ssh-ed25519 AAAAC3NzaC1... test@host", request.PublicKey)
+			assert.Equal(t, "ec2ssh "+tc.wantPrincipal+"@i-1234567890abcdef0", request.KeyID)
+			assert.Equal(t, certValidity, request.Validity)
+
+			connectMock.AssertNotCalled(t, "SendSSHPublicKey", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSSHSession_Run_CAKeyError(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+	var captured commandCapture
+	setupMocksForRun(t, testInstance, &captured)
+	setupCertMock(t, errors.New("unable to read key"))
+
+	session, err := NewSSHSession([]string{"--ca-key", "/etc/ssh/user_ca", "ec2-user@i-1234567890abcdef0"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to issue SSH certificate")
+	assert.Empty(t, captured.command, "ssh should not run")
+}
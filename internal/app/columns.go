@@ -0,0 +1,285 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// tagColumnPrefix starts a column showing the value of an arbitrary tag, e.g. "TAG:Env".
+// The tag key keeps its case.
+const tagColumnPrefix = "TAG:"
+
+// countColumn is the extra column of --group-by output.
+const countColumn = "COUNT"
+
+// now is the clock for the UPTIME column, overridden in tests.
+var now = time.Now
+
+// parseListColumn normalizes a single column name: built-in columns are
+// case-insensitive, TAG:<key> columns keep the key as given.
+func parseListColumn(column string) (string, error) {
+	column = strings.TrimSpace(column)
+
+	if len(column) > len(tagColumnPrefix) && strings.EqualFold(column[:len(tagColumnPrefix)], tagColumnPrefix) {
+		return tagColumnPrefix + column[len(tagColumnPrefix):], nil
+	}
+
+	column = strings.ToUpper(strings.ReplaceAll(column, " ", ""))
+	if !slices.Contains(allowedListColumns, column) {
+		return "", fmt.Errorf("invalid column %s", column)
+	}
+
+	return column, nil
+}
+
+// ListColumn is a column name given on the command line, e.g. to --group-by.
+type ListColumn string
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+func (c *ListColumn) UnmarshalText(text []byte) error {
+	column, err := parseListColumn(string(text))
+	if err != nil {
+		return err
+	}
+	*c = ListColumn(column)
+	return nil
+}
+
+// ListSort orders ec2list output by a column.
+// Use a pointer to ListSort where nil means API order.
+type ListSort struct {
+	Column     string
+	Descending bool
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts a column name, prefixed with "-" for descending order.
+func (s *ListSort) UnmarshalText(text []byte) error {
+	name, descending := strings.CutPrefix(string(text), "-")
+
+	column, err := parseListColumn(name)
+	if err != nil {
+		return err
+	}
+
+	*s = ListSort{Column: column, Descending: descending}
+	return nil
+}
+
+// uptime is how long a running instance has been up, in whole seconds.
+// Structured output has the number; tables show it rounded, e.g. "3d4h".
+type uptime int64
+
+// TableString implements tableValue.
+func (u uptime) TableString() string {
+	d := time.Duration(u) * time.Second
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", d/time.Hour, d%time.Hour/time.Minute)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// stringList is a multi-valued column. Structured output has an array;
+// tables and CSV/TSV join the values with commas.
+type stringList []string
+
+func (l stringList) String() string {
+	return strings.Join(l, ",")
+}
+
+// listLookups holds the values of columns that cost extra API calls, looked
+// up once per listing and only when used. The zero value leaves them empty.
+type listLookups struct {
+	accounts     map[string]string       // Profile → account ID, for ACCOUNT
+	connectivity map[string]connectivity // Instance ID → reachability, for CONNECT, SSM-PING and EICE-ID
+}
+
+// listValue returns the value of column for instance, or nil if it has none.
+func listValue(instance ec2client.RegionalInstance, column string, lookups listLookups) any {
+	if key, ok := strings.CutPrefix(column, tagColumnPrefix); ok {
+		for _, tag := range instance.Tags {
+			if tag.Key != nil && *tag.Key == key {
+				return nonEmpty(tag.Value)
+			}
+		}
+		return nil
+	}
+
+	switch column {
+	case "ID":
+		return nonEmpty(instance.InstanceId)
+	case "NAME":
+		return nonEmpty(ec2client.GetInstanceName(instance.Instance))
+	case "STATE":
+		if instance.State != nil {
+			return nonEmpty((*string)(&instance.State.Name))
+		}
+	case "TYPE":
+		return nonEmpty((*string)(&instance.InstanceType))
+	case "AZ":
+		if instance.Placement != nil {
+			return nonEmpty(instance.Placement.AvailabilityZone)
+		}
+	case "REGION":
+		return nonEmpty(&instance.Region)
+	case "PROFILE":
+		return nonEmpty(&instance.Profile)
+	case "ACCOUNT":
+		account := lookups.accounts[instance.Profile]
+		return nonEmpty(&account)
+	case "CONNECT":
+		return connectMethods(instance, lookups.connectivity[aws.ToString(instance.InstanceId)])
+	case "SSM-PING":
+		ping := lookups.connectivity[aws.ToString(instance.InstanceId)].ssmPing
+		return nonEmpty(&ping)
+	case "EICE-ID":
+		eiceID := lookups.connectivity[aws.ToString(instance.InstanceId)].eiceID
+		return nonEmpty(&eiceID)
+	case "PRIVATE-IP":
+		return nonEmpty(instance.PrivateIpAddress)
+	case "PUBLIC-IP":
+		return nonEmpty(instance.PublicIpAddress)
+	case "IPV6":
+		return nonEmpty(instance.Ipv6Address)
+	case "PRIVATE-DNS":
+		return nonEmpty(instance.PrivateDnsName)
+	case "PUBLIC-DNS":
+		return nonEmpty(instance.PublicDnsName)
+	case "VPC":
+		return nonEmpty(instance.VpcId)
+	case "SUBNET":
+		return nonEmpty(instance.SubnetId)
+	case "LAUNCH-TIME":
+		if instance.LaunchTime != nil {
+			return instance.LaunchTime.UTC().Format(time.RFC3339)
+		}
+	case "UPTIME":
+		// LaunchTime is the last start, so it only means uptime while running
+		if instance.LaunchTime != nil && instance.State != nil && instance.State.Name == types.InstanceStateNameRunning {
+			return uptime(now().Sub(*instance.LaunchTime) / time.Second)
+		}
+	case "AMI":
+		return nonEmpty(instance.ImageId)
+	case "KEY-NAME":
+		return nonEmpty(instance.KeyName)
+	case "PLATFORM":
+		return nonEmpty(instance.PlatformDetails)
+	case "ARCH":
+		return nonEmpty((*string)(&instance.Architecture))
+	case "IAM-PROFILE":
+		// Only the ARN is returned; the profile name is its last path element
+		if instance.IamInstanceProfile != nil && instance.IamInstanceProfile.Arn != nil {
+			arn := *instance.IamInstanceProfile.Arn
+			return arn[strings.LastIndex(arn, "/")+1:]
+		}
+	case "LIFECYCLE":
+		if instance.InstanceLifecycle == "" {
+			return "on-demand"
+		}
+		return string(instance.InstanceLifecycle)
+	case "SECURITY-GROUPS":
+		var groups stringList
+		for _, group := range instance.SecurityGroups {
+			if group.GroupName != nil {
+				groups = append(groups, *group.GroupName)
+			}
+		}
+		if len(groups) > 0 {
+			return groups
+		}
+	default:
+		panic(fmt.Sprintf("unexpected list column: %s", column))
+	}
+
+	return nil
+}
+
+// nonEmpty returns the string value, or nil when it is nil or empty.
+func nonEmpty(value *string) any {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return *value
+}
+
+// compareListValues orders two column values: IP addresses by address,
+// numbers numerically, everything else as text. Missing values sort last.
+func compareListValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	if ua, ok := a.(uptime); ok {
+		return cmp.Compare(ua, b.(uptime))
+	}
+
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	if ipa, err := netip.ParseAddr(sa); err == nil {
+		if ipb, err := netip.ParseAddr(sb); err == nil {
+			return ipa.Compare(ipb)
+		}
+	}
+
+	return strings.Compare(sa, sb)
+}
+
+// sortInstances orders instances by the sort column, keeping API order for ties.
+// Missing values sort last in either direction.
+func sortInstances(instances []ec2client.RegionalInstance, sort ListSort, lookups listLookups) {
+	slices.SortStableFunc(instances, func(a, b ec2client.RegionalInstance) int {
+		va, vb := listValue(a, sort.Column, lookups), listValue(b, sort.Column, lookups)
+		if sort.Descending && va != nil && vb != nil {
+			return compareListValues(vb, va)
+		}
+		return compareListValues(va, vb)
+	})
+}
+
+// groupRows counts instances per value of column. Rows are the value and
+// its count, largest groups first.
+func groupRows(instances []ec2client.RegionalInstance, column string, lookups listLookups) [][]any {
+	var rows [][]any
+	index := make(map[string]int)
+
+	for _, instance := range instances {
+		value := listValue(instance, column, lookups)
+
+		key := "\x00" // Distinct from every real value, for instances without one
+		if value != nil {
+			key = fmt.Sprint(value)
+		}
+
+		if i, ok := index[key]; ok {
+			rows[i][1] = rows[i][1].(int) + 1
+			continue
+		}
+		index[key] = len(rows)
+		rows = append(rows, []any{value, 1})
+	}
+
+	slices.SortStableFunc(rows, func(a, b []any) int {
+		if c := cmp.Compare(b[1].(int), a[1].(int)); c != 0 {
+			return c
+		}
+		return compareListValues(a[0], b[0])
+	})
+
+	return rows
+}
@@ -0,0 +1,307 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListColumn(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		"builtin":               {input: "launch-time", want: "LAUNCH-TIME"},
+		"builtin with spaces":   {input: " uptime ", want: "UPTIME"},
+		"tag keeps key case":    {input: "tag:CostCenter", want: "TAG:CostCenter"},
+		"tag key with colon":    {input: "TAG:aws:autoscaling:groupName", want: "TAG:aws:autoscaling:groupName"},
+		"tag key with space":    {input: "TAG:Cost Center", want: "TAG:Cost Center"},
+		"tag without key":       {input: "TAG:", wantErr: true},
+		"unknown":               {input: "COLOR", wantErr: true},
+		"count is not a column": {input: "COUNT", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseListColumn(tc.input)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestListSort_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    ListSort
+		wantErr bool
+	}{
+		"ascending":  {input: "name", want: ListSort{Column: "NAME"}},
+		"descending": {input: "-uptime", want: ListSort{Column: "UPTIME", Descending: true}},
+		"tag":        {input: "-TAG:Env", want: ListSort{Column: "TAG:Env", Descending: true}},
+		"unknown":    {input: "-color", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got ListSort
+			err := got.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "invalid column")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestListValue(t *testing.T) {
+	// No t.Parallel() - modifies global clock
+
+	origNow := now
+	t.Cleanup(func() { now = origNow })
+	now = func() time.Time { return time.Date(2024, 6, 3, 14, 30, 0, 0, time.UTC) }
+
+	launched := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	running := ec2client.RegionalInstance{Instance: types.Instance{
+		InstanceId:         aws.String("i-1"),
+		VpcId:              aws.String("vpc-1"),
+		SubnetId:           aws.String("subnet-1"),
+		LaunchTime:         &launched,
+		State:              &types.InstanceState{Name: types.InstanceStateNameRunning},
+		ImageId:            aws.String("ami-1"),
+		KeyName:            aws.String("deploy"),
+		PlatformDetails:    aws.String("Linux/UNIX"),
+		Architecture:       "arm64",
+		IamInstanceProfile: &types.IamInstanceProfile{Arn: aws.String("arn:aws:iam::123456789012:instance-profile/app/web-role")},
+		InstanceLifecycle:  types.InstanceLifecycleTypeSpot,
+		SecurityGroups: []types.GroupIdentifier{
+			{GroupId: aws.String("sg-1"), GroupName: aws.String("web")},
+			{GroupId: aws.String("sg-2"), GroupName: aws.String("ssh")},
+		},
+		Tags: []types.Tag{{Key: aws.String("Env"), Value: aws.String("prod")}},
+	}}
+
+	stopped := running
+	stopped.State = &types.InstanceState{Name: types.InstanceStateNameStopped}
+	bare := ec2client.RegionalInstance{Instance: types.Instance{InstanceId: aws.String("i-2")}}
+
+	tests := map[string]struct {
+		instance ec2client.RegionalInstance
+		column   string
+		want     any
+	}{
+		"vpc":              {instance: running, column: "VPC", want: "vpc-1"},
+		"subnet":           {instance: running, column: "SUBNET", want: "subnet-1"},
+		"launch time":      {instance: running, column: "LAUNCH-TIME", want: "2024-06-01T12:00:00Z"},
+		"uptime":           {instance: running, column: "UPTIME", want: uptime(2*24*3600 + 2*3600 + 30*60)},
+		"uptime stopped":   {instance: stopped, column: "UPTIME", want: nil},
+		"ami":              {instance: running, column: "AMI", want: "ami-1"},
+		"key name":         {instance: running, column: "KEY-NAME", want: "deploy"},
+		"platform":         {instance: running, column: "PLATFORM", want: "Linux/UNIX"},
+		"arch":             {instance: running, column: "ARCH", want: "arm64"},
+		"iam profile":      {instance: running, column: "IAM-PROFILE", want: "web-role"},
+		"spot":             {instance: running, column: "LIFECYCLE", want: "spot"},
+		"on-demand":        {instance: bare, column: "LIFECYCLE", want: "on-demand"},
+		"security groups":  {instance: running, column: "SECURITY-GROUPS", want: stringList{"web", "ssh"}},
+		"no groups":        {instance: bare, column: "SECURITY-GROUPS", want: nil},
+		"tag":              {instance: running, column: "TAG:Env", want: "prod"},
+		"tag is exact":     {instance: running, column: "TAG:env", want: nil},
+		"missing value":    {instance: bare, column: "VPC", want: nil},
+		"missing arch":     {instance: bare, column: "ARCH", want: nil},
+		"missing launched": {instance: bare, column: "LAUNCH-TIME", want: nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, listValue(tc.instance, tc.column, listLookups{}))
+		})
+	}
+}
+
+func TestListValue_Connectivity(t *testing.T) {
+	t.Parallel()
+
+	instance := ec2client.RegionalInstance{Instance: ec2client.MakeInstance("i-1", ec2client.WithPrivateIP("10.0.0.1"))}
+	lookups := listLookups{connectivity: map[string]connectivity{
+		"i-1": {eiceID: "eice-1", ssmPing: "Online"},
+	}}
+
+	tests := map[string]struct {
+		lookups listLookups
+		column  string
+		want    any
+	}{
+		"connect":         {lookups: lookups, column: "CONNECT", want: stringList{"eice", "ssm"}},
+		"ssm ping":        {lookups: lookups, column: "SSM-PING", want: "Online"},
+		"eice id":         {lookups: lookups, column: "EICE-ID", want: "eice-1"},
+		"no lookups":      {column: "CONNECT", want: nil},
+		"ssm not managed": {column: "SSM-PING", want: nil},
+		"no eice in vpc":  {column: "EICE-ID", want: nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, listValue(instance, tc.column, tc.lookups))
+		})
+	}
+}
+
+func TestUptime_TableString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "0m", uptime(59).TableString())
+	assert.Equal(t, "45m", uptime(45*60).TableString())
+	assert.Equal(t, "3h5m", uptime(3*3600+5*60).TableString())
+	assert.Equal(t, "2d2h", uptime(2*24*3600+2*3600+30*60).TableString())
+}
+
+func TestColumnValuesInOutput(t *testing.T) {
+	t.Parallel()
+
+	columns := []string{"UPTIME", "SECURITY-GROUPS"}
+	rows := [][]any{{uptime(90 * 60), stringList{"web", "ssh"}}}
+
+	var table, csv, jsonl bytes.Buffer
+	require.NoError(t, writeRows(&table, OutputTable, columns, rows, false))
+	require.NoError(t, writeRows(&csv, OutputCSV, columns, rows, false))
+	require.NoError(t, writeRows(&jsonl, OutputJSONL, columns, rows, false))
+
+	assert.Equal(t, "1h30m  web,ssh\n", table.String())
+	assert.Equal(t, "5400,\"web,ssh\"\n", csv.String())
+	assert.Equal(t, `{"uptime":5400,"security_groups":["web","ssh"]}`+"\n", jsonl.String())
+}
+
+// sortTestInstances creates instances with the given names and private IPs.
+func sortTestInstances(pairs ...string) []ec2client.RegionalInstance {
+	var instances []ec2client.RegionalInstance
+	for i := 0; i < len(pairs); i += 2 {
+		instance := types.Instance{InstanceId: aws.String("i-" + pairs[i])}
+		if pairs[i] != "" {
+			instance.Tags = []types.Tag{{Key: aws.String("Name"), Value: aws.String(pairs[i])}}
+		}
+		if pairs[i+1] != "" {
+			instance.PrivateIpAddress = aws.String(pairs[i+1])
+		}
+		instances = append(instances, ec2client.RegionalInstance{Instance: instance})
+	}
+	return instances
+}
+
+func instanceIDs(instances []ec2client.RegionalInstance) []string {
+	var ids []string
+	for _, instance := range instances {
+		ids = append(ids, *instance.InstanceId)
+	}
+	return ids
+}
+
+func TestSortInstances(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sort ListSort
+		want []string
+	}{
+		"by name": {
+			sort: ListSort{Column: "NAME"},
+			want: []string{"i-api", "i-db", "i-web", "i-"},
+		},
+		"by name descending keeps missing last": {
+			sort: ListSort{Column: "NAME", Descending: true},
+			want: []string{"i-web", "i-db", "i-api", "i-"},
+		},
+		"ip addresses numerically": {
+			sort: ListSort{Column: "PRIVATE-IP"},
+			want: []string{"i-db", "i-web", "i-", "i-api"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			instances := sortTestInstances("web", "10.0.0.9", "api", "", "db", "10.0.0.2", "", "10.0.0.10")
+			sortInstances(instances, tc.sort, listLookups{})
+			assert.Equal(t, tc.want, instanceIDs(instances))
+		})
+	}
+}
+
+func TestSortInstances_Uptime(t *testing.T) {
+	t.Parallel()
+
+	// Launch times are relative to the real clock, so the order is stable
+	// regardless of when the test runs.
+	launched := func(id string, ago time.Duration) ec2client.RegionalInstance {
+		launchTime := time.Now().Add(-ago)
+		return ec2client.RegionalInstance{Instance: types.Instance{
+			InstanceId: aws.String(id),
+			LaunchTime: &launchTime,
+			State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+		}}
+	}
+
+	instances := []ec2client.RegionalInstance{
+		launched("i-hour", time.Hour),
+		launched("i-week", 7*24*time.Hour),
+		launched("i-day", 24*time.Hour),
+	}
+
+	sortInstances(instances, ListSort{Column: "UPTIME", Descending: true}, listLookups{})
+
+	assert.Equal(t, []string{"i-week", "i-day", "i-hour"}, instanceIDs(instances))
+}
+
+func TestGroupRows(t *testing.T) {
+	t.Parallel()
+
+	typed := func(id string, instanceType types.InstanceType) ec2client.RegionalInstance {
+		return ec2client.RegionalInstance{Instance: types.Instance{InstanceId: aws.String(id), InstanceType: instanceType}}
+	}
+
+	instances := []ec2client.RegionalInstance{
+		typed("i-1", "t3.micro"),
+		typed("i-2", "m5.large"),
+		typed("i-3", "t3.micro"),
+		typed("i-4", ""),
+		typed("i-5", "c5.xlarge"),
+		typed("i-6", "t3.micro"),
+	}
+
+	rows := groupRows(instances, "TYPE", listLookups{})
+
+	assert.Equal(t, [][]any{
+		{"t3.micro", 3},
+		{"c5.xlarge", 1},
+		{"m5.large", 1},
+		{nil, 1},
+	}, rows)
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/ivoronin/ec2ssh/internal/awsclient"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// getSSMPingStatuses looks up SSM agent status, overridden in tests.
+var getSSMPingStatuses = awsclient.GetSSMPingStatuses
+
+// connectivity is what ec2list knows about reaching an instance besides its addresses.
+type connectivity struct {
+	eiceID  string // EICE endpoint that would be used, empty if the VPC has none
+	ssmPing string // SSM agent ping status, empty if not managed by SSM
+}
+
+// connectMethods returns how a running instance can be reached: "direct" over
+// its public IP, "eice" through an endpoint in its VPC and "ssm" through an
+// online SSM agent. Returns nil when none will work.
+func connectMethods(instance ec2client.RegionalInstance, reach connectivity) any {
+	if instance.State == nil || instance.State.Name != types.InstanceStateNameRunning {
+		return nil
+	}
+
+	var methods stringList
+	if instance.PublicIpAddress != nil {
+		methods = append(methods, "direct")
+	}
+	if reach.eiceID != "" && instance.PrivateIpAddress != nil {
+		methods = append(methods, "eice")
+	}
+	if reach.ssmPing == string(ssmtypes.PingStatusOnline) {
+		methods = append(methods, "ssm")
+	}
+
+	if len(methods) == 0 {
+		return nil
+	}
+	return methods
+}
+
+// lookupConnectivity finds the EICE endpoint and SSM status of every instance.
+// Endpoints are described once per VPC and SSM status in batches, with each
+// client's lookups running in parallel.
+func lookupConnectivity(clients []*ec2client.Client, instances []ec2client.RegionalInstance) (map[string]connectivity, error) {
+	var owners []*ec2client.Client
+	owned := make(map[*ec2client.Client][]ec2client.RegionalInstance)
+	for _, instance := range instances {
+		client := clientFor(clients, instance)
+		if _, ok := owned[client]; !ok {
+			owners = append(owners, client)
+		}
+		owned[client] = append(owned[client], instance)
+	}
+
+	results := make([]map[string]connectivity, len(owners))
+	errs := make([]error, len(owners))
+
+	var wg sync.WaitGroup
+	for i, client := range owners {
+		wg.Go(func() {
+			results[i], errs[i] = lookupClientConnectivity(client, owned[client])
+		})
+	}
+	wg.Wait()
+
+	reach := make(map[string]connectivity, len(instances))
+	for i, client := range owners {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s: %w", client.Location(), errs[i])
+		}
+		for id, result := range results[i] {
+			reach[id] = result
+		}
+	}
+
+	return reach, nil
+}
+
+// lookupClientConnectivity looks up connectivity of instances found by a single client.
+func lookupClientConnectivity(client *ec2client.Client, instances []ec2client.RegionalInstance) (map[string]connectivity, error) {
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, aws.ToString(instance.InstanceId))
+	}
+
+	pings, err := getSSMPingStatuses(client.Config(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make(map[string][]types.Ec2InstanceConnectEndpoint) // VPC ID → endpoints
+	reach := make(map[string]connectivity, len(instances))
+
+	for _, instance := range instances {
+		id := aws.ToString(instance.InstanceId)
+		result := connectivity{ssmPing: pings[id]}
+
+		if vpcID := aws.ToString(instance.VpcId); vpcID != "" {
+			vpcEndpoints, ok := endpoints[vpcID]
+			if !ok {
+				vpcEndpoints, err = client.ListEICEsInVPC(vpcID)
+				if err != nil {
+					return nil, fmt.Errorf("unable to describe endpoints in %s: %w", vpcID, err)
+				}
+				endpoints[vpcID] = vpcEndpoints
+			}
+
+			if eice := ec2client.SelectEICE(vpcEndpoints, aws.ToString(instance.SubnetId)); eice != nil {
+				result.eiceID = aws.ToString(eice.InstanceConnectEndpointId)
+			}
+		}
+
+		reach[id] = result
+	}
+
+	return reach, nil
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectMethods(t *testing.T) {
+	t.Parallel()
+
+	stopped := func(i *types.Instance) {
+		i.State = &types.InstanceState{Name: types.InstanceStateNameStopped}
+	}
+
+	tests := map[string]struct {
+		instance types.Instance
+		reach    connectivity
+		want     any
+	}{
+		"public ip": {
+			instance: ec2client.MakeInstance("i-1", ec2client.WithPublicIP("54.1.2.3")),
+			want:     stringList{"direct"},
+		},
+		"all methods": {
+			instance: ec2client.MakeInstance("i-1", ec2client.WithPublicIP("54.1.2.3"), ec2client.WithPrivateIP("10.0.0.1")),
+			reach:    connectivity{eiceID: "eice-1", ssmPing: "Online"},
+			want:     stringList{"direct", "eice", "ssm"},
+		},
+		"eice needs private ip": {
+			instance: ec2client.MakeInstance("i-1"),
+			reach:    connectivity{eiceID: "eice-1"},
+			want:     nil,
+		},
+		"ssm only when online": {
+			instance: ec2client.MakeInstance("i-1", ec2client.WithPrivateIP("10.0.0.1")),
+			reach:    connectivity{ssmPing: "ConnectionLost"},
+			want:     nil,
+		},
+		"stopped": {
+			instance: ec2client.MakeInstance("i-1", ec2client.WithPublicIP("54.1.2.3"), stopped),
+			reach:    connectivity{ssmPing: "Online"},
+			want:     nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := connectMethods(ec2client.RegionalInstance{Instance: tc.instance}, tc.reach)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// describesVPC matches DescribeInstanceConnectEndpoints calls for the given VPC.
+func describesVPC(vpcID string) any {
+	return mock.MatchedBy(func(input *ec2.DescribeInstanceConnectEndpointsInput) bool {
+		return slices.ContainsFunc(input.Filters, func(filter types.Filter) bool {
+			return aws.ToString(filter.Name) == "vpc-id" && slices.Equal(filter.Values, []string{vpcID})
+		})
+	})
+}
+
+func TestLookupConnectivity(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	origGetSSMPingStatuses := getSSMPingStatuses
+	t.Cleanup(func() { getSSMPingStatuses = origGetSSMPingStatuses })
+
+	var pingRequests [][]string
+	getSSMPingStatuses = func(cfg aws.Config, instanceIDs []string) (map[string]string, error) {
+		pingRequests = append(pingRequests, instanceIDs)
+		return map[string]string{"i-1": "Online", "i-3": "ConnectionLost"}, nil
+	}
+
+	ec2Mock := new(mockEC2API)
+	ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, describesVPC("vpc-a")).Return(
+		ec2client.MakeEICEOutput(
+			ec2client.MakeEICE("eice-a1", "vpc-a", "subnet-1", "a1.example.com"),
+			ec2client.MakeEICE("eice-a2", "vpc-a", "subnet-2", "a2.example.com"),
+		), nil)
+	ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, describesVPC("vpc-b")).Return(
+		ec2client.MakeEICEOutput(), nil)
+
+	client := ec2client.NewTestClient(ec2Mock, nil, nil)
+	instances := inRegion("us-east-1",
+		ec2client.MakeInstance("i-1", ec2client.WithVPC("vpc-a"), ec2client.WithSubnet("subnet-2")),
+		ec2client.MakeInstance("i-2", ec2client.WithVPC("vpc-a"), ec2client.WithSubnet("subnet-3")),
+		ec2client.MakeInstance("i-3", ec2client.WithVPC("vpc-b"), ec2client.WithSubnet("subnet-4")),
+		ec2client.MakeInstance("i-4"),
+	)
+
+	reach, err := lookupConnectivity([]*ec2client.Client{client}, instances)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]connectivity{
+		"i-1": {eiceID: "eice-a2", ssmPing: "Online"},
+		"i-2": {eiceID: "eice-a1"},
+		"i-3": {ssmPing: "ConnectionLost"},
+		"i-4": {},
+	}, reach)
+
+	// One endpoint lookup per VPC and one SSM lookup per client
+	ec2Mock.AssertNumberOfCalls(t, "DescribeInstanceConnectEndpoints", 2)
+	assert.Equal(t, [][]string{{"i-1", "i-2", "i-3", "i-4"}}, pingRequests)
+}
+
+func TestLookupConnectivity_Error(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	origGetSSMPingStatuses := getSSMPingStatuses
+	t.Cleanup(func() { getSSMPingStatuses = origGetSSMPingStatuses })
+
+	getSSMPingStatuses = func(cfg aws.Config, instanceIDs []string) (map[string]string, error) {
+		return nil, errors.New("access denied")
+	}
+
+	client := ec2client.NewTestClientInRegion("eu-west-1", new(mockEC2API), nil, nil)
+	client.SetProfile("prod")
+
+	_, err := lookupConnectivity([]*ec2client.Client{client}, []ec2client.RegionalInstance{
+		{Instance: ec2client.MakeInstance("i-1"), Region: "eu-west-1", Profile: "prod"},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "prod/eu-west-1: access denied")
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/ivoronin/argsieve"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+)
+
+// defaultMaxConns caps the connections --eice-listen and --socks tunnel at
+// once, as an endpoint only takes a limited number of concurrent connections.
+const defaultMaxConns = 10
+
+// dialEICE opens an EICE WebSocket tunnel, overridden in tests.
+var dialEICE tunnel.Dialer = tunnel.DefaultDialer
+
+// EICEListenSpec is a local port forwarded to an instance port by --eice-listen.
+type EICEListenSpec struct {
+	LocalAddr  string // Address to listen on
+	LocalPort  int    // 0 = any free port
+	RemotePort int
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts [laddr:]lport:rport, where laddr defaults to localhost and an IPv6
+// address is written in brackets, e.g. "5432:5432" or "[::1]:3389:3389".
+func (e *EICEListenSpec) UnmarshalText(text []byte) error {
+	fields := splitForwardSpec(string(text))
+
+	spec := EICEListenSpec{LocalAddr: "localhost"}
+	switch len(fields) {
+	case 2:
+	case 3:
+		spec.LocalAddr, fields = fields[0], fields[1:]
+	default:
+		return fmt.Errorf("invalid listen spec: %s (expected [laddr:]lport:rport)", text)
+	}
+
+	var err error
+	if spec.LocalPort, err = strconv.Atoi(fields[0]); err != nil || spec.LocalPort < 0 || spec.LocalPort > 65535 {
+		return fmt.Errorf("invalid listen spec: %s (bad local port %s)", text, fields[0])
+	}
+	if spec.RemotePort, err = strconv.Atoi(fields[1]); err != nil || spec.RemotePort < 1 || spec.RemotePort > 65535 {
+		return fmt.Errorf("invalid listen spec: %s (bad remote port %s)", text, fields[1])
+	}
+	if spec.LocalAddr == "" {
+		return fmt.Errorf("invalid listen spec: %s (empty address)", text)
+	}
+
+	*e = spec
+	return nil
+}
+
+// MaxConns is a cap on connections tunneled at once.
+type MaxConns int
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+func (m *MaxConns) UnmarshalText(text []byte) error {
+	n, err := strconv.Atoi(string(text))
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid connection limit: %s (must be a positive number)", text)
+	}
+	*m = MaxConns(n)
+	return nil
+}
+
+// EICEListenSession listens on a local port and tunnels every connection to
+// an instance port through EC2 Instance Connect Endpoint, like
+// aws ec2-instance-connect open-tunnel, without ssh in the middle.
+type EICEListenSession struct {
+	baseInstanceSession
+	EICEID   string              `long:"eice-id"`      // Empty = endpoint in the instance's VPC
+	AddrType *ec2client.AddrType `long:"address-type"` // nil = private IPv4, then IPv6
+	Listen   *EICEListenSpec     `long:"eice-listen"`
+	MaxConns MaxConns            `long:"max-connections"` // 0 = defaultMaxConns
+
+	// Runtime
+	client *ec2client.Client // EC2 API client for the instance's profile and region
+	addr   string            // Instance address the endpoint connects to
+	eiceID string            // Endpoint the tunnels go through
+}
+
+// NewEICEListenSession creates an EICEListenSession from command-line arguments.
+func NewEICEListenSession(args []string) (*EICEListenSession, error) {
+	var session EICEListenSession
+
+	positional, err := argsieve.Parse(&session, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	if session.Listen == nil {
+		return nil, fmt.Errorf("%w: --eice-listen requires [laddr:]lport:rport", ErrUsage)
+	}
+
+	if err := validateProfileFlags(session.Profile, session.Profiles, session.AllProfiles); err != nil {
+		return nil, err
+	}
+
+	switch len(positional) {
+	case 0:
+	case 1:
+		session.Destination = positional[0]
+	default:
+		return nil, fmt.Errorf("%w: unexpected argument %s", ErrUsage, positional[1])
+	}
+
+	if session.MaxConns == 0 {
+		session.MaxConns = defaultMaxConns
+	}
+
+	return &session, nil
+}
+
+// Run resolves the instance and its endpoint, and tunnels connections until
+// interrupted.
+func (s *EICEListenSession) Run() error {
+	instanceID, err := s.resolve()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.Listen.LocalAddr, strconv.Itoa(s.Listen.LocalPort)))
+	if err != nil {
+		return fmt.Errorf("unable to listen: %w", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	_, _ = fmt.Fprintf(os.Stderr, "forwarding %s to %s port %d via %s\n", listener.Addr(), instanceID, s.Listen.RemotePort, s.eiceID)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	forwarder := &tunnel.Forwarder{Dial: s.dial, MaxConns: int(s.MaxConns), Log: os.Stderr}
+	return forwarder.Serve(ctx, listener)
+}
+
+// resolve finds the instance, the address the endpoint connects to and the
+// endpoint, and returns the instance ID.
+func (s *EICEListenSession) resolve() (string, error) {
+	client, instance, err := s.resolveTunnelInstance()
+	if err != nil {
+		return "", err
+	}
+	s.client = client
+
+	result, err := ec2client.GetEICEAddr(instance, s.AddrType)
+	if err != nil {
+		return "", err
+	}
+	s.addr = result.Addr
+
+	s.eiceID, err = findEICEID(client, instance, s.EICEID)
+	if err != nil {
+		return "", err
+	}
+
+	return *instance.InstanceId, nil
+}
+
+// dial opens a tunnel for one connection. Presigned URIs expire, so each
+// connection gets a fresh one.
+func (s *EICEListenSession) dial(net.Conn) (tunnel.TunnelConnection, error) {
+	uri, err := s.client.CreateEICETunnelURI(s.addr, strconv.Itoa(s.Listen.RemotePort), s.eiceID)
+	if err != nil {
+		return nil, err
+	}
+	return dialEICE(uri)
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEICEListenSpec_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    EICEListenSpec
+		wantErr string
+	}{
+		"ports only":         {input: "15432:5432", want: EICEListenSpec{LocalAddr: "localhost", LocalPort: 15432, RemotePort: 5432}},
+		"with local address": {input: "0.0.0.0:3389:3389", want: EICEListenSpec{LocalAddr: "0.0.0.0", LocalPort: 3389, RemotePort: 3389}},
+		"ipv6 local address": {input: "[::1]:8080:80", want: EICEListenSpec{LocalAddr: "::1", LocalPort: 8080, RemotePort: 80}},
+		"any local port":     {input: "0:22", want: EICEListenSpec{LocalAddr: "localhost", LocalPort: 0, RemotePort: 22}},
+		"single port":        {input: "5432", wantErr: "expected [laddr:]lport:rport"},
+		"remote host given":  {input: "5432:db:5432:1", wantErr: "expected [laddr:]lport:rport"},
+		"bad local port":     {input: "pg:5432", wantErr: "bad local port pg"},
+		"bad remote port":    {input: "5432:0", wantErr: "bad remote port 0"},
+		"empty address":      {input: ":5432:5432", wantErr: "empty address"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var spec EICEListenSpec
+			err := spec.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, spec)
+		})
+	}
+}
+
+func TestNewEICEListenSession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args         []string
+		wantMaxConns MaxConns
+		wantErr      string
+	}{
+		"default cap": {
+			args:         []string{"--eice-listen", "5432:5432", "db-host"},
+			wantMaxConns: defaultMaxConns,
+		},
+		"own cap": {
+			args:         []string{"--eice-listen", "3389:3389", "--max-connections", "2", "win-host"},
+			wantMaxConns: 2,
+		},
+		"zero cap": {
+			args:    []string{"--eice-listen", "3389:3389", "--max-connections", "0", "win-host"},
+			wantErr: "invalid connection limit",
+		},
+		"missing spec": {
+			args:    []string{"--max-connections", "2", "win-host"},
+			wantErr: "--eice-listen requires",
+		},
+		"extra argument": {
+			args:    []string{"--eice-listen", "3389:3389", "win-host", "other"},
+			wantErr: "unexpected argument other",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			session, err := NewEICEListenSession(tc.args)
+
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, ErrUsage)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantMaxConns, session.MaxConns)
+		})
+	}
+}
+
+// nopConnection is a TunnelConnection that carries nothing.
+type nopConnection struct{}
+
+func (nopConnection) Reader() io.Reader { return strings.NewReader("") }
+func (nopConnection) Writer() io.Writer { return io.Discard }
+func (nopConnection) Close()            {}
+
+func TestEICEListenSession_Dial(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	tests := map[string]struct {
+		args       []string
+		wantEICEID string
+	}{
+		"endpoint guessed from VPC": {args: nil, wantEICEID: "eice-123"},
+		"endpoint given":            {args: []string{"--eice-id", "eice-123"}, wantEICEID: "eice-123"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ec2Mock, _ := setupMocksForRun(t, testInstance, nil)
+
+			var presigned []string
+			signer := new(mockHTTPRequestSigner)
+			signer.On("PresignHTTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					presigned = append(presigned, args.Get(2).(*http.Request).URL.RawQuery)
+				}).
+				Return("wss://eice.example.com/openTunnel?signed", nil, nil)
+			ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, mock.Anything).Return(
+				ec2client.MakeEICEOutput(ec2client.MakeEICE("eice-123", "vpc-123", "subnet-456", "eice.example.com")), nil)
+			newEC2Client = func(cfg aws.Config, logger *log.Logger) (*ec2client.Client, error) {
+				return ec2client.NewTestClient(ec2Mock, new(mockEC2InstanceConnectAPI), signer), nil
+			}
+
+			origDialEICE := dialEICE
+			t.Cleanup(func() { dialEICE = origDialEICE })
+			var dialed []string
+			dialEICE = func(uri string) (tunnel.TunnelConnection, error) {
+				dialed = append(dialed, uri)
+				return nopConnection{}, nil
+			}
+
+			session, err := NewEICEListenSession(append([]string{"--eice-listen", "13389:3389"}, append(tc.args, "i-1234567890abcdef0")...))
+			require.NoError(t, err)
+
+			instanceID, err := session.resolve()
+			require.NoError(t, err)
+			assert.Equal(t, "i-1234567890abcdef0", instanceID)
+			assert.Equal(t, tc.wantEICEID, session.eiceID)
+
+			// Every connection gets a freshly presigned URI to the private address
+			for range 2 {
+				_, err := session.dial(nil)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, []string{"wss://eice.example.com/openTunnel?signed", "wss://eice.example.com/openTunnel?signed"}, dialed)
+			require.Len(t, presigned, 2)
+			for _, query := range presigned {
+				assert.Contains(t, query, "privateIpAddress=10.0.0.1")
+				assert.Contains(t, query, "remotePort=3389")
+				assert.Contains(t, query, "instanceConnectEndpointId=eice-123")
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ivoronin/argsieve"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// executable locates the ec2ssh binary for generated ProxyCommands, overridden in tests.
+var executable = os.Executable
+
+// exportStates are the instance states worth a Host block; terminated instances are gone for good.
+var exportStates = ec2client.States{Names: []string{"pending", "running", "stopping", "stopped"}}
+
+// ExportOptions holds the parsed configuration for --export-ssh-config.
+type ExportOptions struct {
+	Region       string              `long:"region"`
+	Regions      *ec2client.Regions  `long:"regions"` // nil = Region only
+	Profile      string              `long:"profile"`
+	Profiles     string              `long:"profiles"` // Glob over configured profiles
+	AllProfiles  bool                `long:"all-profiles"`
+	Filters      ec2client.Filters   `long:"filter"` // EC2 API filters, repeatable
+	Login        string              `short:"l"`     // User for every host, empty = inferred per instance
+	IdentityFile string              `short:"i"`
+	AddrType     *ec2client.AddrType `long:"address-type"` // nil = auto-detect
+	EICEID       string              `long:"eice-id"`
+	UseEICE      bool                `long:"use-eice"`
+	UseSSM       bool                `long:"use-ssm"`
+	Transport    *Transports         `long:"transport"` // nil = --use-eice/--use-ssm, otherwise direct
+	Debug        bool                `long:"debug"`
+}
+
+// NewExportOptions creates ExportOptions from command-line arguments.
+func NewExportOptions(args []string) (*ExportOptions, error) {
+	var options ExportOptions
+
+	positional, err := argsieve.Parse(&options, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %s", ErrUsage, positional[0])
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &options, nil
+}
+
+// Validate checks for conflicting flags.
+func (o *ExportOptions) Validate() error {
+	if o.UseEICE && o.UseSSM {
+		return fmt.Errorf("%w: --use-eice and --use-ssm are mutually exclusive", ErrUsage)
+	}
+	if o.Transport != nil && (o.UseEICE || o.UseSSM) {
+		return fmt.Errorf("%w: --transport cannot be combined with --use-eice or --use-ssm", ErrUsage)
+	}
+
+	return validateProfileFlags(o.Profile, o.Profiles, o.AllProfiles)
+}
+
+// RunExportSSHConfig executes the export-ssh-config intent with the given arguments.
+func RunExportSSHConfig(args []string) error {
+	options, err := NewExportOptions(args)
+	if err != nil {
+		return err
+	}
+
+	instances, logins, path, err := listExportInstances(options)
+	if err != nil {
+		return err
+	}
+
+	return writeSSHConfig(os.Stdout, instances, options, path, logins)
+}
+
+// listExportInstances lists the instances to export, finds their logins
+// unless -l is given, and locates the ec2ssh binary that their ProxyCommands will run.
+func listExportInstances(options *ExportOptions) ([]ec2client.RegionalInstance, loginMap, string, error) {
+	logger := log.New(io.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	if options.Debug {
+		logger.SetOutput(os.Stderr)
+	}
+
+	clients, err := newSearchClients(options.Region, options.Profile, profilePattern(options.Profiles, options.AllProfiles), options.Regions, logger)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	query := ec2client.ListQuery{Filters: append(slices.Clone(options.Filters.List), exportStates.Filter())}
+	instances, err := ec2client.ListInstancesInRegions(clients, query)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("unable to list instances: %w", err)
+	}
+
+	var logins loginMap
+	if options.Login == "" {
+		logins = instanceLogins(clients, instances, logger)
+	}
+
+	path, err := executable()
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("unable to locate ec2ssh: %w", err)
+	}
+
+	return instances, logins, path, nil
+}
+
+// writeSSHConfig writes a Host block per instance, sorted by alias and then
+// instance ID so that regenerating the file gives a minimal diff.
+func writeSSHConfig(w io.Writer, instances []ec2client.RegionalInstance, options *ExportOptions, path string, logins loginMap) error {
+	aliases := hostAliases(instances)
+
+	slices.SortStableFunc(instances, func(a, b ec2client.RegionalInstance) int {
+		idA, idB := aws.ToString(a.InstanceId), aws.ToString(b.InstanceId)
+		if c := strings.Compare(aliases[idA], aliases[idB]); c != 0 {
+			return c
+		}
+		return strings.Compare(idA, idB)
+	})
+
+	var b strings.Builder
+	b.WriteString("# Generated by ec2ssh --export-ssh-config; regenerate rather than edit.\n")
+
+	for _, instance := range instances {
+		id := aws.ToString(instance.InstanceId)
+
+		login := options.Login
+		if login == "" {
+			login = logins.login(instance.Instance)
+		}
+
+		b.WriteString("\n")
+		if alias := aliases[id]; alias != id {
+			fmt.Fprintf(&b, "Host %s %s\n", alias, id)
+		} else {
+			fmt.Fprintf(&b, "Host %s\n", id)
+		}
+		fmt.Fprintf(&b, "    HostName %s\n", id)
+		fmt.Fprintf(&b, "    User %s\n", login)
+		if port := instancePortTag(instance.Instance); port != "" {
+			fmt.Fprintf(&b, "    Port %s\n", port)
+		}
+		fmt.Fprintf(&b, "    HostKeyAlias %s\n", id)
+		if options.IdentityFile != "" {
+			fmt.Fprintf(&b, "    IdentityFile %s\n", sshConfigQuote(options.IdentityFile))
+		}
+		fmt.Fprintf(&b, "    ProxyCommand %s\n", proxyCommandFor(instance, options, path))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// hostAliases returns a Host alias per instance ID: the Name tag made safe for
+// ssh_config, suffixed with the instance ID when several instances share it.
+// Instances without a usable name are known by their ID alone.
+func hostAliases(instances []ec2client.RegionalInstance) map[string]string {
+	names := make(map[string]string, len(instances))
+	counts := make(map[string]int)
+	for _, instance := range instances {
+		name := sanitizeHostAlias(aws.ToString(ec2client.GetInstanceName(instance.Instance)))
+		names[aws.ToString(instance.InstanceId)] = name
+		counts[name]++
+	}
+
+	aliases := make(map[string]string, len(instances))
+	for id, name := range names {
+		switch {
+		case name == "":
+			aliases[id] = id
+		case counts[name] > 1:
+			aliases[id] = name + "-" + id
+		default:
+			aliases[id] = name
+		}
+	}
+	return aliases
+}
+
+// sanitizeHostAlias replaces characters that ssh_config treats as whitespace,
+// patterns or quoting with "-".
+func sanitizeHostAlias(name string) string {
+	alias := strings.Map(func(r rune) rune {
+		if r <= ' ' || strings.ContainsRune(`*?!,"'#\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	return strings.Trim(alias, "-")
+}
+
+// proxyCommandFor returns the ProxyCommand that reaches instance through ec2ssh --proxy.
+// Region and profile are pinned to where the instance was found.
+func proxyCommandFor(instance ec2client.RegionalInstance, options *ExportOptions, path string) string {
+	args := []string{path, "--proxy", "--region", instance.Region}
+	if instance.Profile != "" {
+		args = append(args, "--profile", instance.Profile)
+	}
+	if options.AddrType != nil {
+		args = append(args, "--address-type", options.AddrType.String())
+	}
+
+	switch {
+	case options.Transport != nil:
+		args = append(args, "--transport", options.Transport.String())
+		if options.EICEID != "" {
+			args = append(args, "--eice-id", options.EICEID)
+		}
+	case options.UseSSM:
+		args = append(args, "--use-ssm")
+	case options.EICEID != "":
+		args = append(args, "--eice-id", options.EICEID)
+	case options.UseEICE:
+		args = append(args, "--use-eice")
+	}
+
+	if options.IdentityFile != "" {
+		args = append(args, "-i", options.IdentityFile)
+	}
+
+	return shellescape.QuoteCommand(args) + " %r@%h:%p"
+}
+
+// sshConfigQuote double-quotes an ssh_config argument containing whitespace.
+func sshConfigQuote(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
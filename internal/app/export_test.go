@@ -0,0 +1,200 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExportOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args        []string
+		wantErr     bool
+		errContains string
+	}{
+		"defaults":            {args: []string{}},
+		"all options":         {args: []string{"--all-profiles", "--regions", "all", "--filter", "tag:Env=prod", "-l", "admin", "-i", "~/.ssh/id", "--transport", "auto"}},
+		"unexpected argument": {args: []string{"web"}, wantErr: true, errContains: "unexpected argument"},
+		"eice and ssm":        {args: []string{"--use-eice", "--use-ssm"}, wantErr: true, errContains: "mutually exclusive"},
+		"transport with eice": {args: []string{"--transport", "auto", "--use-eice"}, wantErr: true, errContains: "--transport cannot be combined"},
+		"profile conflict":    {args: []string{"--profile", "a", "--all-profiles"}, wantErr: true, errContains: "--profile cannot be combined"},
+		"invalid filter":      {args: []string{"--filter", "nope"}, wantErr: true, errContains: "invalid filter"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewExportOptions(tc.args)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrUsage), "expected ErrUsage, got: %v", err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSanitizeHostAlias(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"plain":          {input: "web-01", want: "web-01"},
+		"spaces":         {input: " My Web Server ", want: "My-Web-Server"},
+		"pattern chars":  {input: "db*primary?", want: "db-primary"},
+		"negation":       {input: "!bastion", want: "bastion"},
+		"quotes":         {input: `api "blue"`, want: "api--blue"},
+		"only specials":  {input: "***", want: ""},
+		"empty":          {input: "", want: ""},
+		"dots and colon": {input: "app.prod:v2", want: "app.prod:v2"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, sanitizeHostAlias(tc.input))
+		})
+	}
+}
+
+func TestHostAliases(t *testing.T) {
+	t.Parallel()
+
+	instances := inRegion("us-east-1",
+		ec2client.MakeInstance("i-1", ec2client.WithNameTag("web")),
+		ec2client.MakeInstance("i-2", ec2client.WithNameTag("web")),
+		ec2client.MakeInstance("i-3", ec2client.WithNameTag("db")),
+		ec2client.MakeInstance("i-4"),
+		ec2client.MakeInstance("i-5", ec2client.WithNameTag("***")),
+	)
+
+	assert.Equal(t, map[string]string{
+		"i-1": "web-i-1",
+		"i-2": "web-i-2",
+		"i-3": "db",
+		"i-4": "i-4",
+		"i-5": "i-5",
+	}, hostAliases(instances))
+}
+
+func TestProxyCommandFor(t *testing.T) {
+	t.Parallel()
+
+	instance := ec2client.RegionalInstance{Instance: ec2client.MakeInstance("i-1"), Region: "eu-west-1"}
+	profiled := instance
+	profiled.Profile = "prod"
+
+	tests := map[string]struct {
+		instance ec2client.RegionalInstance
+		args     []string
+		want     string
+	}{
+		"direct": {
+			instance: instance,
+			want:     "/usr/bin/ec2ssh --proxy --region eu-west-1 %r@%h:%p",
+		},
+		"profile": {
+			instance: profiled,
+			want:     "/usr/bin/ec2ssh --proxy --region eu-west-1 --profile prod %r@%h:%p",
+		},
+		"ssm": {
+			instance: instance,
+			args:     []string{"--use-ssm"},
+			want:     "/usr/bin/ec2ssh --proxy --region eu-west-1 --use-ssm %r@%h:%p",
+		},
+		"eice id": {
+			instance: instance,
+			args:     []string{"--eice-id", "eice-1"},
+			want:     "/usr/bin/ec2ssh --proxy --region eu-west-1 --eice-id eice-1 %r@%h:%p",
+		},
+		"transport auto": {
+			instance: instance,
+			args:     []string{"--transport", "auto", "--address-type", "private"},
+			want:     "/usr/bin/ec2ssh --proxy --region eu-west-1 --address-type private --transport direct,eice,ssm %r@%h:%p",
+		},
+		"identity with space": {
+			instance: instance,
+			args:     []string{"--use-eice", "-i", "/home/me/my keys/id"},
+			want:     "/usr/bin/ec2ssh --proxy --region eu-west-1 --use-eice -i '/home/me/my keys/id' %r@%h:%p",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options, err := NewExportOptions(tc.args)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, proxyCommandFor(tc.instance, options, "/usr/bin/ec2ssh"))
+		})
+	}
+}
+
+func TestWriteSSHConfig(t *testing.T) {
+	t.Parallel()
+
+	ubuntu := func(i *types.Instance) { i.PlatformDetails = aws.String("Ubuntu Pro") }
+
+	instances := []ec2client.RegionalInstance{
+		{Instance: ec2client.MakeInstance("i-2", ec2client.WithNameTag("web")), Region: "us-east-1", Profile: "prod"},
+		{Instance: ec2client.MakeInstance("i-9"), Region: "us-east-1", Profile: "prod"},
+		{Instance: ec2client.MakeInstance("i-1", ec2client.WithNameTag("web"), ubuntu), Region: "eu-west-1", Profile: "prod"},
+		{Instance: ec2client.MakeInstance("i-3", ec2client.WithNameTag("api server"), ec2client.WithTag("ec2ssh:port", "2222")), Region: "us-east-1", Profile: "dev"},
+	}
+
+	options, err := NewExportOptions([]string{"-i", "~/.ssh/id_ed25519"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = writeSSHConfig(&buf, instances, options, "/usr/bin/ec2ssh", loginMap{"i-3": "admin"})
+	require.NoError(t, err)
+
+	want := `# Generated by ec2ssh --export-ssh-config; regenerate rather than edit.
+
+Host api-server i-3
+    HostName i-3
+    User admin
+    Port 2222
+    HostKeyAlias i-3
+    IdentityFile ~/.ssh/id_ed25519
+    ProxyCommand /usr/bin/ec2ssh --proxy --region us-east-1 --profile dev -i '~/.ssh/id_ed25519' %r@%h:%p
+
+Host i-9
+    HostName i-9
+    User ec2-user
+    HostKeyAlias i-9
+    IdentityFile ~/.ssh/id_ed25519
+    ProxyCommand /usr/bin/ec2ssh --proxy --region us-east-1 --profile prod -i '~/.ssh/id_ed25519' %r@%h:%p
+
+Host web-i-1 i-1
+    HostName i-1
+    User ubuntu
+    HostKeyAlias i-1
+    IdentityFile ~/.ssh/id_ed25519
+    ProxyCommand /usr/bin/ec2ssh --proxy --region eu-west-1 --profile prod -i '~/.ssh/id_ed25519' %r@%h:%p
+
+Host web-i-2 i-2
+    HostName i-2
+    User ec2-user
+    HostKeyAlias i-2
+    IdentityFile ~/.ssh/id_ed25519
+    ProxyCommand /usr/bin/ec2ssh --proxy --region us-east-1 --profile prod -i '~/.ssh/id_ed25519' %r@%h:%p
+`
+	assert.Equal(t, want, buf.String())
+}
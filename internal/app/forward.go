@@ -0,0 +1,204 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ivoronin/argsieve"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+)
+
+// dialSSM starts an SSM port forwarding session, overridden in tests.
+var dialSSM = tunnel.DialSSM
+
+// ForwardSpec is a port forwarded through an instance, as for ssh -L.
+type ForwardSpec struct {
+	LocalAddr  string // Address to listen on
+	LocalPort  int    // 0 = any free port
+	RemoteHost string // Host to connect to, as seen from the instance
+	RemotePort int
+}
+
+// String returns the spec in [laddr:]lport:rhost:rport form.
+func (f ForwardSpec) String() string {
+	return net.JoinHostPort(f.LocalAddr, strconv.Itoa(f.LocalPort)) + ":" +
+		net.JoinHostPort(f.RemoteHost, strconv.Itoa(f.RemotePort))
+}
+
+// ForwardSpecs collects --forward specs.
+// Each UnmarshalText call appends one spec, so the flag may be repeated.
+type ForwardSpecs struct {
+	List []ForwardSpec
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts [laddr:]lport:rhost:rport, where laddr defaults to localhost and
+// IPv6 addresses are written in brackets, e.g. "5432:db.internal:5432" or
+// "[::1]:8080:[fd00::10]:80".
+func (f *ForwardSpecs) UnmarshalText(text []byte) error {
+	fields := splitForwardSpec(string(text))
+
+	spec := ForwardSpec{LocalAddr: "localhost"}
+	switch len(fields) {
+	case 3:
+	case 4:
+		spec.LocalAddr, fields = fields[0], fields[1:]
+	default:
+		return fmt.Errorf("invalid forward: %s (expected [laddr:]lport:rhost:rport)", text)
+	}
+	spec.RemoteHost = fields[1]
+
+	var err error
+	if spec.LocalPort, err = strconv.Atoi(fields[0]); err != nil || spec.LocalPort < 0 || spec.LocalPort > 65535 {
+		return fmt.Errorf("invalid forward: %s (bad local port %s)", text, fields[0])
+	}
+	if spec.RemotePort, err = strconv.Atoi(fields[2]); err != nil || spec.RemotePort < 1 || spec.RemotePort > 65535 {
+		return fmt.Errorf("invalid forward: %s (bad remote port %s)", text, fields[2])
+	}
+	if spec.LocalAddr == "" || spec.RemoteHost == "" {
+		return fmt.Errorf("invalid forward: %s (empty address)", text)
+	}
+
+	f.List = append(f.List, spec)
+	return nil
+}
+
+// splitForwardSpec splits a forward spec at the colons outside brackets,
+// dropping the brackets around IPv6 addresses.
+func splitForwardSpec(text string) []string {
+	var fields []string
+	var field strings.Builder
+	inBrackets := false
+
+	for _, r := range text {
+		switch {
+		case r == '[' && !inBrackets:
+			inBrackets = true
+		case r == ']' && inBrackets:
+			inBrackets = false
+		case r == ':' && !inBrackets:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+
+	return append(fields, field.String())
+}
+
+// ForwardSession forwards local ports through an instance with SSM Session
+// Manager, without SSH or a key push.
+type ForwardSession struct {
+	baseInstanceSession
+	Forwards ForwardSpecs `long:"forward"` // Repeatable
+}
+
+// NewForwardSession creates a ForwardSession from command-line arguments.
+func NewForwardSession(args []string) (*ForwardSession, error) {
+	var session ForwardSession
+
+	positional, err := argsieve.Parse(&session, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	if len(session.Forwards.List) == 0 {
+		return nil, fmt.Errorf("%w: --forward requires [laddr:]lport:rhost:rport", ErrUsage)
+	}
+
+	if err := validateProfileFlags(session.Profile, session.Profiles, session.AllProfiles); err != nil {
+		return nil, err
+	}
+
+	switch len(positional) {
+	case 0:
+	case 1:
+		session.Destination = positional[0]
+	default:
+		return nil, fmt.Errorf("%w: unexpected argument %s", ErrUsage, positional[1])
+	}
+
+	return &session, nil
+}
+
+// Run resolves the instance and forwards the ports until interrupted.
+func (s *ForwardSession) Run() error {
+	client, instance, err := s.resolveTunnelInstance()
+	if err != nil {
+		return err
+	}
+	instanceID := *instance.InstanceId
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SSM calls must use the profile and region the instance was found in
+	dial := ssmForwardDialer(ctx, client.Config(), instanceID, s.logger)
+
+	return serveForwards(ctx, s.Forwards.List, instanceID, dial, os.Stderr)
+}
+
+// ssmForwardDialer returns a dial function starting an SSM session through
+// instanceID for each forwarded connection.
+func ssmForwardDialer(ctx context.Context, cfg aws.Config, instanceID string, logger *log.Logger) func(ForwardSpec) (tunnel.TunnelConnection, error) {
+	return func(spec ForwardSpec) (tunnel.TunnelConnection, error) {
+		logger.Printf("connecting to %s:%d via %s", spec.RemoteHost, spec.RemotePort, instanceID)
+		return dialSSMHost(ctx, cfg, instanceID, spec.RemoteHost, spec.RemotePort)
+	}
+}
+
+// serveForwards listens on every spec's local address and forwards
+// connections through dial until ctx is done, reporting each forward and
+// connection to stderr. Nothing is served unless all addresses can be
+// listened on; an accept error stops all forwards.
+func serveForwards(ctx context.Context, specs []ForwardSpec, via string, dial func(ForwardSpec) (tunnel.TunnelConnection, error), stderr io.Writer) error {
+	listeners := make([]net.Listener, 0, len(specs))
+	defer func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}()
+
+	for _, spec := range specs {
+		listener, err := net.Listen("tcp", net.JoinHostPort(spec.LocalAddr, strconv.Itoa(spec.LocalPort)))
+		if err != nil {
+			return fmt.Errorf("unable to forward %s: %w", spec, err)
+		}
+		listeners = append(listeners, listener)
+		_, _ = fmt.Fprintf(stderr, "forwarding %s to %s via %s\n",
+			listener.Addr(), net.JoinHostPort(spec.RemoteHost, strconv.Itoa(spec.RemotePort)), via)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(specs))
+	for i, spec := range specs {
+		wg.Go(func() {
+			forwarder := &tunnel.Forwarder{
+				Dial: func(net.Conn) (tunnel.TunnelConnection, error) { return dial(spec) },
+				Log:  stderr,
+			}
+			errs[i] = forwarder.Serve(ctx, listeners[i])
+			if errs[i] != nil {
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardSpecs_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    ForwardSpec
+		wantErr string
+	}{
+		"without local address": {
+			input: "5432:db.internal:5432",
+			want:  ForwardSpec{LocalAddr: "localhost", LocalPort: 5432, RemoteHost: "db.internal", RemotePort: 5432},
+		},
+		"with local address": {
+			input: "0.0.0.0:8080:10.0.1.20:80",
+			want:  ForwardSpec{LocalAddr: "0.0.0.0", LocalPort: 8080, RemoteHost: "10.0.1.20", RemotePort: 80},
+		},
+		"ipv6 in brackets": {
+			input: "[::1]:8080:[fd00::10]:80",
+			want:  ForwardSpec{LocalAddr: "::1", LocalPort: 8080, RemoteHost: "fd00::10", RemotePort: 80},
+		},
+		"any local port": {
+			input: "0:localhost:6379",
+			want:  ForwardSpec{LocalAddr: "localhost", LocalPort: 0, RemoteHost: "localhost", RemotePort: 6379},
+		},
+		"too few fields":      {input: "5432:db", wantErr: "expected [laddr:]lport:rhost:rport"},
+		"bare ipv6":           {input: "8080:fd00::10:80", wantErr: "expected [laddr:]lport:rhost:rport"},
+		"bad local port":      {input: "pg:db:5432", wantErr: "bad local port pg"},
+		"local port range":    {input: "70000:db:5432", wantErr: "bad local port 70000"},
+		"remote port zero":    {input: "5432:db:0", wantErr: "bad remote port 0"},
+		"empty remote host":   {input: "5432::5432", wantErr: "empty address"},
+		"empty local address": {input: ":5432:db:5432", wantErr: "empty address"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var specs ForwardSpecs
+			err := specs.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []ForwardSpec{tc.want}, specs.List)
+		})
+	}
+}
+
+func TestNewForwardSession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args            []string
+		wantForwards    int
+		wantDestination string
+		wantErr         string
+	}{
+		"single forward": {
+			args:            []string{"--forward", "5432:db:5432", "bastion"},
+			wantForwards:    1,
+			wantDestination: "bastion",
+		},
+		"several forwards": {
+			args:            []string{"--forward", "5432:db:5432", "--forward", "6379:cache:6379", "--region", "eu-west-1", "bastion"},
+			wantForwards:    2,
+			wantDestination: "bastion",
+		},
+		"no destination": {
+			args:         []string{"--forward", "5432:db:5432"},
+			wantForwards: 1,
+		},
+		"no forward": {
+			args:    []string{"bastion"},
+			wantErr: "--forward requires",
+		},
+		"invalid forward": {
+			args:    []string{"--forward", "5432", "bastion"},
+			wantErr: "invalid forward",
+		},
+		"extra argument": {
+			args:    []string{"--forward", "5432:db:5432", "bastion", "uptime"},
+			wantErr: "unexpected argument uptime",
+		},
+		"profile conflict": {
+			args:    []string{"--forward", "5432:db:5432", "--profile", "dev", "--all-profiles", "bastion"},
+			wantErr: "cannot be combined",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			session, err := NewForwardSession(tc.args)
+
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, ErrUsage)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, session.Forwards.List, tc.wantForwards)
+			assert.Equal(t, tc.wantDestination, session.Destination)
+		})
+	}
+}
+
+func TestServeForwards(t *testing.T) {
+	t.Parallel()
+
+	// Stands in for the hosts behind the instance: replies with its name
+	backends := map[string]string{}
+	for _, name := range []string{"db", "cache"} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = listener.Close() })
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				_, _ = io.WriteString(conn, name)
+				_ = conn.Close()
+			}
+		}()
+		backends[name] = listener.Addr().String()
+	}
+
+	specs := []ForwardSpec{
+		{LocalAddr: "127.0.0.1", LocalPort: 0, RemoteHost: "db", RemotePort: 5432},
+		{LocalAddr: "127.0.0.1", LocalPort: 0, RemoteHost: "cache", RemotePort: 6379},
+	}
+	dial := func(spec ForwardSpec) (tunnel.TunnelConnection, error) {
+		return tunnel.DialTCP(backends[spec.RemoteHost])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stderrReader, stderr := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- serveForwards(ctx, specs, "i-1234567890abcdef0", dial, stderr)
+		_ = stderr.Close()
+	}()
+
+	// Each forward is announced with the port it listens on
+	lines := bufio.NewScanner(stderrReader)
+	var addrs []string
+	for _, want := range []string{"db:5432", "cache:6379"} {
+		require.True(t, lines.Scan())
+		fields := strings.Fields(lines.Text())
+		require.Len(t, fields, 6, lines.Text())
+		assert.Equal(t, []string{"forwarding", "to", want, "via", "i-1234567890abcdef0"},
+			[]string{fields[0], fields[2], fields[3], fields[4], fields[5]})
+		addrs = append(addrs, fields[1])
+	}
+	go func() { _, _ = io.Copy(io.Discard, stderrReader) }()
+
+	for i, want := range []string{"db", "cache"} {
+		conn, err := net.Dial("tcp", addrs[i])
+		require.NoError(t, err)
+		reply, err := io.ReadAll(conn)
+		_ = conn.Close()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(reply))
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServeForwards_ListenError(t *testing.T) {
+	t.Parallel()
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = taken.Close() })
+	port := taken.Addr().(*net.TCPAddr).Port
+
+	specs := []ForwardSpec{{LocalAddr: "127.0.0.1", LocalPort: port, RemoteHost: "db", RemotePort: 5432}}
+	dial := func(ForwardSpec) (tunnel.TunnelConnection, error) {
+		t.Fatal("unexpected dial")
+		return nil, nil
+	}
+
+	err = serveForwards(context.Background(), specs, "i-1234567890abcdef0", dial, io.Discard)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to forward 127.0.0.1:")
+}
+
+func TestSSMForwardDialer(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	tests := map[string]struct {
+		remoteHost string
+		wantHost   string // Empty = the instance itself, without ToRemoteHost
+	}{
+		"remote host":   {remoteHost: "db.internal", wantHost: "db.internal"},
+		"localhost":     {remoteHost: "localhost", wantHost: ""},
+		"IPv4 loopback": {remoteHost: "127.0.0.1", wantHost: ""},
+		"IPv6 loopback": {remoteHost: "::1", wantHost: ""},
+		"private IP":    {remoteHost: "10.0.0.5", wantHost: "10.0.0.5"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			origDialSSM := dialSSM
+			t.Cleanup(func() { dialSSM = origDialSSM })
+
+			var gotID, gotHost string
+			var gotPort int
+			dialSSM = func(ctx context.Context, cfg aws.Config, instanceID, host string, port int) (tunnel.TunnelConnection, error) {
+				gotID, gotHost, gotPort = instanceID, host, port
+				return nil, nil
+			}
+
+			dial := ssmForwardDialer(context.Background(), aws.Config{}, "i-1234567890abcdef0", log.New(io.Discard, "", 0))
+			_, err := dial(ForwardSpec{LocalAddr: "localhost", LocalPort: 8080, RemoteHost: tc.remoteHost, RemotePort: 80})
+			require.NoError(t, err)
+
+			assert.Equal(t, "i-1234567890abcdef0", gotID)
+			assert.Equal(t, tc.wantHost, gotHost)
+			assert.Equal(t, 80, gotPort)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+)
+
+// hostKeysTimeout bounds the RunCommand that reads host keys.
+const hostKeysTimeout = 60 * time.Second
+
+// ssmHostKeysCommand prints the instance's host public keys.
+var ssmHostKeysCommand = []string{"sh", "-c", "cat /etc/ssh/ssh_host_*_key.pub"}
+
+// knownHostsPath locates the known_hosts file for --host-keys, overridden in tests.
+var knownHostsPath = ssh.KnownHostsPath
+
+// HostKeySource is where --host-keys fetches host keys from.
+// Use a pointer to HostKeySource where nil means ssh's own known_hosts (trust on first use).
+type HostKeySource int
+
+const (
+	HostKeysAuto    HostKeySource = iota // Console output, SSM if it has none
+	HostKeysConsole                      // Keys cloud-init prints to the console
+	HostKeysSSM                          // /etc/ssh/ssh_host_*_key.pub through SSM RunCommand
+)
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+func (h *HostKeySource) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "auto":
+		*h = HostKeysAuto
+	case "console":
+		*h = HostKeysConsole
+	case "ssm":
+		*h = HostKeysSSM
+	default:
+		return fmt.Errorf("unknown host key source: %s", text)
+	}
+	return nil
+}
+
+// setupHostKeys makes ssh verify the instance against host keys from AWS
+// rather than trust on first use. Keys are fetched the first time the instance
+// is seen and recorded under its ID, the HostKeyAlias, in a dedicated
+// known_hosts file; from then on ssh checks the instance against them.
+func (s *baseSSHSession) setupHostKeys() error {
+	path, err := knownHostsPath()
+	if err != nil {
+		return err
+	}
+	instanceID := *s.instance.InstanceId
+
+	known, err := ssh.KnownHostKeys(path, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if len(known) == 0 {
+		keys, err := s.fetchHostKeys()
+		if err != nil {
+			return fmt.Errorf("unable to get host keys of %s: %w", instanceID, err)
+		}
+		if err := ssh.AddKnownHostKeys(path, instanceID, keys); err != nil {
+			return err
+		}
+		s.logger.Printf("recorded %d host keys of %s in %s", len(keys), instanceID, path)
+	} else {
+		s.logger.Printf("verifying %s against %d recorded host keys", instanceID, len(known))
+	}
+
+	s.knownHostsFile = path
+	return nil
+}
+
+// fetchHostKeys returns the instance's host public keys from the source
+// chosen by --host-keys.
+func (s *baseSSHSession) fetchHostKeys() ([]string, error) {
+	switch *s.HostKeys {
+	case HostKeysConsole:
+		return s.consoleHostKeys()
+	case HostKeysSSM:
+		return s.ssmHostKeys()
+	case HostKeysAuto:
+		keys, err := s.consoleHostKeys()
+		if err == nil {
+			return keys, nil
+		}
+		s.logger.Printf("no host keys from console output (%v), reading them through SSM", err)
+		keys, ssmErr := s.ssmHostKeys()
+		if ssmErr != nil {
+			return nil, fmt.Errorf("%w (SSM: %v)", err, ssmErr)
+		}
+		return keys, nil
+	default:
+		panic(fmt.Sprintf("unexpected HostKeySource: %d", int(*s.HostKeys)))
+	}
+}
+
+// consoleHostKeys reads the host keys cloud-init printed to the console at boot.
+func (s *baseSSHSession) consoleHostKeys() ([]string, error) {
+	output, err := s.client.GetConsoleOutput(s.instance)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get console output: %w", err)
+	}
+	keys := ssh.ConsoleHostKeys(output)
+	if len(keys) == 0 {
+		return nil, errors.New("console output has no host keys")
+	}
+	return keys, nil
+}
+
+// ssmHostKeys reads the host keys from the instance through SSM RunCommand.
+func (s *baseSSHSession) ssmHostKeys() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hostKeysTimeout)
+	defer cancel()
+
+	stdout, _, err := runSSMCommand(ctx, s.client.Config(), *s.instance.InstanceId, ssmHostKeysCommand)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read host keys through SSM: %w", err)
+	}
+	keys := ssh.ParseHostKeys(stdout)
+	if len(keys) == 0 {
+		return nil, errors.New("instance has no host keys in /etc/ssh")
+	}
+	return keys, nil
+}
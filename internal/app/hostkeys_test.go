@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestHostKeySource_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    HostKeySource
+		wantErr bool
+	}{
+		"auto":    {input: "auto", want: HostKeysAuto},
+		"console": {input: "console", want: HostKeysConsole},
+		"ssm":     {input: "ssm", want: HostKeysSSM},
+		"unknown": {input: "dns", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var source HostKeySource
+			err := source.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown host key source")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, source)
+		})
+	}
+}
+
+// testHostKey returns a fresh ed25519 host public key as "type base64".
+func testHostKey(t *testing.T) string {
+	t.Helper()
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshKey, err := gossh.NewPublicKey(publicKey)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(sshKey)))
+}
+
+func TestSSHSession_Run_HostKeys(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	hostKey := testHostKey(t)
+	consoleOutput := "ec2: -----BEGIN SSH HOST KEY KEYS-----\nec2: " + hostKey + " root@ip-10-0-0-1\nec2: -----END SSH HOST KEY KEYS-----\n"
+
+	tests := map[string]struct {
+		source      string
+		recorded    string // known_hosts content before the run
+		console     string
+		consoleErr  error
+		ssmStdout   string
+		ssmErr      error
+		wantConsole bool
+		wantSSM     bool
+		wantFile    string
+		errContains string
+	}{
+		"console": {
+			source:      "console",
+			console:     consoleOutput,
+			wantConsole: true,
+			wantFile:    "i-1234567890abcdef0 " + hostKey + "\n",
+		},
+		"ssm": {
+			source:    "ssm",
+			ssmStdout: hostKey + " root@ip-10-0-0-1\n",
+			wantSSM:   true,
+			wantFile:  "i-1234567890abcdef0 " + hostKey + "\n",
+		},
+		"auto falls back to ssm": {
+			source:      "auto",
+			console:     "Booting Linux\n",
+			ssmStdout:   hostKey + "\n",
+			wantConsole: true,
+			wantSSM:     true,
+			wantFile:    "i-1234567890abcdef0 " + hostKey + "\n",
+		},
+		"recorded keys are not fetched again": {
+			source:   "console",
+			recorded: "i-1234567890abcdef0 " + hostKey + "\n",
+			wantFile: "i-1234567890abcdef0 " + hostKey + "\n",
+		},
+		"other instances recorded": {
+			source:      "console",
+			recorded:    "i-other " + hostKey + "\n",
+			console:     consoleOutput,
+			wantConsole: true,
+			wantFile:    "i-other " + hostKey + "\ni-1234567890abcdef0 " + hostKey + "\n",
+		},
+		"no keys in console": {
+			source:      "console",
+			console:     "Booting Linux\n",
+			wantConsole: true,
+			errContains: "console output has no host keys",
+		},
+		"console error": {
+			source:      "console",
+			consoleErr:  errors.New("UnauthorizedOperation"),
+			wantConsole: true,
+			errContains: "UnauthorizedOperation",
+		},
+		"auto fails on both": {
+			source:      "auto",
+			console:     "Booting Linux\n",
+			ssmErr:      errors.New("InvalidInstanceId"),
+			wantConsole: true,
+			wantSSM:     true,
+			errContains: "console output has no host keys (SSM: unable to read host keys through SSM: InvalidInstanceId)",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var captured commandCapture
+			ec2Mock, _ := setupMocksForRun(t, testInstance, &captured)
+			ec2Mock.On("GetConsoleOutput", mock.Anything, mock.Anything).Return(
+				&ec2.GetConsoleOutputOutput{Output: aws.String(base64.StdEncoding.EncodeToString([]byte(tc.console)))},
+				tc.consoleErr,
+			)
+
+			path := filepath.Join(t.TempDir(), "ec2ssh_known_hosts")
+			if tc.recorded != "" {
+				require.NoError(t, os.WriteFile(path, []byte(tc.recorded), 0o600))
+			}
+
+			origKnownHostsPath, origRunSSMCommand := knownHostsPath, runSSMCommand
+			t.Cleanup(func() { knownHostsPath, runSSMCommand = origKnownHostsPath, origRunSSMCommand })
+			knownHostsPath = func() (string, error) { return path, nil }
+			ssmCalls := 0
+			runSSMCommand = func(ctx context.Context, cfg aws.Config, instanceID string, args []string) (string, string, error) {
+				ssmCalls++
+				assert.Equal(t, ssmHostKeysCommand, args)
+				return tc.ssmStdout, "", tc.ssmErr
+			}
+
+			session, err := NewSSHSession([]string{"--host-keys", tc.source, "i-1234567890abcdef0"})
+			require.NoError(t, err)
+
+			err = session.Run()
+
+			if tc.wantConsole {
+				ec2Mock.AssertCalled(t, "GetConsoleOutput", mock.Anything, mock.Anything)
+			} else {
+				ec2Mock.AssertNotCalled(t, "GetConsoleOutput", mock.Anything, mock.Anything)
+			}
+			assert.Equal(t, tc.wantSSM, ssmCalls > 0)
+
+			if tc.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				assert.Empty(t, captured.command, "ssh should not run")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Contains(t, captured.args, "-oUserKnownHostsFile="+path)
+			assert.Contains(t, captured.args, "-oStrictHostKeyChecking=yes")
+			assert.Contains(t, captured.args, "-oHostKeyAlias=i-1234567890abcdef0")
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFile, string(content))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+)
+
+// baseInstanceSession contains common fields for sessions that relay
+// connections through an instance without ssh (--forward, --eice-listen and
+// --socks).
+type baseInstanceSession struct {
+	// CLI flags (parsed by argsieve)
+	Region      string              `long:"region"`
+	Regions     *ec2client.Regions  `long:"regions"` // nil = Region only
+	Profile     string              `long:"profile"`
+	Profiles    string              `long:"profiles"` // Glob over configured profiles
+	AllProfiles bool                `long:"all-profiles"`
+	DstType     *ec2client.DstType  `long:"destination-type"` // nil = auto-detect
+	Select      *ec2client.Selector `long:"select"`           // nil = multiple matches are an error
+	Debug       bool                `long:"debug"`
+
+	// Parsed values
+	Destination string
+
+	// Runtime state
+	logger *log.Logger
+}
+
+// resolveTunnelInstance initializes the debug logger and finds the instance,
+// returning it with the client (region and profile) that found it.
+func (s *baseInstanceSession) resolveTunnelInstance() (*ec2client.Client, types.Instance, error) {
+	s.logger = log.New(io.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	if s.Debug {
+		s.logger.SetOutput(os.Stderr)
+	}
+
+	// Missing destination is only allowed when it can be picked interactively
+	if s.Destination == "" && !isInteractive() {
+		return nil, types.Instance{}, fmt.Errorf("%w: missing destination", ErrUsage)
+	}
+
+	clients, err := newSearchClients(s.Region, s.Profile, profilePattern(s.Profiles, s.AllProfiles), s.Regions, s.logger)
+	if err != nil {
+		return nil, types.Instance{}, err
+	}
+
+	// Get instance (empty destination opens the picker)
+	client, instance, err := resolveInstance(clients, s.Destination, s.DstType, s.Select)
+	if err != nil {
+		return nil, types.Instance{}, err
+	}
+
+	if instance.InstanceId == nil {
+		panic("ec2ssh: AWS returned instance without InstanceId - this should never happen")
+	}
+
+	return client, instance, nil
+}
+
+// findEICEID returns explicitID, the --eice-id, or else finds an endpoint in
+// the instance's VPC.
+func findEICEID(client *ec2client.Client, instance types.Instance, explicitID string) (string, error) {
+	if explicitID != "" {
+		return explicitID, nil
+	}
+
+	if instance.VpcId == nil || instance.SubnetId == nil {
+		return "", fmt.Errorf("unable to find EICE endpoint: instance %s is not in a VPC", *instance.InstanceId)
+	}
+	eice, err := client.GuessEICEByVPCAndSubnet(*instance.VpcId, *instance.SubnetId)
+	if err != nil {
+		return "", fmt.Errorf("unable to find EICE endpoint: %w", err)
+	}
+
+	return *eice.InstanceConnectEndpointId, nil
+}
+
+// dialSSMHost starts an SSM port forwarding session through instanceID to
+// host:port. Loopback hosts are the instance itself, so they use the plain
+// port forwarding document, which older SSM agents support too.
+func dialSSMHost(ctx context.Context, cfg aws.Config, instanceID, host string, port int) (tunnel.TunnelConnection, error) {
+	if isLoopbackHost(host) {
+		host = ""
+	}
+	return dialSSM(ctx, cfg, instanceID, host, port)
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback address.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
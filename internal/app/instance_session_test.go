@@ -0,0 +1,50 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindEICEID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		explicitID string
+		noVPC      bool
+		want       string
+		wantErr    string
+	}{
+		"explicit":        {explicitID: "eice-cli", want: "eice-cli"},
+		"explicit no VPC": {explicitID: "eice-cli", noVPC: true, want: "eice-cli"},
+		"guessed":         {want: "eice-123"},
+		"not in a VPC":    {noVPC: true, wantErr: "unable to find EICE endpoint: instance i-1234567890abcdef0 is not in a VPC"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ec2Mock := new(mockEC2API)
+			ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, mock.Anything).Return(
+				ec2client.MakeEICEOutput(ec2client.MakeEICE("eice-123", "vpc-123", "subnet-456", "eice.example.com")), nil)
+			client := ec2client.NewTestClient(ec2Mock, new(mockEC2InstanceConnectAPI), new(mockHTTPRequestSigner))
+
+			instance := testInstance
+			if tc.noVPC {
+				instance.VpcId = nil
+			}
+
+			got, err := findEICEID(client, instance, tc.explicitID)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
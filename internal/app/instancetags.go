@@ -0,0 +1,93 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// Instance tags through which infrastructure owners declare how an instance
+// is reached. Command-line options always win over them.
+const (
+	tagUser        = "ec2ssh:user"         // Login, like -l
+	tagPort        = "ec2ssh:port"         // SSH port, like -p
+	tagTransport   = "ec2ssh:transport"    // Like --transport: direct, eice, ssm, a list or auto
+	tagAddressType = "ec2ssh:address-type" // Like --address-type
+	tagEICEID      = "ec2ssh:eice-id"      // EICE endpoint, used when the transport is eice
+	tagKeyType     = "ec2ssh:key-type"     // Like --key-type, for generated keys
+)
+
+// instanceTag returns the value of one of the instance's ec2ssh: tags, and
+// whether it is set and not empty.
+func (s *baseSSHSession) instanceTag(key string) (string, bool) {
+	value := aws.ToString(ec2client.GetInstanceTag(s.instance, key))
+	return value, value != ""
+}
+
+// instancePortTag returns the instance's ec2ssh:port tag for generated
+// configs, empty if unset or not a port number.
+func instancePortTag(instance types.Instance) string {
+	value := aws.ToString(ec2client.GetInstanceTag(instance, tagPort))
+	if !validPort(value) {
+		return ""
+	}
+	return value
+}
+
+// validPort reports whether value is a TCP port number.
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+// invalidTagError reports an ec2ssh: tag whose value cannot be used.
+func (s *baseSSHSession) invalidTagError(key, value string, err error) error {
+	return fmt.Errorf("invalid tag %s=%s on instance %s: %w", key, value, *s.instance.InstanceId, err)
+}
+
+// applyInstanceTags fills in the connection settings not given on the command
+// line from the instance's ec2ssh: tags. Called once the instance is resolved.
+// Unlike --eice-id, the eice-id tag does not imply the EICE transport.
+func (s *baseSSHSession) applyInstanceTags() error {
+	if value, ok := s.instanceTag(tagUser); ok && s.Target.Login() == "" && s.loginFlag == "" {
+		s.logger.Printf("using tag %s=%s", tagUser, value)
+		s.defaultUser = value
+	}
+
+	if value, ok := s.instanceTag(tagPort); ok && (s.cliPort() == "" || s.defaultPort) {
+		if !validPort(value) {
+			return s.invalidTagError(tagPort, value, errors.New("not a port number"))
+		}
+		s.logger.Printf("using tag %s=%s", tagPort, value)
+		s.tagPort = value
+	}
+
+	if value, ok := s.instanceTag(tagTransport); ok && s.Transport == nil && !s.UseEICE && !s.UseSSM {
+		var transports Transports
+		if err := transports.UnmarshalText([]byte(value)); err != nil {
+			return s.invalidTagError(tagTransport, value, err)
+		}
+		s.logger.Printf("using tag %s=%s", tagTransport, value)
+		s.Transport = &transports
+	}
+
+	if value, ok := s.instanceTag(tagAddressType); ok && s.AddrType == nil {
+		var addrType ec2client.AddrType
+		if err := addrType.UnmarshalText([]byte(value)); err != nil {
+			return s.invalidTagError(tagAddressType, value, err)
+		}
+		s.logger.Printf("using tag %s=%s", tagAddressType, value)
+		s.AddrType = &addrType
+	}
+
+	if value, ok := s.instanceTag(tagEICEID); ok && s.EICEID == "" {
+		s.logger.Printf("using tag %s=%s", tagEICEID, value)
+		s.EICEID = value
+	}
+
+	return nil
+}
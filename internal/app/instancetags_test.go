@@ -0,0 +1,179 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHSession_Run_InstanceTags(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	tests := map[string]struct {
+		tags        map[string]string
+		args        []string
+		wantArgs    []string
+		notArgs     []string
+		wantProxy   string // Substring of the ProxyCommand, empty = no proxy
+		wantDest    string
+		wantLogin   string
+		wantKeyType string
+		wantErr     string
+	}{
+		"user tag": {
+			tags:      map[string]string{"ec2ssh:user": "admin"},
+			wantArgs:  []string{"-oUser=admin"},
+			wantLogin: "admin",
+		},
+		"-l wins over user tag": {
+			tags:      map[string]string{"ec2ssh:user": "admin"},
+			args:      []string{"-l", "root"},
+			notArgs:   []string{"-oUser=admin"},
+			wantLogin: "root",
+		},
+		"port tag": {
+			tags:     map[string]string{"ec2ssh:port": "2222"},
+			wantArgs: []string{"-oPort=2222"},
+		},
+		"-p wins over port tag": {
+			tags:    map[string]string{"ec2ssh:port": "2222"},
+			args:    []string{"-p", "2200"},
+			notArgs: []string{"-oPort=2222"},
+		},
+		"transport and eice-id tags": {
+			tags:      map[string]string{"ec2ssh:transport": "eice", "ec2ssh:eice-id": "eice-tag"},
+			wantProxy: "--eice-id eice-tag",
+			wantDest:  "i-1234567890abcdef0",
+		},
+		"--use-ssm wins over transport tag": {
+			tags:      map[string]string{"ec2ssh:transport": "eice"},
+			args:      []string{"--use-ssm"},
+			wantProxy: "--ssm-tunnel",
+			wantDest:  "i-1234567890abcdef0",
+		},
+		"eice-id tag alone stays direct": {
+			tags:     map[string]string{"ec2ssh:eice-id": "eice-tag"},
+			wantDest: "52.1.2.3",
+		},
+		"address-type tag": {
+			tags:     map[string]string{"ec2ssh:address-type": "private"},
+			wantDest: "10.0.0.1",
+		},
+		"--address-type wins over tag": {
+			tags:     map[string]string{"ec2ssh:address-type": "private"},
+			args:     []string{"--address-type", "public"},
+			wantDest: "52.1.2.3",
+		},
+		"key-type tag": {
+			tags:        map[string]string{"ec2ssh:key-type": "rsa:4096"},
+			wantKeyType: "rsa:4096",
+		},
+		"--key-type wins over tag": {
+			tags:        map[string]string{"ec2ssh:key-type": "rsa:4096"},
+			args:        []string{"--key-type", "rsa"},
+			wantKeyType: "rsa:2048",
+		},
+		"empty tag ignored": {
+			tags:     map[string]string{"ec2ssh:port": ""},
+			wantDest: "52.1.2.3",
+			notArgs:  []string{"-oPort="},
+		},
+		"invalid port tag": {
+			tags:    map[string]string{"ec2ssh:port": "ssh"},
+			wantErr: "invalid tag ec2ssh:port=ssh on instance i-1234567890abcdef0: not a port number",
+		},
+		"invalid transport tag": {
+			tags:    map[string]string{"ec2ssh:transport": "vpn"},
+			wantErr: "invalid tag ec2ssh:transport=vpn on instance i-1234567890abcdef0: unknown transport: vpn",
+		},
+		"key-type tag refused by Instance Connect": {
+			tags:    map[string]string{"ec2ssh:key-type": "ecdsa"},
+			wantErr: "EC2 Instance Connect does not accept ecdsa keys",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			instance := testInstance
+			instance.Tags = nil
+			for key, value := range tc.tags {
+				instance.Tags = append(instance.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+			}
+
+			var captured commandCapture
+			_, connectMock := setupMocksForRun(t, instance, &captured)
+
+			var keyType ssh.KeyType
+			generateKeypair = func(tmpDir string, kt ssh.KeyType) (string, string, error) {
+				keyType = kt
+				return "/tmp/test_key", "ssh-ed25519 AAAAC3NzaC1... test@host", nil
+			}
+
+			session, err := NewSSHSession(append(tc.args, "i-1234567890abcdef0"))
+			require.NoError(t, err)
+
+			err = session.Run()
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, arg := range tc.wantArgs {
+				assert.Contains(t, captured.args, arg)
+			}
+			for _, arg := range tc.notArgs {
+				assert.NotContains(t, captured.args, arg)
+			}
+
+			var proxy string
+			for _, arg := range captured.args {
+				if value, ok := strings.CutPrefix(arg, "-oProxyCommand="); ok {
+					proxy = value
+				}
+			}
+			if tc.wantProxy != "" {
+				assert.Contains(t, proxy, tc.wantProxy)
+			} else {
+				assert.Empty(t, proxy)
+			}
+
+			if tc.wantDest != "" {
+				assert.Equal(t, tc.wantDest, captured.args[len(captured.args)-1])
+			}
+			if tc.wantLogin != "" {
+				connectMock.AssertCalled(t, "SendSSHPublicKey", mock.Anything, mock.MatchedBy(func(input *ec2instanceconnect.SendSSHPublicKeyInput) bool {
+					return *input.InstanceOSUser == tc.wantLogin
+				}))
+			}
+			if tc.wantKeyType != "" {
+				assert.Equal(t, tc.wantKeyType, keyType.String())
+			}
+		})
+	}
+}
+
+func TestSSHSession_Run_InstanceTagsNoSendKeys(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+	instance := testInstance
+	instance.Tags = []types.Tag{{Key: aws.String("ec2ssh:user"), Value: aws.String("admin")}}
+
+	var captured commandCapture
+	_, connectMock := setupMocksForRun(t, instance, &captured)
+
+	session, err := NewSSHSession([]string{"--no-send-keys", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+	require.NoError(t, session.Run())
+
+	// The tag still chooses the login ssh uses
+	assert.Contains(t, captured.args, "-oUser=admin")
+	connectMock.AssertNotCalled(t, "SendSSHPublicKey", mock.Anything, mock.Anything)
+}
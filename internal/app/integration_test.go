@@ -3,13 +3,19 @@ package app
 import (
 	"errors"
 	"log"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
 	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/picker"
+	"github.com/ivoronin/ec2ssh/internal/ssh"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -53,6 +59,9 @@ func setupMocksForRun(t *testing.T, instance types.Instance, captureCmd *command
 	origNewEC2Client := newEC2Client
 	origGenerateKeypair := generateKeypair
 	origExecuteCommand := executeCommand
+	origIsInteractive := isInteractive
+	origPickInstance := pickInstance
+	origImageLoginCachePath := imageLoginCachePath
 
 	// Cleanup
 	t.Cleanup(func() {
@@ -60,8 +69,22 @@ func setupMocksForRun(t *testing.T, instance types.Instance, captureCmd *command
 		newEC2Client = origNewEC2Client
 		generateKeypair = origGenerateKeypair
 		executeCommand = origExecuteCommand
+		isInteractive = origIsInteractive
+		pickInstance = origPickInstance
+		imageLoginCachePath = origImageLoginCachePath
 	})
 
+	// Image logins are cached per test, not in the user's cache directory
+	cachePath := filepath.Join(t.TempDir(), "image-logins.json")
+	imageLoginCachePath = func() (string, error) { return cachePath, nil }
+
+	// Not a terminal unless a test opts in; the picker must not be reached by default
+	isInteractive = func() bool { return false }
+	pickInstance = func(instances []ec2client.RegionalInstance) (ec2client.RegionalInstance, error) {
+		t.Fatal("unexpected interactive picker")
+		return ec2client.RegionalInstance{}, nil
+	}
+
 	// Mock AWS config loading
 	loadAWSConfig = func(region, profile string, logger *log.Logger) (aws.Config, error) {
 		return aws.Config{Region: "us-east-1"}, nil
@@ -89,7 +112,7 @@ func setupMocksForRun(t *testing.T, instance types.Instance, captureCmd *command
 	}
 
 	// Mock keypair generation
-	generateKeypair = func(tmpDir string) (string, string, error) {
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
 		return "/tmp/test_key", "ssh-ed25519 AAAAC3NzaC1... test@host", nil
 	}
 
@@ -218,6 +241,87 @@ func TestSSHSession_Run_WithEICEProxy(t *testing.T) {
 	assert.True(t, foundProxy, "ProxyCommand should be set for EICE")
 }
 
+func TestSSHSession_Run_TransportAutoFallsBackToSSM(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	ec2Mock, _ := setupMocksForRun(t, testInstance, &captured)
+
+	origProbeTCP := probeTCP
+	origGetSSMPingStatuses := getSSMPingStatuses
+	t.Cleanup(func() {
+		probeTCP = origProbeTCP
+		getSSMPingStatuses = origGetSSMPingStatuses
+	})
+
+	var probed string
+	probeTCP = func(addr string, timeout time.Duration) error {
+		probed = addr
+		return errors.New("i/o timeout")
+	}
+	getSSMPingStatuses = func(cfg aws.Config, instanceIDs []string) (map[string]string, error) {
+		return map[string]string{"i-1234567890abcdef0": "Online"}, nil
+	}
+	ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, mock.Anything).Return(
+		ec2client.MakeEICEOutput(), nil)
+
+	session, err := NewSSHSession([]string{"--transport", "auto", "-p", "2222", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, "52.1.2.3:2222", probed)
+	proxy := slices.IndexFunc(captured.args, func(arg string) bool { return strings.HasPrefix(arg, "-oProxyCommand=") })
+	require.NotEqual(t, -1, proxy, "ProxyCommand should be set for SSM")
+	assert.Contains(t, captured.args[proxy], "--ssm-tunnel --instance-id i-1234567890abcdef0 --port %p")
+	assert.Equal(t, "i-1234567890abcdef0", captured.args[len(captured.args)-1])
+}
+
+func TestSSHSession_Run_TransportAutoDirect(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForRun(t, testInstance, &captured)
+
+	origProbeTCP := probeTCP
+	t.Cleanup(func() { probeTCP = origProbeTCP })
+	probeTCP = func(addr string, timeout time.Duration) error { return nil }
+
+	session, err := NewSSHSession([]string{"--transport", "auto", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	for _, arg := range captured.args {
+		assert.NotContains(t, arg, "-oProxyCommand=")
+	}
+	assert.Equal(t, "52.1.2.3", captured.args[len(captured.args)-1])
+}
+
+func TestSSHSession_Run_WithKeyType(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForRun(t, testInstance, &captured)
+
+	var gotKeyType ssh.KeyType
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
+		gotKeyType = keyType
+		return "/tmp/test_key", "ssh-rsa AAAAB3Nza... test@host", nil
+	}
+
+	session, err := NewSSHSession([]string{"--key-type", "rsa:4096", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, ssh.KeyType{Algorithm: ssh.KeyRSA, Bits: 4096}, gotKeyType)
+	assert.Contains(t, captured.args, "-i/tmp/test_key")
+}
+
 func TestSSHSession_Run_WithNoSendKeys(t *testing.T) {
 	// No t.Parallel() - modifies global DI vars
 
@@ -395,6 +499,388 @@ func TestSSHSession_Run_InstanceNotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), "unable to get instance")
 }
 
+// setupMocksForAmbiguousRun sets up DI mocks where the destination matches two web servers.
+func setupMocksForAmbiguousRun(t *testing.T, captureCmd *commandCapture) {
+	t.Helper()
+
+	older := testInstance
+	older.InstanceId = aws.String("i-older")
+	older.PublicIpAddress = aws.String("52.1.1.1")
+	older.LaunchTime = aws.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	newer := testInstance
+	newer.InstanceId = aws.String("i-newer")
+	newer.PublicIpAddress = aws.String("52.2.2.2")
+	newer.LaunchTime = aws.Time(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	ec2Mock, _ := setupMocksForRun(t, testInstance, captureCmd)
+	ec2Mock.ExpectedCalls = nil
+	ec2Mock.On("DescribeInstances", mock.Anything, mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{{Instances: []types.Instance{newer, older}}},
+		}, nil,
+	)
+}
+
+func TestSSHSession_Run_AmbiguousDestination(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForAmbiguousRun(t, &captured)
+
+	session, err := NewSSHSession([]string{"web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.ErrorIs(t, err, ec2client.ErrAmbiguous)
+	assert.Contains(t, err.Error(), "i-older")
+	assert.Contains(t, err.Error(), "i-newer")
+	assert.Empty(t, captured.command, "ssh should not run")
+}
+
+func TestSSHSession_Run_SelectNewest(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForAmbiguousRun(t, &captured)
+
+	session, err := NewSSHSession([]string{"--select", "newest", "web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+	assert.Contains(t, captured.args, "-oHostKeyAlias=i-newer")
+	assert.Equal(t, "52.2.2.2", captured.args[len(captured.args)-1])
+}
+
+func TestSCPSession_Run_DestinationIndex(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForAmbiguousRun(t, &captured)
+
+	session, err := NewSCPSession([]string{"./file", "web#1:/tmp/"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+	assert.Contains(t, captured.args, "-oHostKeyAlias=i-older")
+	assert.Equal(t, "52.1.1.1:/tmp/", captured.args[len(captured.args)-1])
+}
+
+// setupPicker makes the session interactive and picks the candidate with the given ID.
+// The candidates passed to the picker are recorded in seen.
+func setupPicker(t *testing.T, pickID string, seen *[]string) {
+	t.Helper()
+
+	isInteractive = func() bool { return true }
+	pickInstance = func(instances []ec2client.RegionalInstance) (ec2client.RegionalInstance, error) {
+		for _, instance := range instances {
+			*seen = append(*seen, *instance.InstanceId)
+		}
+		for _, instance := range instances {
+			if *instance.InstanceId == pickID {
+				return instance, nil
+			}
+		}
+		return ec2client.RegionalInstance{}, picker.ErrCancelled
+	}
+}
+
+func TestSSHSession_Run_AmbiguousDestinationPicker(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForAmbiguousRun(t, &captured)
+
+	var seen []string
+	setupPicker(t, "i-newer", &seen)
+
+	session, err := NewSSHSession([]string{"web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-older", "i-newer"}, seen, "candidates in launch order")
+	assert.Contains(t, captured.args, "-oHostKeyAlias=i-newer")
+}
+
+func TestSSHSession_Run_NoDestinationPicker(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	ec2Mock, _ := setupMocksForRun(t, testInstance, &captured)
+	ec2Mock.ExpectedCalls = nil
+	// Only running instances are requested from the API
+	ec2Mock.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return len(input.Filters) == 1 && *input.Filters[0].Name == "instance-state-name" &&
+			slices.Equal(input.Filters[0].Values, []string{"running"})
+	})).Return(
+		&ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{{Instances: []types.Instance{testInstance}}},
+		}, nil,
+	)
+
+	var seen []string
+	setupPicker(t, "i-1234567890abcdef0", &seen)
+
+	session, err := NewSSHSession([]string{"-l", "ec2-user"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-1234567890abcdef0"}, seen, "only running instances are offered")
+	assert.Equal(t, "ssh", captured.command)
+	assert.Contains(t, captured.args, "-oHostKeyAlias=i-1234567890abcdef0")
+	assert.Contains(t, captured.args, "-lec2-user")
+	assert.Equal(t, "52.1.2.3", captured.args[len(captured.args)-1])
+}
+
+func TestSFTPSession_Run_NoDestinationPicker(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForRun(t, testInstance, &captured)
+
+	var seen []string
+	setupPicker(t, "i-1234567890abcdef0", &seen)
+
+	session, err := NewSFTPSession([]string{})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+	assert.Equal(t, "sftp", captured.command)
+	assert.Equal(t, "52.1.2.3", captured.args[len(captured.args)-1])
+}
+
+func TestSSHSession_Run_NoDestinationPassthroughArgs(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	ec2Mock, _ := setupMocksForRun(t, testInstance, &captured)
+	isInteractive = func() bool { return true }
+
+	session, err := NewSSHSession([]string{"-V"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-V"}, captured.args, "ssh options without destination stay passthrough")
+	ec2Mock.AssertNotCalled(t, "DescribeInstances", mock.Anything, mock.Anything)
+}
+
+func TestSSHSession_Run_PickerCancelled(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMocksForAmbiguousRun(t, &captured)
+
+	var seen []string
+	setupPicker(t, "i-none", &seen)
+
+	session, err := NewSSHSession([]string{"web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.ErrorIs(t, err, picker.ErrCancelled)
+	assert.Empty(t, captured.command, "ssh should not run")
+}
+
+func TestSSMSession_Run_MissingDestinationNotInteractive(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	setupMocksForRun(t, testInstance, nil)
+
+	session, err := NewSSMSession([]string{})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.ErrorIs(t, err, ErrUsage)
+	assert.Contains(t, err.Error(), "missing destination")
+}
+
+// setupMultiRegionMocks replaces the EC2 client factory with one mock per region.
+// Only eu-west-1 has the test instance; the returned connect mock belongs to it.
+func setupMultiRegionMocks(t *testing.T, captureCmd *commandCapture) (*mockEC2API, *mockEC2InstanceConnectAPI) {
+	t.Helper()
+
+	setupMocksForRun(t, testInstance, captureCmd)
+
+	usMock := new(mockEC2API)
+	usMock.On("DescribeInstances", mock.Anything, mock.Anything).Return(ec2client.MakeDescribeOutput(), nil)
+	usMock.On("DescribeRegions", mock.Anything, mock.Anything).Return(
+		ec2client.MakeDescribeRegionsOutput("us-east-1", "eu-west-1"), nil,
+	)
+
+	euMock := new(mockEC2API)
+	euMock.On("DescribeInstances", mock.Anything, mock.Anything).Return(
+		ec2client.MakeDescribeOutput(ec2client.MakeReservation(testInstance)), nil,
+	)
+	euConnect := new(mockEC2InstanceConnectAPI)
+	euConnect.On("SendSSHPublicKey", mock.Anything, mock.Anything).Return(
+		&ec2instanceconnect.SendSSHPublicKeyOutput{Success: true}, nil,
+	)
+
+	newEC2Client = func(cfg aws.Config, logger *log.Logger) (*ec2client.Client, error) {
+		switch cfg.Region {
+		case "us-east-1":
+			return ec2client.NewTestClientInRegion(cfg.Region, usMock, new(mockEC2InstanceConnectAPI), nil), nil
+		case "eu-west-1":
+			return ec2client.NewTestClientInRegion(cfg.Region, euMock, euConnect, nil), nil
+		default:
+			return nil, errors.New("unexpected region " + cfg.Region)
+		}
+	}
+
+	return usMock, euConnect
+}
+
+func TestSSHSession_Run_RegionsCarryIntoProxyCommand(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	_, euConnect := setupMultiRegionMocks(t, &captured)
+
+	session, err := NewSSHSession([]string{"--use-ssm", "--regions", "us-east-1,eu-west-1", "web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	euConnect.AssertExpectations(t)
+
+	foundProxy := false
+	for _, arg := range captured.args {
+		if strings.HasPrefix(arg, "-oProxyCommand=") {
+			assert.Contains(t, arg, "--ssm-tunnel")
+			assert.Contains(t, arg, "--region eu-west-1")
+			foundProxy = true
+		}
+	}
+	assert.True(t, foundProxy, "ProxyCommand should be set for SSM")
+}
+
+func TestSSHSession_Run_AllRegions(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	usMock, euConnect := setupMultiRegionMocks(t, &captured)
+
+	session, err := NewSSHSession([]string{"--regions", "all", "web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	usMock.AssertCalled(t, "DescribeRegions", mock.Anything, mock.Anything)
+	euConnect.AssertExpectations(t)
+	assert.Contains(t, captured.args, "-oHostKeyAlias=i-1234567890abcdef0")
+}
+
+// setupMultiProfileMocks layers profiles over setupMultiRegionMocks: prod-a
+// lives in us-east-1 (no instances) and prod-b in eu-west-1 (test instance).
+// dev is configured but must never be loaded by the prod-* tests.
+func setupMultiProfileMocks(t *testing.T, captureCmd *commandCapture) *mockEC2InstanceConnectAPI {
+	t.Helper()
+
+	_, euConnect := setupMultiRegionMocks(t, captureCmd)
+
+	origListProfiles := listProfiles
+	t.Cleanup(func() { listProfiles = origListProfiles })
+
+	listProfiles = func() ([]string, error) {
+		return []string{"dev", "prod-a", "prod-b"}, nil
+	}
+
+	loadAWSConfig = func(region, profile string, logger *log.Logger) (aws.Config, error) {
+		switch profile {
+		case "prod-a":
+			return aws.Config{Region: "us-east-1"}, nil
+		case "prod-b":
+			return aws.Config{Region: "eu-west-1"}, nil
+		default:
+			return aws.Config{}, errors.New("unexpected profile " + profile)
+		}
+	}
+
+	return euConnect
+}
+
+func TestSSHSession_Run_ProfilesCarryIntoProxyCommand(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	euConnect := setupMultiProfileMocks(t, &captured)
+
+	session, err := NewSSHSession([]string{"--use-ssm", "--profiles", "prod-*", "web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	// Key is pushed with the owning profile's client
+	euConnect.AssertExpectations(t)
+
+	foundProxy := false
+	for _, arg := range captured.args {
+		if strings.HasPrefix(arg, "-oProxyCommand=") {
+			assert.Contains(t, arg, "--profile prod-b")
+			foundProxy = true
+		}
+	}
+	assert.True(t, foundProxy, "ProxyCommand should be set for SSM")
+}
+
+func TestSSHSession_Run_ProfilesNoMatch(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMultiProfileMocks(t, &captured)
+
+	session, err := NewSSHSession([]string{"--profiles", "staging-*", "web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no profiles match staging-*")
+}
+
+func TestSSHSession_Run_AllProfilesSkipsLoadError(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMultiProfileMocks(t, &captured)
+	warnings := captureWarnings(t)
+
+	session, err := NewSSHSession([]string{"--use-ssm", "--all-profiles", "web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+	assert.Contains(t, warnings.String(), "warning: skipping profile dev: unexpected profile dev")
+	assert.Contains(t, captured.args, "-oHostKeyAlias=i-1234567890abcdef0")
+}
+
+func TestSSHSession_Run_AllProfilesLoadError(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured commandCapture
+	setupMultiProfileMocks(t, &captured)
+
+	loadAWSConfig = func(region, profile string, logger *log.Logger) (aws.Config, error) {
+		return aws.Config{}, errors.New("expired SSO token for " + profile)
+	}
+
+	session, err := NewSSHSession([]string{"--all-profiles", "web"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile dev: expired SSO token for dev")
+	assert.Contains(t, err.Error(), "profile prod-b: expired SSO token for prod-b")
+}
+
 func TestSSHSession_Run_KeygenError(t *testing.T) {
 	// No t.Parallel() - modifies global DI vars
 
@@ -423,7 +909,7 @@ func TestSSHSession_Run_KeygenError(t *testing.T) {
 	}
 
 	expectedErr := errors.New("ssh-keygen not found")
-	generateKeypair = func(tmpDir string) (string, string, error) {
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
 		return "", "", expectedErr
 	}
 
@@ -470,7 +956,7 @@ func TestSSHSession_Run_SendKeysError(t *testing.T) {
 		return ec2client.NewTestClient(ec2Mock, connectMock, new(mockHTTPRequestSigner)), nil
 	}
 
-	generateKeypair = func(tmpDir string) (string, string, error) {
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
 		return "/tmp/key", "ssh-ed25519 AAAA...", nil
 	}
 
@@ -516,7 +1002,7 @@ func TestSSHSession_Run_CommandError(t *testing.T) {
 		return ec2client.NewTestClient(ec2Mock, connectMock, new(mockHTTPRequestSigner)), nil
 	}
 
-	generateKeypair = func(tmpDir string) (string, string, error) {
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
 		return "/tmp/key", "ssh-ed25519 AAAA...", nil
 	}
 
@@ -818,7 +1304,7 @@ func TestSSHSession_Run_EICEAutoDiscovery(t *testing.T) {
 		return ec2client.NewTestClient(ec2Mock, connectMock, new(mockHTTPRequestSigner)), nil
 	}
 
-	generateKeypair = func(tmpDir string) (string, string, error) {
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
 		return "/tmp/test_key", "ssh-ed25519 AAAAC3NzaC1... test@host", nil
 	}
 
@@ -899,7 +1385,7 @@ func TestSSHSession_Run_EICEAutoDiscoveryError(t *testing.T) {
 		return ec2client.NewTestClient(ec2Mock, connectMock, new(mockHTTPRequestSigner)), nil
 	}
 
-	generateKeypair = func(tmpDir string) (string, string, error) {
+	generateKeypair = func(tmpDir string, keyType ssh.KeyType) (string, string, error) {
 		return "/tmp/test_key", "ssh-ed25519 AAAAC3NzaC1... test@host", nil
 	}
 
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ivoronin/argsieve"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// InventoryOptions holds the parsed configuration for --inventory, an Ansible
+// dynamic inventory. Ansible runs it with --list, or --host <name>.
+type InventoryOptions struct {
+	ExportOptions
+	List bool   `long:"list"`
+	Host string `long:"host"` // Inventory hostname, empty = --list
+}
+
+// NewInventoryOptions creates InventoryOptions from command-line arguments.
+func NewInventoryOptions(args []string) (*InventoryOptions, error) {
+	var options InventoryOptions
+
+	positional, err := argsieve.Parse(&options, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %s", ErrUsage, positional[0])
+	}
+
+	if options.List && options.Host != "" {
+		return nil, fmt.Errorf("%w: --list and --host are mutually exclusive", ErrUsage)
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &options, nil
+}
+
+// RunInventory executes the inventory intent with the given arguments.
+func RunInventory(args []string) error {
+	options, err := NewInventoryOptions(args)
+	if err != nil {
+		return err
+	}
+
+	instances, logins, path, err := listExportInstances(&options.ExportOptions)
+	if err != nil {
+		return err
+	}
+
+	inventory := buildInventory(instances, &options.ExportOptions, path, logins)
+
+	if options.Host != "" {
+		return writeInventoryJSON(os.Stdout, inventory.hostVars(options.Host))
+	}
+	return writeInventoryJSON(os.Stdout, inventory.list())
+}
+
+// inventory is an Ansible inventory: hosts named like the Host aliases of
+// --export-ssh-config, their variables, and groups of host names.
+type inventory struct {
+	hostvars map[string]map[string]any
+	groups   map[string][]string
+}
+
+// buildInventory groups instances by tag, AZ, VPC and instance type.
+// Name tags become host names rather than groups.
+func buildInventory(instances []ec2client.RegionalInstance, options *ExportOptions, path string, logins loginMap) inventory {
+	aliases := hostAliases(instances)
+	inv := inventory{
+		hostvars: make(map[string]map[string]any, len(instances)),
+		groups:   make(map[string][]string),
+	}
+
+	for _, instance := range instances {
+		host := aliases[aws.ToString(instance.InstanceId)]
+		inv.hostvars[host] = inventoryHostVars(instance, options, path, logins)
+
+		for _, tag := range instance.Tags {
+			if key := aws.ToString(tag.Key); key != "Name" {
+				inv.addHost(inventoryGroupName("tag", key, aws.ToString(tag.Value)), host)
+			}
+		}
+		if instance.Placement != nil && instance.Placement.AvailabilityZone != nil {
+			inv.addHost(inventoryGroupName("az", *instance.Placement.AvailabilityZone), host)
+		}
+		if instance.VpcId != nil {
+			inv.addHost(inventoryGroupName("vpc", *instance.VpcId), host)
+		}
+		if instance.InstanceType != "" {
+			inv.addHost(inventoryGroupName("type", string(instance.InstanceType)), host)
+		}
+	}
+
+	for _, hosts := range inv.groups {
+		slices.Sort(hosts)
+	}
+
+	return inv
+}
+
+func (inv inventory) addHost(group, host string) {
+	if !slices.Contains(inv.groups[group], host) {
+		inv.groups[group] = append(inv.groups[group], host)
+	}
+}
+
+// list returns the --list document. Hostvars go in _meta so that Ansible
+// does not call --host once per host.
+func (inv inventory) list() map[string]any {
+	document := map[string]any{
+		"_meta": map[string]any{"hostvars": inv.hostvars},
+	}
+	for group, hosts := range inv.groups {
+		document[group] = map[string]any{"hosts": hosts}
+	}
+	return document
+}
+
+// hostVars returns the --host document, empty for unknown hosts as Ansible expects.
+func (inv inventory) hostVars(host string) map[string]any {
+	if vars, ok := inv.hostvars[host]; ok {
+		return vars
+	}
+	return map[string]any{}
+}
+
+// inventoryHostVars returns the variables of one host. Ansible connects to the
+// instance ID through the same ProxyCommand that --export-ssh-config writes.
+func inventoryHostVars(instance ec2client.RegionalInstance, options *ExportOptions, path string, logins loginMap) map[string]any {
+	login := options.Login
+	if login == "" {
+		login = logins.login(instance.Instance)
+	}
+
+	proxyCommand := "ProxyCommand=" + proxyCommandFor(instance, options, path)
+
+	vars := map[string]any{
+		"ansible_host":            aws.ToString(instance.InstanceId),
+		"ansible_user":            login,
+		"ansible_ssh_common_args": "-o " + shellescape.Quote(proxyCommand),
+		"ec2_id":                  aws.ToString(instance.InstanceId),
+		"ec2_region":              instance.Region,
+		"ec2_type":                string(instance.InstanceType),
+	}
+
+	if port := instancePortTag(instance.Instance); port != "" {
+		vars["ansible_port"], _ = strconv.Atoi(port)
+	}
+	if options.IdentityFile != "" {
+		vars["ansible_ssh_private_key_file"] = options.IdentityFile
+	}
+	if instance.Profile != "" {
+		vars["ec2_profile"] = instance.Profile
+	}
+	if instance.State != nil {
+		vars["ec2_state"] = string(instance.State.Name)
+	}
+	if instance.Placement != nil && instance.Placement.AvailabilityZone != nil {
+		vars["ec2_az"] = *instance.Placement.AvailabilityZone
+	}
+	if instance.VpcId != nil {
+		vars["ec2_vpc"] = *instance.VpcId
+	}
+	if instance.PrivateIpAddress != nil {
+		vars["ec2_private_ip"] = *instance.PrivateIpAddress
+	}
+	if instance.PublicIpAddress != nil {
+		vars["ec2_public_ip"] = *instance.PublicIpAddress
+	}
+
+	tags := make(map[string]string, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	vars["ec2_tags"] = tags
+
+	return vars
+}
+
+// inventoryGroupName joins a prefix and values into an Ansible group name,
+// replacing anything but letters, digits and underscores, e.g.
+// ("az", "us-east-1a") → "az_us_east_1a".
+func inventoryGroupName(prefix string, values ...string) string {
+	name := strings.Join(append([]string{prefix}, values...), "_")
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func writeInventoryJSON(w io.Writer, document map[string]any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInventoryOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args        []string
+		wantList    bool
+		wantHost    string
+		wantErr     bool
+		errContains string
+	}{
+		"list":                {args: []string{"--list"}, wantList: true},
+		"host":                {args: []string{"--host", "web"}, wantHost: "web"},
+		"no mode":             {args: []string{"--use-ssm"}},
+		"export options":      {args: []string{"--list", "--all-profiles", "--transport", "auto", "-i", "~/.ssh/id"}, wantList: true},
+		"list and host":       {args: []string{"--list", "--host", "web"}, wantErr: true, errContains: "mutually exclusive"},
+		"unexpected argument": {args: []string{"web"}, wantErr: true, errContains: "unexpected argument"},
+		"eice and ssm":        {args: []string{"--list", "--use-eice", "--use-ssm"}, wantErr: true, errContains: "mutually exclusive"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options, err := NewInventoryOptions(tc.args)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrUsage), "expected ErrUsage, got: %v", err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantList, options.List)
+			assert.Equal(t, tc.wantHost, options.Host)
+		})
+	}
+}
+
+func TestInventoryGroupName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		prefix string
+		values []string
+		want   string
+	}{
+		"az":         {prefix: "az", values: []string{"us-east-1a"}, want: "az_us_east_1a"},
+		"vpc":        {prefix: "vpc", values: []string{"vpc-123"}, want: "vpc_vpc_123"},
+		"type":       {prefix: "type", values: []string{"t3.micro"}, want: "type_t3_micro"},
+		"tag":        {prefix: "tag", values: []string{"Env", "prod"}, want: "tag_Env_prod"},
+		"tag spaces": {prefix: "tag", values: []string{"Cost Center", "R&D"}, want: "tag_Cost_Center_R_D"},
+		"empty tag":  {prefix: "tag", values: []string{"Backup", ""}, want: "tag_Backup_"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, inventoryGroupName(tc.prefix, tc.values...))
+		})
+	}
+}
+
+func TestBuildInventory(t *testing.T) {
+	t.Parallel()
+
+	micro := func(i *types.Instance) { i.InstanceType = types.InstanceTypeT3Micro }
+
+	instances := []ec2client.RegionalInstance{
+		{
+			Instance: ec2client.MakeInstance("i-1",
+				ec2client.WithNameTag("web"),
+				ec2client.WithTag("Env", "prod"),
+				ec2client.WithAZ("us-east-1a"),
+				ec2client.WithVPC("vpc-123"),
+				ec2client.WithPrivateIP("10.0.0.1"),
+				micro,
+			),
+			Region:  "us-east-1",
+			Profile: "prod",
+		},
+		{
+			Instance: ec2client.MakeInstance("i-2",
+				ec2client.WithNameTag("db"),
+				ec2client.WithTag("Env", "prod"),
+				ec2client.WithTag("ec2ssh:port", "2222"),
+				ec2client.WithAZ("us-east-1b"),
+				ec2client.WithVPC("vpc-123"),
+			),
+			Region:  "us-east-1",
+			Profile: "prod",
+		},
+	}
+
+	options, err := NewExportOptions([]string{"--use-ssm", "-i", "/home/me/.ssh/id_ed25519"})
+	require.NoError(t, err)
+
+	inv := buildInventory(instances, options, "/usr/bin/ec2ssh", loginMap{"i-2": "admin"})
+
+	assert.Equal(t, map[string][]string{
+		"tag_Env_prod":         {"db", "web"},
+		"tag_ec2ssh_port_2222": {"db"},
+		"az_us_east_1a":        {"web"},
+		"az_us_east_1b":        {"db"},
+		"vpc_vpc_123":          {"db", "web"},
+		"type_t3_micro":        {"web"},
+	}, inv.groups)
+
+	assert.Equal(t, map[string]any{
+		"ansible_host":                 "i-1",
+		"ansible_user":                 "ec2-user",
+		"ansible_ssh_common_args":      "-o 'ProxyCommand=/usr/bin/ec2ssh --proxy --region us-east-1 --profile prod --use-ssm -i /home/me/.ssh/id_ed25519 %r@%h:%p'",
+		"ansible_ssh_private_key_file": "/home/me/.ssh/id_ed25519",
+		"ec2_id":                       "i-1",
+		"ec2_region":                   "us-east-1",
+		"ec2_profile":                  "prod",
+		"ec2_state":                    "running",
+		"ec2_type":                     "t3.micro",
+		"ec2_az":                       "us-east-1a",
+		"ec2_vpc":                      "vpc-123",
+		"ec2_private_ip":               "10.0.0.1",
+		"ec2_tags":                     map[string]string{"Name": "web", "Env": "prod"},
+	}, inv.hostVars("web"))
+
+	assert.Equal(t, "admin", inv.hostVars("db")["ansible_user"])
+	assert.Equal(t, 2222, inv.hostVars("db")["ansible_port"])
+	assert.Empty(t, inv.hostVars("missing"))
+}
+
+func TestWriteInventoryJSON(t *testing.T) {
+	t.Parallel()
+
+	instances := inRegion("eu-west-1",
+		ec2client.MakeInstance("i-1", ec2client.WithNameTag("web"), ec2client.WithVPC("vpc-1")),
+	)
+
+	options, err := NewExportOptions(nil)
+	require.NoError(t, err)
+
+	inv := buildInventory(instances, options, "/usr/bin/ec2ssh", nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeInventoryJSON(&buf, inv.list()))
+
+	var document map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &document))
+
+	assert.Equal(t, map[string]any{"hosts": []any{"web"}}, document["vpc_vpc_1"])
+
+	hostvars := document["_meta"].(map[string]any)["hostvars"].(map[string]any)
+	web := hostvars["web"].(map[string]any)
+	assert.Equal(t, "i-1", web["ansible_host"])
+	assert.Equal(t, "-o 'ProxyCommand=/usr/bin/ec2ssh --proxy --region eu-west-1 %r@%h:%p'", web["ansible_ssh_common_args"])
+}
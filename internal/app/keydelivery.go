@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/ssmcommand"
+)
+
+// ssmKeyTTL is how long a key delivered through SSM stays in authorized_keys,
+// matching the 60 seconds of an Instance Connect key push.
+const ssmKeyTTL = 60 * time.Second
+
+// ssmKeyTimeout bounds the RunCommand that delivers a key.
+const ssmKeyTimeout = 60 * time.Second
+
+// runSSMCommand runs a command through SSM RunCommand, overridden in tests.
+var runSSMCommand = ssmcommand.RunCommand
+
+// KeyDelivery is how the public key reaches the instance.
+// Use a pointer to KeyDelivery where nil means KeyDeliveryAuto.
+type KeyDelivery int
+
+const (
+	KeyDeliveryAuto            KeyDelivery = iota // Instance Connect, SSM when it cannot serve the instance
+	KeyDeliveryInstanceConnect                    // EC2 Instance Connect SendSSHPublicKey only
+	KeyDeliverySSM                                // authorized_keys through SSM RunCommand
+)
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+func (d *KeyDelivery) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "auto":
+		*d = KeyDeliveryAuto
+	case "instance-connect":
+		*d = KeyDeliveryInstanceConnect
+	case "ssm":
+		*d = KeyDeliverySSM
+	default:
+		return fmt.Errorf("unknown key delivery: %s", text)
+	}
+	return nil
+}
+
+// ssmAddKeyScript runs ssmEditKeysScript as the user, so that links the
+// user planted under the home directory cannot make root write anywhere else.
+// Arguments: user, key line, TTL in seconds, ssmEditKeysScript.
+const ssmAddKeyScript = `set -e
+user=$1 key=$2 ttl=$3 edit=$4
+home=$(getent passwd "$user" | cut -d: -f6)
+if [ -z "$home" ]; then echo "ec2ssh: no such user: $user" >&2; exit 1; fi
+if [ "$(id -u)" = "$(id -u "$user")" ]; then
+	sh -c "$edit" ec2ssh-edit-keys "$home" "$key" "$ttl"
+else
+	runuser -u "$user" -- sh -c "$edit" ec2ssh-edit-keys "$home" "$key" "$ttl"
+fi
+`
+
+// ssmEditKeysScript appends a key line to authorized_keys with an
+// ec2ssh-expires=<unix time> marker, drops lines whose marker has passed, and
+// schedules removal of the new line after the TTL. Times come from the
+// instance clock. The new file is written next to authorized_keys and renamed
+// over it, so sshd never reads it half-written; a symlinked ~/.ssh or
+// authorized_keys is refused rather than replaced.
+// Arguments: home directory, key line, TTL in seconds.
+const ssmEditKeysScript = `set -e
+dir=$1/.ssh key=$2 ttl=$3
+file=$dir/authorized_keys
+for path in "$dir" "$file"; do
+	if [ -L "$path" ]; then echo "ec2ssh: refusing to edit through symlink $path" >&2; exit 1; fi
+done
+[ -d "$dir" ] || mkdir -m 700 "$dir"
+now=$(date +%s)
+line="$key ec2ssh-expires=$((now + ttl))"
+tmp=$(mktemp "$dir/.authorized_keys.XXXXXX")
+trap 'rm -f "$tmp"' EXIT
+if [ -f "$file" ]; then
+	awk -v now="$now" '{ for (i = 1; i <= NF; i++) if ($i ~ /^ec2ssh-expires=[0-9]+$/ && substr($i, 16) + 0 <= now) next; print }' "$file" > "$tmp"
+fi
+printf '%s\n' "$line" >> "$tmp"
+mv -f "$tmp" "$file"
+setsid sh -c '
+	sleep "$1"
+	[ -L "$3" ] && exit
+	tmp=$(mktemp "$3.XXXXXX") || exit
+	grep -vxF "$2" "$3" > "$tmp"
+	if [ $? -le 1 ]; then mv -f "$tmp" "$3"; else rm -f "$tmp"; fi
+' ec2ssh-expire "$ttl" "$line" "$file" < /dev/null > /dev/null 2>&1 &
+`
+
+// deliverPublicKey authorizes the public key for login as chosen by
+// --key-delivery: by default Instance Connect, falling back to SSM when
+// Instance Connect cannot serve the instance.
+func (s *baseSSHSession) deliverPublicKey(login string) error {
+	delivery := KeyDeliveryAuto
+	if s.KeyDelivery != nil {
+		delivery = *s.KeyDelivery
+	}
+
+	if delivery == KeyDeliverySSM {
+		if err := s.deliverKeySSM(login); err != nil {
+			return fmt.Errorf("unable to deliver SSH public key through SSM: %w", err)
+		}
+		return nil
+	}
+
+	err := s.client.SendSSHPublicKey(s.instance, login, s.publicKey)
+	if err == nil {
+		return nil
+	}
+	if delivery == KeyDeliveryAuto && ec2client.IsInstanceConnectUnsupported(err) {
+		s.logger.Printf("Instance Connect cannot serve instance (%v), delivering key through SSM", err)
+		if ssmErr := s.deliverKeySSM(login); ssmErr != nil {
+			return fmt.Errorf("unable to send SSH public key: %w (SSM fallback: %v)", err, ssmErr)
+		}
+		return nil
+	}
+	return fmt.Errorf("unable to send SSH public key: %w", err)
+}
+
+// deliverKeySSM adds the public key to login's authorized_keys through SSM
+// RunCommand, for ssmKeyTTL.
+func (s *baseSSHSession) deliverKeySSM(login string) error {
+	// Type and key only: the comment would get in the way of the marker
+	fields := strings.Fields(s.publicKey)
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid public key")
+	}
+	key := fields[0] + " " + fields[1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), ssmKeyTimeout)
+	defer cancel()
+
+	s.logger.Printf("adding SSH public key for %s through SSM on instance %s", login, *s.instance.InstanceId)
+
+	args := []string{"sh", "-c", ssmAddKeyScript, "ec2ssh-add-key", login, key, strconv.Itoa(int(ssmKeyTTL / time.Second)), ssmEditKeysScript}
+	_, stderr, err := runSSMCommand(ctx, s.client.Config(), *s.instance.InstanceId, args)
+	if err != nil {
+		if stderr = strings.TrimSpace(stderr); stderr != "" {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+		return err
+	}
+	return nil
+}
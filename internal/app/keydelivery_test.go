@@ -0,0 +1,297 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	connecttypes "github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyDelivery_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    KeyDelivery
+		wantErr bool
+	}{
+		"auto":             {input: "auto", want: KeyDeliveryAuto},
+		"instance-connect": {input: "instance-connect", want: KeyDeliveryInstanceConnect},
+		"ssm":              {input: "ssm", want: KeyDeliverySSM},
+		"unknown":          {input: "scp", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var delivery KeyDelivery
+			err := delivery.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown key delivery")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, delivery)
+		})
+	}
+}
+
+// runAddKeyScript runs ssmAddKeyScript locally against a fake home directory,
+// with getent answering for the current user.
+func runAddKeyScript(t *testing.T, home, key string, ttl int) {
+	t.Helper()
+
+	output, err := addKeyScriptCommand(t, home, key, ttl).CombinedOutput()
+	require.NoError(t, err, string(output))
+}
+
+// addKeyScriptCommand returns the command runAddKeyScript runs.
+func addKeyScriptCommand(t *testing.T, home, key string, ttl int) *exec.Cmd {
+	t.Helper()
+
+	for _, tool := range []string{"sh", "setsid", "awk", "mktemp"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+
+	bin := t.TempDir()
+	getent := "#!/bin/sh\necho \"$2:x:0:0::" + home + ":/bin/sh\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "getent"), []byte(getent), 0o755))
+
+	user := strconv.Itoa(os.Getuid())
+	cmd := exec.Command("sh", "-c", ssmAddKeyScript, "ec2ssh-add-key", user, key, strconv.Itoa(ttl), ssmEditKeysScript)
+	cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+	return cmd
+}
+
+func TestSSMAddKeyScript(t *testing.T) {
+	t.Parallel()
+
+	home := t.TempDir()
+	runAddKeyScript(t, home, "ssh-ed25519 AAAAfirst", 600)
+
+	// Creates ~/.ssh and authorized_keys with sshd's required modes
+	info, err := os.Stat(filepath.Join(home, ".ssh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	file := filepath.Join(home, ".ssh", "authorized_keys")
+	info, err = os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Regexp(t, `^ssh-ed25519 AAAAfirst ec2ssh-expires=\d+\n$`, string(content))
+
+	// Expired ec2ssh keys are dropped, other keys kept
+	expired := "ssh-ed25519 AAAAold ec2ssh-expires=1000\n"
+	require.NoError(t, os.WriteFile(file, []byte("ssh-rsa AAAAmine me@laptop\n"+expired), 0o600))
+
+	runAddKeyScript(t, home, "ssh-ed25519 AAAAsecond", 600)
+
+	content, err = os.ReadFile(file)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "ssh-rsa AAAAmine me@laptop", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "ssh-ed25519 AAAAsecond ec2ssh-expires="))
+}
+
+func TestSSMAddKeyScript_RefusesSymlinks(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		link func(t *testing.T, home, target string)
+	}{
+		"authorized_keys": {
+			link: func(t *testing.T, home, target string) {
+				require.NoError(t, os.Mkdir(filepath.Join(home, ".ssh"), 0o700))
+				require.NoError(t, os.Symlink(filepath.Join(target, "victim"), filepath.Join(home, ".ssh", "authorized_keys")))
+			},
+		},
+		".ssh": {
+			link: func(t *testing.T, home, target string) {
+				require.NoError(t, os.Symlink(target, filepath.Join(home, ".ssh")))
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			home, target := t.TempDir(), t.TempDir()
+			victim := filepath.Join(target, "victim")
+			authorizedKeys := filepath.Join(target, "authorized_keys")
+			require.NoError(t, os.WriteFile(victim, []byte("root:x:0:0::/root:/bin/sh\n"), 0o644))
+			require.NoError(t, os.WriteFile(authorizedKeys, []byte("ssh-rsa AAAAmine me@laptop\n"), 0o600))
+			tc.link(t, home, target)
+
+			output, err := addKeyScriptCommand(t, home, "ssh-ed25519 AAAAevil", 600).CombinedOutput()
+
+			require.Error(t, err)
+			assert.Contains(t, string(output), "refusing to edit through symlink")
+			content, err := os.ReadFile(victim)
+			require.NoError(t, err)
+			assert.Equal(t, "root:x:0:0::/root:/bin/sh\n", string(content))
+			content, err = os.ReadFile(authorizedKeys)
+			require.NoError(t, err)
+			assert.Equal(t, "ssh-rsa AAAAmine me@laptop\n", string(content))
+			entries, err := os.ReadDir(target)
+			require.NoError(t, err)
+			assert.Len(t, entries, 2, "no temporary files left behind")
+		})
+	}
+}
+
+func TestSSMAddKeyScript_RemovesAfterTTL(t *testing.T) {
+	t.Parallel()
+
+	home := t.TempDir()
+	file := filepath.Join(home, ".ssh", "authorized_keys")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o700))
+	require.NoError(t, os.WriteFile(file, []byte("ssh-rsa AAAAmine me@laptop\n"), 0o600))
+
+	runAddKeyScript(t, home, "ssh-ed25519 AAAAshort", 1)
+
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(file)
+		return err == nil && string(content) == "ssh-rsa AAAAmine me@laptop\n"
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// ssmCommandCapture records calls to runSSMCommand.
+type ssmCommandCapture struct {
+	instanceID string
+	args       []string
+	calls      int
+}
+
+func setupSSMCommandMock(t *testing.T, stderr string, err error) *ssmCommandCapture {
+	t.Helper()
+
+	origRunSSMCommand := runSSMCommand
+	t.Cleanup(func() { runSSMCommand = origRunSSMCommand })
+
+	var captured ssmCommandCapture
+	runSSMCommand = func(ctx context.Context, cfg aws.Config, instanceID string, args []string) (string, string, error) {
+		captured.instanceID, captured.args = instanceID, args
+		captured.calls++
+		return "", stderr, err
+	}
+	return &captured
+}
+
+func TestSSHSession_Run_KeyDelivery(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	unavailable := &connecttypes.EC2InstanceUnavailableException{Message: aws.String("no agent")}
+	denied := &connecttypes.AuthException{Message: aws.String("denied")}
+
+	tests := map[string]struct {
+		args        []string
+		connectErr  error
+		ssmStderr   string
+		ssmErr      error
+		wantConnect bool
+		wantSSM     bool
+		errContains string
+	}{
+		"ssm": {
+			args:    []string{"--key-delivery", "ssm", "admin@i-1234567890abcdef0"},
+			wantSSM: true,
+		},
+		"auto uses instance connect": {
+			args:        []string{"admin@i-1234567890abcdef0"},
+			wantConnect: true,
+		},
+		"auto falls back to ssm": {
+			args:        []string{"admin@i-1234567890abcdef0"},
+			connectErr:  unavailable,
+			wantConnect: true,
+			wantSSM:     true,
+		},
+		"auto does not fall back on auth errors": {
+			args:        []string{"admin@i-1234567890abcdef0"},
+			connectErr:  denied,
+			wantConnect: true,
+			errContains: "unable to send SSH public key",
+		},
+		"instance connect only": {
+			args:        []string{"--key-delivery", "instance-connect", "admin@i-1234567890abcdef0"},
+			connectErr:  unavailable,
+			wantConnect: true,
+			errContains: "unable to send SSH public key",
+		},
+		"fallback fails": {
+			args:        []string{"admin@i-1234567890abcdef0"},
+			connectErr:  unavailable,
+			ssmErr:      errors.New("failed to send command"),
+			wantConnect: true,
+			wantSSM:     true,
+			errContains: "SSM fallback: failed to send command",
+		},
+		"ssm script fails": {
+			args:        []string{"--key-delivery", "ssm", "admin@i-1234567890abcdef0"},
+			ssmStderr:   "ec2ssh: no such user: admin\n",
+			ssmErr:      errors.New("remote command exited with code 1"),
+			wantSSM:     true,
+			errContains: "no such user: admin",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var captured commandCapture
+			_, connectMock := setupMocksForRun(t, testInstance, &captured)
+			connectMock.ExpectedCalls = nil
+			connectMock.On("SendSSHPublicKey", mock.Anything, mock.Anything).Return(nil, tc.connectErr)
+			ssmCommand := setupSSMCommandMock(t, tc.ssmStderr, tc.ssmErr)
+
+			session, err := NewSSHSession(tc.args)
+			require.NoError(t, err)
+
+			err = session.Run()
+
+			if tc.wantConnect {
+				connectMock.AssertCalled(t, "SendSSHPublicKey", mock.Anything, mock.Anything)
+			} else {
+				connectMock.AssertNotCalled(t, "SendSSHPublicKey", mock.Anything, mock.Anything)
+			}
+
+			if tc.wantSSM {
+				require.Equal(t, 1, ssmCommand.calls)
+				assert.Equal(t, "i-1234567890abcdef0", ssmCommand.instanceID)
+				assert.Equal(t, []string{"sh", "-c", ssmAddKeyScript, "ec2ssh-add-key", "admin", "ssh-ed25519 AAAAC3NzaC1...", "60", ssmEditKeysScript}, ssmCommand.args)
+			} else {
+				assert.Zero(t, ssmCommand.calls)
+			}
+
+			if tc.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				assert.Empty(t, captured.command, "ssh should not run")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "ssh", captured.command)
+		})
+	}
+}
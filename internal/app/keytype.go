@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+)
+
+// keyTypeEnv names the environment variable holding the default --key-type,
+// for users whose instances mostly need the same one.
+const keyTypeEnv = "EC2SSH_KEY_TYPE"
+
+// generatesKeys reports whether the session generates its key, as opposed
+// to using -i or an identity already in ssh-agent.
+func (s *baseSSHSession) generatesKeys() bool {
+	return s.IdentityFile == "" && (s.Agent == nil || *s.Agent != AgentUse)
+}
+
+// instanceConnectRefuses reports whether keys of keyType would have to be
+// pushed with Instance Connect, which does not accept them.
+func (s *baseSSHSession) instanceConnectRefuses(keyType ssh.KeyType) bool {
+	viaSSM := s.KeyDelivery != nil && *s.KeyDelivery == KeyDeliverySSM
+	return !keyType.InstanceConnectAccepts() && !s.NoSendKeys && s.CAKey == "" && !viaSSM
+}
+
+// validateKeyType checks --key-type, or without it loads the default key
+// type from EC2SSH_KEY_TYPE, which is ignored when no key is generated.
+func (s *baseSSHSession) validateKeyType() error {
+	if s.KeyType != nil {
+		if !s.generatesKeys() {
+			return fmt.Errorf("%w: --key-type only applies to generated keys, not -i or --agent use", ErrUsage)
+		}
+		if s.instanceConnectRefuses(*s.KeyType) {
+			return fmt.Errorf("%w: EC2 Instance Connect does not accept %s keys, use ed25519, rsa, --ca-key or --key-delivery ssm", ErrUsage, s.KeyType.Algorithm)
+		}
+		return nil
+	}
+
+	value := os.Getenv(keyTypeEnv)
+	if value == "" || !s.generatesKeys() {
+		return nil
+	}
+	var keyType ssh.KeyType
+	if err := keyType.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("%w: invalid %s=%s: %w", ErrUsage, keyTypeEnv, value, err)
+	}
+	if s.instanceConnectRefuses(keyType) {
+		return fmt.Errorf("%w: invalid %s=%s: EC2 Instance Connect does not accept %s keys", ErrUsage, keyTypeEnv, value, keyType.Algorithm)
+	}
+	s.defaultKeyType = &keyType
+	return nil
+}
+
+// applyKeyTypeTag sets the type of generated keys from the instance's
+// ec2ssh:key-type tag, unless --key-type is given or no key is generated.
+// The tag wins over EC2SSH_KEY_TYPE, as it is specific to the instance.
+// Types Instance Connect refuses are an error, as with --key-type.
+func (s *baseSSHSession) applyKeyTypeTag() error {
+	value, ok := s.instanceTag(tagKeyType)
+	if !ok || s.KeyType != nil || !s.generatesKeys() {
+		return nil
+	}
+
+	var keyType ssh.KeyType
+	if err := keyType.UnmarshalText([]byte(value)); err != nil {
+		return s.invalidTagError(tagKeyType, value, err)
+	}
+	if s.instanceConnectRefuses(keyType) {
+		return s.invalidTagError(tagKeyType, value, fmt.Errorf("EC2 Instance Connect does not accept %s keys", keyType.Algorithm))
+	}
+	s.logger.Printf("using tag %s=%s", tagKeyType, value)
+	s.KeyType = &keyType
+	return nil
+}
+
+// keyType returns the type of generated keys: from --key-type or the
+// ec2ssh:key-type tag, else EC2SSH_KEY_TYPE, else ed25519.
+func (s *baseSSHSession) keyType() ssh.KeyType {
+	if s.KeyType != nil {
+		return *s.KeyType
+	}
+	if s.defaultKeyType != nil {
+		return *s.defaultKeyType
+	}
+	return ssh.KeyType{}
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSSHSession_KeyTypeEnv(t *testing.T) {
+	// No t.Parallel() - sets EC2SSH_KEY_TYPE
+
+	tests := map[string]struct {
+		env         string
+		args        []string
+		wantKeyType string
+		wantErr     string
+	}{
+		"unset": {
+			wantKeyType: "ed25519",
+		},
+		"default from environment": {
+			env:         "rsa:4096",
+			wantKeyType: "rsa:4096",
+		},
+		"--key-type wins": {
+			env:         "rsa:4096",
+			args:        []string{"--key-type", "ed25519"},
+			wantKeyType: "ed25519",
+		},
+		"ignored with identity file": {
+			env:         "dsa",
+			args:        []string{"-i", "~/.ssh/id_rsa"},
+			wantKeyType: "ed25519",
+		},
+		"ecdsa with ssm key delivery": {
+			env:         "ecdsa",
+			args:        []string{"--key-delivery", "ssm"},
+			wantKeyType: "ecdsa:256",
+		},
+		"unknown type": {
+			env:     "dsa",
+			wantErr: "invalid EC2SSH_KEY_TYPE=dsa: unknown key type",
+		},
+		"refused by Instance Connect": {
+			env:     "ecdsa",
+			wantErr: "invalid EC2SSH_KEY_TYPE=ecdsa: EC2 Instance Connect does not accept ecdsa keys",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(keyTypeEnv, tc.env)
+
+			session, err := NewSSHSession(append(tc.args, "myhost"))
+
+			if tc.wantErr != "" {
+				require.ErrorIs(t, err, ErrUsage)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantKeyType, session.keyType().String())
+		})
+	}
+}
+
+func TestSSHSession_Run_KeyTypeTagWinsOverEnv(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+	t.Setenv(keyTypeEnv, "rsa")
+
+	instance := testInstance
+	instance.Tags = []types.Tag{{Key: aws.String("ec2ssh:key-type"), Value: aws.String("rsa:4096")}}
+	setupMocksForRun(t, instance, &commandCapture{})
+
+	var keyType ssh.KeyType
+	generateKeypair = func(tmpDir string, kt ssh.KeyType) (string, string, error) {
+		keyType = kt
+		return "/tmp/test_key", "ssh-rsa AAAAB3NzaC1... test@host", nil
+	}
+
+	session, err := NewSSHSession([]string{"i-1234567890abcdef0"})
+	require.NoError(t, err)
+	require.NoError(t, session.Run())
+
+	assert.Equal(t, "rsa:4096", keyType.String())
+}
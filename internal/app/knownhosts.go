@@ -0,0 +1,226 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/argsieve"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+)
+
+// knownHostsBatchSize is the most instance IDs per DescribeInstances filter.
+const knownHostsBatchSize = 200
+
+// instanceIDRe matches the instance IDs that HostKeyAlias puts in known_hosts.
+var instanceIDRe = regexp.MustCompile(`^i-([0-9a-f]{8}|[0-9a-f]{17})$`)
+
+// KnownHostsOptions holds the parsed configuration for --known-hosts.
+type KnownHostsOptions struct {
+	Region        string             `long:"region"`
+	Regions       *ec2client.Regions `long:"regions"` // nil = all regions, unless --region is given
+	Profile       string             `long:"profile"`
+	Profiles      string             `long:"profiles"` // Glob over configured profiles
+	AllProfiles   bool               `long:"all-profiles"`
+	DryRun        bool               `long:"dry-run"`
+	RemoveMissing bool               `long:"remove-missing"` // Also remove instances not found, see removesMissing
+	Debug         bool               `long:"debug"`
+
+	Files []string // known_hosts files, empty = ~/.ssh/known_hosts and the --host-keys file
+}
+
+// NewKnownHostsOptions creates KnownHostsOptions from command-line arguments:
+// the operation, which must be "prune", followed by options and files.
+func NewKnownHostsOptions(args []string) (*KnownHostsOptions, error) {
+	var options KnownHostsOptions
+
+	positional, err := argsieve.Parse(&options, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	if len(positional) == 0 {
+		return nil, fmt.Errorf("%w: --known-hosts requires an operation (prune)", ErrUsage)
+	}
+	if positional[0] != "prune" {
+		return nil, fmt.Errorf("%w: unknown --known-hosts operation: %s", ErrUsage, positional[0])
+	}
+	options.Files = positional[1:]
+
+	if options.Region == "" && options.Regions == nil {
+		options.Regions = &ec2client.Regions{All: true}
+	}
+
+	if err := validateProfileFlags(options.Profile, options.Profiles, options.AllProfiles); err != nil {
+		return nil, err
+	}
+
+	return &options, nil
+}
+
+// RunKnownHosts executes the known-hosts intent with the given arguments.
+func RunKnownHosts(args []string) error {
+	options, err := NewKnownHostsOptions(args)
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(io.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	if options.Debug {
+		logger.SetOutput(os.Stderr)
+	}
+
+	files := options.Files
+	if len(files) == 0 {
+		if files, err = defaultKnownHostsFiles(); err != nil {
+			return err
+		}
+	}
+
+	// A profile left out would make its instances look missing
+	clients, err := newStrictSearchClients(options.Region, options.Profile, profilePattern(options.Profiles, options.AllProfiles), options.Regions, logger)
+	if err != nil {
+		return err
+	}
+
+	return pruneKnownHosts(os.Stdout, files, clients, options.DryRun, options.removesMissing())
+}
+
+// removesMissing reports whether entries of instances found nowhere are
+// removed too. An instance of an account or region outside the search is
+// not found either, so that takes --remove-missing, or a search of every
+// profile in every region.
+func (o *KnownHostsOptions) removesMissing() bool {
+	return o.RemoveMissing || (o.AllProfiles && o.Regions != nil && o.Regions.All)
+}
+
+// defaultKnownHostsFiles returns those of ~/.ssh/known_hosts and the
+// --host-keys file that exist.
+func defaultKnownHostsFiles() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("unable to find home directory: %w", err)
+	}
+	hostKeysFile, err := knownHostsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, path := range []string{filepath.Join(home, ".ssh", "known_hosts"), hostKeysFile} {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no known_hosts files found")
+	}
+	return files, nil
+}
+
+// pruneKnownHosts removes known_hosts entries for instances that are
+// terminated, or with removeMissing not found with any of the clients, and
+// reports them and the entries removed per file to w. Hosts that are not
+// instance IDs are kept.
+func pruneKnownHosts(w io.Writer, files []string, clients []*ec2client.Client, dryRun, removeMissing bool) error {
+	var ids []string
+	for _, path := range files {
+		hosts, err := ssh.KnownHostsHosts(path)
+		if err != nil {
+			return err
+		}
+		for _, host := range hosts {
+			if instanceIDRe.MatchString(host) && !slices.Contains(ids, host) {
+				ids = append(ids, host)
+			}
+		}
+	}
+
+	stale, err := staleInstances(clients, ids)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, id := range ids {
+		reason, ok := stale[id]
+		if !ok {
+			continue
+		}
+		if reason == staleNotFound && !removeMissing {
+			delete(stale, id)
+			reason += ", kept"
+			kept++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", id, reason)
+	}
+
+	verb := "removed"
+	if dryRun {
+		verb = "would remove"
+	}
+	for _, path := range files {
+		removed, total, err := ssh.PruneKnownHosts(path, func(host string) bool {
+			_, ok := stale[host]
+			return ok
+		}, dryRun)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "%s: %s %d of %d entries\n", path, verb, removed, total)
+	}
+	if kept > 0 {
+		_, _ = fmt.Fprintf(w, "%d instances not found were kept, as they may be in accounts or regions not searched; use --all-profiles in all regions, or --remove-missing, to remove them\n", kept)
+	}
+
+	return nil
+}
+
+// Reasons staleInstances gives for an instance.
+const (
+	staleTerminated = "terminated"
+	staleNotFound   = "not found"
+)
+
+// staleInstances looks up instance IDs in batches with every client and
+// returns why each stale one is: staleTerminated or staleNotFound. An error from
+// any client fails the lookup, so that nothing is pruned on partial results.
+func staleInstances(clients []*ec2client.Client, ids []string) (map[string]string, error) {
+	states := make(map[string]types.InstanceStateName)
+	for batch := range slices.Chunk(ids, knownHostsBatchSize) {
+		query := ec2client.ListQuery{Filters: []types.Filter{{Name: aws.String("instance-id"), Values: batch}}}
+		instances, err := ec2client.ListInstancesInRegionsStrict(clients, query)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up instances: %w", err)
+		}
+		for _, instance := range instances {
+			id := aws.ToString(instance.InstanceId)
+			// An instance seen live anywhere stays, whatever other locations say
+			if states[id] != "" && states[id] != types.InstanceStateNameTerminated {
+				continue
+			}
+			if instance.State != nil {
+				states[id] = instance.State.Name
+			}
+		}
+	}
+
+	stale := make(map[string]string)
+	for _, id := range ids {
+		switch states[id] {
+		case "":
+			stale[id] = staleNotFound
+		case types.InstanceStateNameTerminated:
+			stale[id] = staleTerminated
+		}
+	}
+	return stale, nil
+}
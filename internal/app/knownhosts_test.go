@@ -0,0 +1,238 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKnownHostsOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args              []string
+		wantFiles         []string
+		wantDryRun        bool
+		wantRegions       *ec2client.Regions
+		wantRemoveMissing bool
+		wantErr           bool
+		errContains       string
+	}{
+		"prune":                      {args: []string{"prune"}, wantRegions: &ec2client.Regions{All: true}},
+		"all profiles":               {args: []string{"prune", "--all-profiles"}, wantRegions: &ec2client.Regions{All: true}, wantRemoveMissing: true},
+		"all profiles, some regions": {args: []string{"prune", "--all-profiles", "--regions", "us-east-1"}, wantRegions: &ec2client.Regions{Names: []string{"us-east-1"}}},
+		"remove missing":             {args: []string{"prune", "--profile", "prod", "--remove-missing"}, wantRegions: &ec2client.Regions{All: true}, wantRemoveMissing: true},
+		"dry run and files":          {args: []string{"prune", "--dry-run", "a", "b"}, wantFiles: []string{"a", "b"}, wantDryRun: true, wantRegions: &ec2client.Regions{All: true}},
+		"region":                     {args: []string{"prune", "--region", "us-east-1"}},
+		"regions":                    {args: []string{"prune", "--regions", "us-east-1,eu-west-1"}, wantRegions: &ec2client.Regions{Names: []string{"us-east-1", "eu-west-1"}}},
+		"no operation":               {args: []string{"--dry-run"}, wantErr: true, errContains: "requires an operation"},
+		"unknown operation":          {args: []string{"add"}, wantErr: true, errContains: "unknown --known-hosts operation: add"},
+		"profile conflict":           {args: []string{"prune", "--profile", "a", "--all-profiles"}, wantErr: true, errContains: "cannot be combined"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options, err := NewKnownHostsOptions(tc.args)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrUsage), "expected ErrUsage, got: %v", err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.wantFiles, options.Files)
+			assert.Equal(t, tc.wantDryRun, options.DryRun)
+			assert.Equal(t, tc.wantRegions, options.Regions)
+			assert.Equal(t, tc.wantRemoveMissing, options.removesMissing())
+		})
+	}
+}
+
+func TestPruneKnownHosts(t *testing.T) {
+	t.Parallel()
+
+	const (
+		live       = "i-0000000000000000a"
+		terminated = "i-0000000000000000b"
+		missing    = "i-0000000000000000c"
+		elsewhere  = "i-0000000000000000d"
+	)
+	key := testHostKey(t)
+	knownHosts := fmt.Sprintf("%[1]s %[5]s\n%[2]s %[5]s\n%[3]s %[5]s\nweb %[5]s\n%[4]s %[5]s\n", live, terminated, missing, elsewhere, key)
+	hostKeys := fmt.Sprintf("%[1]s %[2]s\n", missing, key)
+
+	terminatedState := func(i *types.Instance) { i.State = &types.InstanceState{Name: types.InstanceStateNameTerminated} }
+
+	tests := map[string]struct {
+		dryRun        bool
+		removeMissing bool
+		euErr         error
+		wantOutput    string
+		wantKnown     string
+		wantHostKeys  string
+		errContains   string
+	}{
+		"prune": {
+			removeMissing: true,
+			wantOutput: terminated + "\tterminated\n" + missing + "\tnot found\n" +
+				"known_hosts: removed 2 of 5 entries\nec2ssh_known_hosts: removed 1 of 1 entries\n",
+			wantKnown:    fmt.Sprintf("%s %s\nweb %s\n%s %s\n", live, key, key, elsewhere, key),
+			wantHostKeys: "",
+		},
+		// missing belongs to an account that was not searched
+		"instance outside the searched scope kept": {
+			wantOutput: terminated + "\tterminated\n" + missing + "\tnot found, kept\n" +
+				"known_hosts: removed 1 of 5 entries\nec2ssh_known_hosts: removed 0 of 1 entries\n" +
+				"1 instances not found were kept, as they may be in accounts or regions not searched; use --all-profiles in all regions, or --remove-missing, to remove them\n",
+			wantKnown:    fmt.Sprintf("%s %s\n%s %s\nweb %s\n%s %s\n", live, key, missing, key, key, elsewhere, key),
+			wantHostKeys: hostKeys,
+		},
+		"dry run": {
+			dryRun:        true,
+			removeMissing: true,
+			wantOutput: terminated + "\tterminated\n" + missing + "\tnot found\n" +
+				"known_hosts: would remove 2 of 5 entries\nec2ssh_known_hosts: would remove 1 of 1 entries\n",
+			wantKnown:    knownHosts,
+			wantHostKeys: hostKeys,
+		},
+		"lookup error": {
+			euErr:        errors.New("UnauthorizedOperation"),
+			errContains:  "UnauthorizedOperation",
+			wantKnown:    knownHosts,
+			wantHostKeys: hostKeys,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			knownHostsFile := filepath.Join(dir, "known_hosts")
+			hostKeysFile := filepath.Join(dir, "ec2ssh_known_hosts")
+			require.NoError(t, os.WriteFile(knownHostsFile, []byte(knownHosts), 0o600))
+			require.NoError(t, os.WriteFile(hostKeysFile, []byte(hostKeys), 0o600))
+
+			usMock := new(mockEC2API)
+			usMock.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+				return len(input.Filters) == 1 && *input.Filters[0].Name == "instance-id" &&
+					assert.ObjectsAreEqual([]string{live, terminated, missing, elsewhere}, input.Filters[0].Values)
+			})).Return(ec2client.MakeDescribeOutput(ec2client.MakeReservation(
+				ec2client.MakeInstance(live),
+				ec2client.MakeInstance(terminated, terminatedState),
+				// Terminated here, but live in the other region
+				ec2client.MakeInstance(elsewhere, terminatedState),
+			)), nil)
+			euMock := new(mockEC2API)
+			if tc.euErr != nil {
+				euMock.On("DescribeInstances", mock.Anything, mock.Anything).Return(nil, tc.euErr)
+			} else {
+				euMock.On("DescribeInstances", mock.Anything, mock.Anything).Return(ec2client.MakeDescribeOutput(ec2client.MakeReservation(
+					ec2client.MakeInstance(elsewhere),
+				)), nil)
+			}
+			clients := []*ec2client.Client{
+				ec2client.NewTestClientInRegion("us-east-1", usMock, nil, nil),
+				ec2client.NewTestClientInRegion("eu-west-1", euMock, nil, nil),
+			}
+
+			var output bytes.Buffer
+			err := pruneKnownHosts(&output, []string{knownHostsFile, hostKeysFile}, clients, tc.dryRun, tc.removeMissing)
+
+			if tc.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantOutput, string(bytes.ReplaceAll(output.Bytes(), []byte(dir+"/"), nil)))
+			}
+
+			data, err := os.ReadFile(knownHostsFile)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantKnown, string(data))
+			data, err = os.ReadFile(hostKeysFile)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantHostKeys, string(data))
+		})
+	}
+}
+
+func TestStaleInstances_Batches(t *testing.T) {
+	t.Parallel()
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("i-%017x", i)
+	}
+
+	ec2Mock := new(mockEC2API)
+	ec2Mock.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return len(input.Filters[0].Values) == knownHostsBatchSize
+	})).Return(ec2client.MakeDescribeOutput(ec2client.MakeReservation(ec2client.MakeInstance(ids[0]))), nil).Once()
+	ec2Mock.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return len(input.Filters[0].Values) == 50
+	})).Return(ec2client.MakeDescribeOutput(ec2client.MakeReservation(ec2client.MakeInstance(ids[249]))), nil).Once()
+
+	stale, err := staleInstances([]*ec2client.Client{ec2client.NewTestClient(ec2Mock, nil, nil)}, ids)
+	require.NoError(t, err)
+
+	assert.Len(t, stale, 248)
+	assert.NotContains(t, stale, ids[0])
+	assert.NotContains(t, stale, ids[249])
+	assert.Equal(t, "not found", stale[ids[1]])
+	ec2Mock.AssertExpectations(t)
+}
+
+func TestRunKnownHosts_ProfileLoadError(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	setupMocksForRun(t, testInstance, nil)
+
+	origListProfiles := listProfiles
+	t.Cleanup(func() { listProfiles = origListProfiles })
+	listProfiles = func() ([]string, error) {
+		return []string{"dev", "prod"}, nil
+	}
+
+	// dev cannot be searched, so its instances are not found in prod alone
+	loadAWSConfig = func(region, profile string, logger *log.Logger) (aws.Config, error) {
+		if profile == "dev" {
+			return aws.Config{}, errors.New("expired token")
+		}
+		return aws.Config{Region: "us-east-1"}, nil
+	}
+	prodMock := new(mockEC2API)
+	prodMock.On("DescribeRegions", mock.Anything, mock.Anything).Return(ec2client.MakeDescribeRegionsOutput("us-east-1"), nil)
+	prodMock.On("DescribeInstances", mock.Anything, mock.Anything).Return(ec2client.MakeDescribeOutput(), nil)
+	newEC2Client = func(cfg aws.Config, logger *log.Logger) (*ec2client.Client, error) {
+		return ec2client.NewTestClientInRegion(cfg.Region, prodMock, nil, nil), nil
+	}
+
+	knownHosts := fmt.Sprintf("i-0000000000000000a %s\n", testHostKey(t))
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHostsFile, []byte(knownHosts), 0o600))
+
+	err := RunKnownHosts([]string{"prune", "--all-profiles", "--regions", "all", knownHostsFile})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile dev: expired token")
+
+	data, err := os.ReadFile(knownHostsFile)
+	require.NoError(t, err)
+	assert.Equal(t, knownHosts, string(data))
+}
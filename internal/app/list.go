@@ -5,32 +5,65 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
 	"slices"
 	"strings"
-	"text/tabwriter"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/ivoronin/argsieve"
-	"github.com/ivoronin/ec2ssh/internal/awsclient"
 	"github.com/ivoronin/ec2ssh/internal/ec2client"
 )
 
 var (
 	allowedListColumns = []string{
-		"ID", "NAME", "STATE", "TYPE", "AZ", "PRIVATE-IP",
-		"PUBLIC-IP", "IPV6", "PRIVATE-DNS", "PUBLIC-DNS",
+		"ID", "NAME", "STATE", "TYPE", "AZ", "REGION", "PROFILE", "ACCOUNT",
+		"PRIVATE-IP", "PUBLIC-IP", "IPV6", "PRIVATE-DNS", "PUBLIC-DNS",
+		"VPC", "SUBNET", "LAUNCH-TIME", "UPTIME", "AMI", "KEY-NAME", "PLATFORM",
+		"ARCH", "IAM-PROFILE", "LIFECYCLE", "SECURITY-GROUPS",
+		"CONNECT", "SSM-PING", "EICE-ID",
 	}
-	defaultListColumns = "ID,NAME,STATE,PRIVATE-IP,PUBLIC-IP"
+	connectivityColumns = []string{"CONNECT", "SSM-PING", "EICE-ID"}
+	defaultListColumns  = "ID,NAME,STATE,PRIVATE-IP,PUBLIC-IP"
 )
 
+// listColumnsFor returns the default columns with PROFILE, ACCOUNT and REGION
+// inserted after NAME as requested.
+func listColumnsFor(profile, account, region bool) string {
+	columns := []string{"ID", "NAME"}
+	if profile {
+		columns = append(columns, "PROFILE")
+	}
+	if account {
+		columns = append(columns, "ACCOUNT")
+	}
+	if region {
+		columns = append(columns, "REGION")
+	}
+	columns = append(columns, "STATE", "PRIVATE-IP", "PUBLIC-IP")
+	return strings.Join(columns, ",")
+}
+
 const listPadding = 2
 
 // ListOptions holds the parsed configuration for listing instances.
 type ListOptions struct {
-	Region  string `long:"region"`
-	Profile string `long:"profile"`
-	Columns string `long:"list-columns"`
-	Debug   bool   `long:"debug"`
+	Region      string             `long:"region"`
+	Regions     *ec2client.Regions `long:"regions"` // nil = Region only
+	Profile     string             `long:"profile"`
+	Profiles    string             `long:"profiles"` // Glob over configured profiles
+	AllProfiles bool               `long:"all-profiles"`
+	Filters     ec2client.Filters  `long:"filter"`    // EC2 API filters, repeatable
+	State       *ec2client.States  `long:"state"`     // nil = any state
+	PageSize    ec2client.PageSize `long:"page-size"` // 0 = ec2client.DefaultPageSize
+	Columns     string             `long:"list-columns"`
+	Sort        *ListSort          `long:"sort"`       // nil = API order
+	GroupBy     *ListColumn        `long:"group-by"`   // nil = one row per instance
+	Output      OutputFormat       `long:"output"`     // zero = table
+	NoHeaders   bool               `long:"no-headers"` // table, csv and tsv only
+	Debug       bool               `long:"debug"`
+
+	// Parsed values
+	Query *regexp.Regexp // From the optional positional query, nil = all instances
 }
 
 // NewListOptions creates ListOptions from command-line arguments.
@@ -42,9 +75,23 @@ func NewListOptions(args []string) (*ListOptions, error) {
 		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
 	}
 
-	// List doesn't accept positional arguments
-	if len(positional) > 0 {
-		return nil, fmt.Errorf("%w: unexpected argument %s", ErrUsage, positional[0])
+	// A single positional argument narrows the listing client-side
+	if len(positional) > 1 {
+		return nil, fmt.Errorf("%w: unexpected argument %s", ErrUsage, positional[1])
+	}
+	if len(positional) == 1 {
+		options.Query, err = parseListQuery(positional[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+	}
+
+	if err := validateProfileFlags(options.Profile, options.Profiles, options.AllProfiles); err != nil {
+		return nil, err
+	}
+
+	if options.GroupBy != nil && (options.Sort != nil || options.Columns != "") {
+		return nil, fmt.Errorf("%w: --group-by cannot be combined with --sort or --list-columns", ErrUsage)
 	}
 
 	return &options, nil
@@ -57,7 +104,14 @@ func RunList(args []string) error {
 		return err
 	}
 
-	columns, err := parseListColumns(options.Columns)
+	pattern := profilePattern(options.Profiles, options.AllProfiles)
+
+	requestedColumns := options.Columns
+	if requestedColumns == "" {
+		requestedColumns = listColumnsFor(pattern != "", pattern != "", options.Regions != nil)
+	}
+
+	columns, err := parseListColumns(requestedColumns)
 	if err != nil {
 		return fmt.Errorf("%w: invalid list columns: %v", ErrUsage, err)
 	}
@@ -67,86 +121,139 @@ func RunList(args []string) error {
 		logger.SetOutput(os.Stderr)
 	}
 
-	cfg, err := awsclient.LoadConfig(options.Region, options.Profile, logger)
+	clients, err := newSearchClients(options.Region, options.Profile, pattern, options.Regions, logger)
 	if err != nil {
 		return err
 	}
 
-	client, err := ec2client.NewClient(cfg, logger)
+	instances, err := ec2client.ListInstancesInRegions(clients, options.listQuery())
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to list instances: %w", err)
 	}
 
-	instances, err := client.ListInstances()
-	if err != nil {
-		return fmt.Errorf("unable to list instances: %w", err)
+	if options.Query != nil {
+		instances = slices.DeleteFunc(instances, func(instance ec2client.RegionalInstance) bool {
+			return !matchListQuery(options.Query, instance.Instance)
+		})
 	}
 
-	return writeInstanceList(os.Stdout, instances, columns)
-}
+	// Account IDs cost an STS call per profile and connectivity several calls
+	// per region, so look them up only when used
+	var lookups listLookups
+	used := options.usedColumns(columns)
+	if slices.Contains(used, "ACCOUNT") {
+		lookups.accounts, err = lookupAccounts(clients, logger)
+		if err != nil {
+			return err
+		}
+	}
+	if slices.ContainsFunc(used, func(column string) bool { return slices.Contains(connectivityColumns, column) }) {
+		lookups.connectivity, err = lookupConnectivity(clients, instances)
+		if err != nil {
+			return err
+		}
+	}
 
-func parseListColumns(requestedColumns string) ([]string, error) {
-	if requestedColumns == "" {
-		requestedColumns = defaultListColumns
+	if options.GroupBy != nil {
+		column := string(*options.GroupBy)
+		rows := groupRows(instances, column, lookups)
+		return writeRows(os.Stdout, options.Output, []string{column, countColumn}, rows, !options.NoHeaders)
 	}
 
-	requestedColumns = strings.ToUpper(requestedColumns)
-	requestedColumns = strings.ReplaceAll(requestedColumns, " ", "")
+	if options.Sort != nil {
+		sortInstances(instances, *options.Sort, lookups)
+	}
 
-	columns := strings.Split(requestedColumns, ",")
+	rows := instanceRows(instances, columns, lookups)
 
-	for _, column := range columns {
-		if !slices.Contains(allowedListColumns, column) {
-			return nil, fmt.Errorf("invalid column %s", column)
-		}
+	return writeRows(os.Stdout, options.Output, columns, rows, !options.NoHeaders)
+}
+
+// usedColumns returns the displayed columns plus those used for sorting and grouping.
+func (o *ListOptions) usedColumns(columns []string) []string {
+	used := slices.Clone(columns)
+	if o.Sort != nil {
+		used = append(used, o.Sort.Column)
+	}
+	if o.GroupBy != nil {
+		used = append(used, string(*o.GroupBy))
 	}
+	return used
+}
 
-	return columns, nil
+// listQuery returns the server-side part of the listing options.
+func (o *ListOptions) listQuery() ec2client.ListQuery {
+	filters := slices.Clone(o.Filters.List)
+	if o.State != nil {
+		filters = append(filters, o.State.Filter())
+	}
+	return ec2client.ListQuery{Filters: filters, PageSize: o.PageSize}
 }
 
-func writeInstanceList(w io.Writer, instances []types.Instance, columns []string) error {
-	writer := tabwriter.NewWriter(w, 0, 1, listPadding, ' ', 0)
-	_, _ = fmt.Fprintln(writer, strings.Join(columns, "\t"))
+// parseListQuery compiles the positional ec2list query. A query wrapped in
+// slashes is a regular expression; anything else is a case-insensitive substring.
+func parseListQuery(query string) (*regexp.Regexp, error) {
+	if len(query) > 2 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/") {
+		re, err := regexp.Compile(query[1 : len(query)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		return re, nil
+	}
+	return regexp.MustCompile("(?i)" + regexp.QuoteMeta(query)), nil
+}
 
-	for _, instance := range instances {
-		var state string
-		if instance.State != nil {
-			state = string(instance.State.Name)
+// matchListQuery reports whether the query matches the instance's name, ID or any IP address.
+func matchListQuery(query *regexp.Regexp, instance types.Instance) bool {
+	for _, value := range []*string{
+		ec2client.GetInstanceName(instance),
+		instance.InstanceId,
+		instance.PrivateIpAddress,
+		instance.PublicIpAddress,
+		instance.Ipv6Address,
+	} {
+		if value != nil && query.MatchString(*value) {
+			return true
 		}
+	}
+	return false
+}
 
-		typ := string(instance.InstanceType)
+func parseListColumns(requestedColumns string) ([]string, error) {
+	if requestedColumns == "" {
+		requestedColumns = defaultListColumns
+	}
 
-		var az *string
-		if instance.Placement != nil {
-			az = instance.Placement.AvailabilityZone
-		}
+	var columns []string
 
-		values := map[string]*string{
-			"ID":          instance.InstanceId,
-			"NAME":        ec2client.GetInstanceName(instance),
-			"STATE":       &state,
-			"TYPE":        &typ,
-			"AZ":          az,
-			"PRIVATE-IP":  instance.PrivateIpAddress,
-			"PUBLIC-IP":   instance.PublicIpAddress,
-			"IPV6":        instance.Ipv6Address,
-			"PRIVATE-DNS": instance.PrivateDnsName,
-			"PUBLIC-DNS":  instance.PublicDnsName,
+	for _, column := range strings.Split(requestedColumns, ",") {
+		column, err := parseListColumn(column)
+		if err != nil {
+			return nil, err
 		}
+		columns = append(columns, column)
+	}
 
-		var rows []string
+	return columns, nil
+}
+
+// writeInstanceList writes instances as a table. Columns without lookups are empty.
+func writeInstanceList(w io.Writer, instances []ec2client.RegionalInstance, columns []string, lookups listLookups) error {
+	return writeRows(w, OutputTable, columns, instanceRows(instances, columns, lookups), true)
+}
 
-		for _, column := range columns {
-			value := "-"
-			if values[column] != nil && *(values[column]) != "" {
-				value = *values[column]
-			}
+// instanceRows returns the column values of every instance.
+// Missing values are nil so that structured output can tell them apart.
+func instanceRows(instances []ec2client.RegionalInstance, columns []string, lookups listLookups) [][]any {
+	rows := make([][]any, 0, len(instances))
 
-			rows = append(rows, value)
+	for _, instance := range instances {
+		row := make([]any, len(columns))
+		for i, column := range columns {
+			row[i] = listValue(instance, column, lookups)
 		}
-
-		_, _ = fmt.Fprintln(writer, strings.Join(rows, "\t"))
+		rows = append(rows, row)
 	}
 
-	return writer.Flush()
+	return rows
 }
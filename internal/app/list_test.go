@@ -7,6 +7,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,6 +20,17 @@ func TestNewListOptions(t *testing.T) {
 		wantRegion  string
 		wantProfile string
 		wantColumns string
+		wantRegions *ec2client.Regions
+		wantProfs   string
+		wantAllProf bool
+		wantFilters []types.Filter
+		wantState   *ec2client.States
+		wantPage    ec2client.PageSize
+		wantQuery   string
+		wantSort    *ListSort
+		wantGroupBy *ListColumn
+		wantOutput  OutputFormat
+		wantNoHead  bool
 		wantDebug   bool
 		wantErr     bool
 		errContains string
@@ -35,6 +47,62 @@ func TestNewListOptions(t *testing.T) {
 			args:        []string{"--profile", "myprofile"},
 			wantProfile: "myprofile",
 		},
+		"with regions list": {
+			args:        []string{"--regions", "us-east-1,eu-west-1"},
+			wantRegions: &ec2client.Regions{Names: []string{"us-east-1", "eu-west-1"}},
+		},
+		"with all regions": {
+			args:        []string{"--regions", "all"},
+			wantRegions: &ec2client.Regions{All: true},
+		},
+		"with profiles": {
+			args:      []string{"--profiles", "prod-*"},
+			wantProfs: "prod-*",
+		},
+		"with all profiles": {
+			args:        []string{"--all-profiles"},
+			wantAllProf: true,
+		},
+		"with filters": {
+			args: []string{"--filter", "tag:Env=prod", "--filter", "instance-type=t3.micro,t3.small"},
+			wantFilters: []types.Filter{
+				{Name: aws.String("tag:Env"), Values: []string{"prod"}},
+				{Name: aws.String("instance-type"), Values: []string{"t3.micro", "t3.small"}},
+			},
+		},
+		"with state": {
+			args:      []string{"--state", "running,stopped"},
+			wantState: &ec2client.States{Names: []string{"running", "stopped"}},
+		},
+		"with page size": {
+			args:     []string{"--page-size", "100"},
+			wantPage: 100,
+		},
+		"with substring query": {
+			args:      []string{"web"},
+			wantQuery: "(?i)web",
+		},
+		"with regex query": {
+			args:      []string{"/^web-[0-9]+$/"},
+			wantQuery: "^web-[0-9]+$",
+		},
+		"with sort": {
+			args:     []string{"--sort", "-uptime"},
+			wantSort: &ListSort{Column: "UPTIME", Descending: true},
+		},
+		"with group by": {
+			args:        []string{"--group-by", "tag:Env"},
+			wantGroupBy: func() *ListColumn { c := ListColumn("TAG:Env"); return &c }(),
+		},
+		"with output": {
+			args:       []string{"--output", "jsonl"},
+			wantOutput: OutputJSONL,
+		},
+		"with no headers": {
+			args:       []string{"--output", "csv", "--no-headers"},
+			wantOutput: OutputCSV,
+			wantNoHead: true,
+		},
 		"with columns": {
 			args:        []string{"--list-columns", "ID,NAME"},
 			wantColumns: "ID,NAME",
@@ -53,9 +121,54 @@ func TestNewListOptions(t *testing.T) {
 
 		// Error cases
 		"unexpected positional": {
-			args:        []string{"extra"},
+			args:        []string{"web", "extra"},
+			wantErr:     true,
+			errContains: "unexpected argument extra",
+		},
+		"invalid regex query": {
+			args:        []string{"/web[/"},
+			wantErr:     true,
+			errContains: "invalid query",
+		},
+		"invalid filter": {
+			args:        []string{"--filter", "running"},
+			wantErr:     true,
+			errContains: "invalid filter",
+		},
+		"invalid state": {
+			args:        []string{"--state", "asleep"},
+			wantErr:     true,
+			errContains: "unknown instance state",
+		},
+		"invalid sort column": {
+			args:        []string{"--sort", "color"},
+			wantErr:     true,
+			errContains: "invalid column",
+		},
+		"group by with sort": {
+			args:        []string{"--group-by", "type", "--sort", "name"},
+			wantErr:     true,
+			errContains: "--group-by cannot be combined",
+		},
+		"invalid output": {
+			args:        []string{"--output", "xml"},
+			wantErr:     true,
+			errContains: "unknown output format",
+		},
+		"invalid page size": {
+			args:        []string{"--page-size", "2000"},
+			wantErr:     true,
+			errContains: "invalid page size",
+		},
+		"empty region in list": {
+			args:        []string{"--regions", "us-east-1,"},
+			wantErr:     true,
+			errContains: "invalid region list",
+		},
+		"profile with profiles": {
+			args:        []string{"--profile", "dev", "--profiles", "prod-*"},
 			wantErr:     true,
-			errContains: "unexpected argument",
+			errContains: "--profile cannot be combined",
 		},
 		"unknown flag": {
 			args:        []string{"--unknown"},
@@ -84,6 +197,22 @@ func TestNewListOptions(t *testing.T) {
 			assert.Equal(t, tc.wantRegion, options.Region)
 			assert.Equal(t, tc.wantProfile, options.Profile)
 			assert.Equal(t, tc.wantColumns, options.Columns)
+			assert.Equal(t, tc.wantRegions, options.Regions)
+			assert.Equal(t, tc.wantProfs, options.Profiles)
+			assert.Equal(t, tc.wantAllProf, options.AllProfiles)
+			assert.Equal(t, tc.wantFilters, options.Filters.List)
+			assert.Equal(t, tc.wantState, options.State)
+			assert.Equal(t, tc.wantPage, options.PageSize)
+			assert.Equal(t, tc.wantSort, options.Sort)
+			assert.Equal(t, tc.wantGroupBy, options.GroupBy)
+			assert.Equal(t, tc.wantOutput, options.Output)
+			assert.Equal(t, tc.wantNoHead, options.NoHeaders)
+			if tc.wantQuery == "" {
+				assert.Nil(t, options.Query)
+			} else {
+				require.NotNil(t, options.Query)
+				assert.Equal(t, tc.wantQuery, options.Query.String())
+			}
 			assert.Equal(t, tc.wantDebug, options.Debug)
 		})
 	}
@@ -110,8 +239,8 @@ func TestParseListColumns(t *testing.T) {
 			want:  []string{"ID", "NAME", "STATE"},
 		},
 		"all columns": {
-			input: "ID,NAME,STATE,TYPE,AZ,PRIVATE-IP,PUBLIC-IP,IPV6,PRIVATE-DNS,PUBLIC-DNS",
-			want:  []string{"ID", "NAME", "STATE", "TYPE", "AZ", "PRIVATE-IP", "PUBLIC-IP", "IPV6", "PRIVATE-DNS", "PUBLIC-DNS"},
+			input: "ID,NAME,STATE,TYPE,AZ,REGION,PROFILE,ACCOUNT,PRIVATE-IP,PUBLIC-IP,IPV6,PRIVATE-DNS,PUBLIC-DNS",
+			want:  []string{"ID", "NAME", "STATE", "TYPE", "AZ", "REGION", "PROFILE", "ACCOUNT", "PRIVATE-IP", "PUBLIC-IP", "IPV6", "PRIVATE-DNS", "PUBLIC-DNS"},
 		},
 		"case insensitive - lowercase": {
 			input: "id,name,state",
@@ -121,6 +250,18 @@ func TestParseListColumns(t *testing.T) {
 			input: "Id,NaMe,STATE",
 			want:  []string{"ID", "NAME", "STATE"},
 		},
+		"tag column": {
+			input: "ID,tag:Env,NAME",
+			want:  []string{"ID", "TAG:Env", "NAME"},
+		},
+		"computed columns": {
+			input: "vpc,uptime,security-groups",
+			want:  []string{"VPC", "UPTIME", "SECURITY-GROUPS"},
+		},
+		"connectivity columns": {
+			input: "ID,connect,ssm-ping,eice-id",
+			want:  []string{"ID", "CONNECT", "SSM-PING", "EICE-ID"},
+		},
 		"with spaces": {
 			input: "ID, NAME, STATE",
 			want:  []string{"ID", "NAME", "STATE"},
@@ -152,6 +293,90 @@ func TestParseListColumns(t *testing.T) {
 	}
 }
 
+func TestListOptions_ListQuery(t *testing.T) {
+	t.Parallel()
+
+	options, err := NewListOptions([]string{"--filter", "tag:Env=prod", "--state", "running", "--page-size", "50"})
+	require.NoError(t, err)
+
+	query := options.listQuery()
+
+	assert.Equal(t, ec2client.PageSize(50), query.PageSize)
+	assert.Equal(t, []types.Filter{
+		{Name: aws.String("tag:Env"), Values: []string{"prod"}},
+		{Name: aws.String("instance-state-name"), Values: []string{"running"}},
+	}, query.Filters)
+}
+
+func TestMatchListQuery(t *testing.T) {
+	t.Parallel()
+
+	instance := types.Instance{
+		InstanceId:       aws.String("i-0abc123"),
+		PrivateIpAddress: aws.String("10.0.1.5"),
+		PublicIpAddress:  aws.String("52.1.2.3"),
+		Ipv6Address:      aws.String("2001:db8::5"),
+		Tags:             []types.Tag{{Key: aws.String("Name"), Value: aws.String("Web-Server-01")}},
+	}
+
+	tests := map[string]struct {
+		query string
+		want  bool
+	}{
+		"name substring":          {query: "server", want: true},
+		"id substring":            {query: "0abc", want: true},
+		"private ip":              {query: "10.0.1", want: true},
+		"public ip":               {query: "52.1.2.3", want: true},
+		"ipv6":                    {query: "db8::5", want: true},
+		"dot is literal":          {query: "0abc.23", want: false},
+		"no match":                {query: "database", want: false},
+		"regex":                   {query: "/^Web-Server-[0-9]+$/", want: true},
+		"regex is case-sensitive": {query: "/^web/", want: false},
+		"regex on ip":             {query: `/^10\.0\.1\.\d+$/`, want: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := parseListQuery(tc.query)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, matchListQuery(query, instance))
+		})
+	}
+}
+
+func TestListColumnsFor(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		profile, account, region bool
+		want                     string
+	}{
+		"default":        {want: defaultListColumns},
+		"regions":        {region: true, want: "ID,NAME,REGION,STATE,PRIVATE-IP,PUBLIC-IP"},
+		"profiles":       {profile: true, account: true, want: "ID,NAME,PROFILE,ACCOUNT,STATE,PRIVATE-IP,PUBLIC-IP"},
+		"profile picker": {profile: true, region: true, want: "ID,NAME,PROFILE,REGION,STATE,PRIVATE-IP,PUBLIC-IP"},
+		"everything":     {profile: true, account: true, region: true, want: "ID,NAME,PROFILE,ACCOUNT,REGION,STATE,PRIVATE-IP,PUBLIC-IP"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, listColumnsFor(tc.profile, tc.account, tc.region))
+		})
+	}
+}
+
+// inRegion tags instances with the region they were listed in.
+func inRegion(region string, instances ...types.Instance) []ec2client.RegionalInstance {
+	regional := make([]ec2client.RegionalInstance, len(instances))
+	for i, instance := range instances {
+		regional[i] = ec2client.RegionalInstance{Instance: instance, Region: region}
+	}
+	return regional
+}
+
 func TestWriteInstanceList(t *testing.T) {
 	t.Parallel()
 
@@ -217,7 +442,7 @@ func TestWriteInstanceList(t *testing.T) {
 			t.Parallel()
 
 			var buf bytes.Buffer
-			err := writeInstanceList(&buf, tc.instances, tc.columns)
+			err := writeInstanceList(&buf, inRegion("us-east-1", tc.instances...), tc.columns, listLookups{})
 			require.NoError(t, err)
 
 			output := buf.String()
@@ -244,10 +469,10 @@ func TestWriteInstanceList_AllColumns(t *testing.T) {
 		Tags:             []types.Tag{{Key: aws.String("Name"), Value: aws.String("my-server")}},
 	}
 
-	columns := []string{"ID", "NAME", "STATE", "TYPE", "AZ", "PRIVATE-IP", "PUBLIC-IP", "IPV6", "PRIVATE-DNS", "PUBLIC-DNS"}
+	columns := []string{"ID", "NAME", "STATE", "TYPE", "AZ", "REGION", "PRIVATE-IP", "PUBLIC-IP", "IPV6", "PRIVATE-DNS", "PUBLIC-DNS"}
 
 	var buf bytes.Buffer
-	err := writeInstanceList(&buf, []types.Instance{inst}, columns)
+	err := writeInstanceList(&buf, inRegion("us-east-1", inst), columns, listLookups{})
 	require.NoError(t, err)
 
 	output := buf.String()
@@ -262,6 +487,7 @@ func TestWriteInstanceList_AllColumns(t *testing.T) {
 	assert.Contains(t, output, "running")
 	assert.Contains(t, output, "t2.micro")
 	assert.Contains(t, output, "us-east-1a")
+	assert.Contains(t, output, "us-east-1 ")
 	assert.Contains(t, output, "10.0.0.1")
 	assert.Contains(t, output, "52.0.0.1")
 	assert.Contains(t, output, "2001:db8::1")
@@ -276,3 +502,39 @@ func awsPtrOrNil(s string) *string {
 	}
 	return aws.String(s)
 }
+
+func TestWriteInstanceList_ProfileAndAccount(t *testing.T) {
+	t.Parallel()
+
+	instances := []ec2client.RegionalInstance{
+		{Instance: types.Instance{InstanceId: aws.String("i-a")}, Region: "us-east-1", Profile: "prod-a"},
+		{Instance: types.Instance{InstanceId: aws.String("i-b")}, Region: "us-east-1", Profile: "prod-b"},
+		{Instance: types.Instance{InstanceId: aws.String("i-c")}, Region: "us-east-1", Profile: "unknown"},
+	}
+	accounts := map[string]string{"prod-a": "111111111111", "prod-b": "222222222222"}
+
+	var buf bytes.Buffer
+	err := writeInstanceList(&buf, instances, []string{"ID", "PROFILE", "ACCOUNT"}, listLookups{accounts: accounts})
+	require.NoError(t, err)
+
+	assert.Regexp(t, `i-a\s+prod-a\s+111111111111`, buf.String())
+	assert.Regexp(t, `i-b\s+prod-b\s+222222222222`, buf.String())
+	assert.Regexp(t, `i-c\s+unknown\s+-`, buf.String())
+}
+
+func TestInstanceRows_MissingValuesAreNil(t *testing.T) {
+	t.Parallel()
+
+	instances := []ec2client.RegionalInstance{{
+		Instance: types.Instance{
+			InstanceId:       aws.String("i-1"),
+			PrivateIpAddress: aws.String("10.0.0.1"),
+			State:            &types.InstanceState{Name: types.InstanceStateNameRunning},
+		},
+		Region: "us-east-1",
+	}}
+
+	rows := instanceRows(instances, []string{"ID", "NAME", "STATE", "TYPE", "PRIVATE-IP", "PROFILE", "ACCOUNT"}, listLookups{})
+
+	assert.Equal(t, [][]any{{"i-1", nil, "running", nil, "10.0.0.1", nil, nil}}, rows)
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// imageLoginCachePath locates the cache of logins by AMI, overridden in tests.
+var imageLoginCachePath = defaultImageLoginCachePath
+
+// imageLoginRules map words in AMI names and descriptions to the login the
+// image is built with; the first match wins, and images matching none use
+// ec2-user like Amazon Linux, RHEL, SUSE, AlmaLinux and FreeBSD.
+var imageLoginRules = []struct {
+	word  string
+	login string
+}{
+	{"bitnami", "bitnami"}, // Before the distributions Bitnami builds on
+	{"ubuntu", "ubuntu"},
+	{"debian", "admin"},
+	{"centos stream", "ec2-user"},
+	{"centos-stream", "ec2-user"},
+	{"centos", "centos"},
+	{"coreos", "core"},
+	{"flatcar", "core"},
+	{"fedora", "fedora"},
+	{"rocky", "rocky"},
+	{"kali", "kali"},
+	{"alpine", "alpine"},
+	{"archlinux", "arch"},
+	{"arch-linux", "arch"},
+	{"windows", "Administrator"},
+}
+
+// loginForImage returns the conventional login of an AMI.
+func loginForImage(image types.Image) string {
+	if strings.Contains(aws.ToString(image.PlatformDetails), "Windows") {
+		return "Administrator"
+	}
+	text := strings.ToLower(aws.ToString(image.Name) + " " + aws.ToString(image.Description))
+	for _, rule := range imageLoginRules {
+		if strings.Contains(text, rule.word) {
+			return rule.login
+		}
+	}
+	return "ec2-user"
+}
+
+// platformLogin guesses the login from the instance's platform alone, for
+// instances whose AMI cannot be described: Administrator on Windows, ubuntu
+// on Ubuntu Pro, otherwise ec2-user.
+func platformLogin(instance types.Instance) string {
+	platform := aws.ToString(instance.PlatformDetails)
+	switch {
+	case strings.Contains(platform, "Windows"):
+		return "Administrator"
+	case strings.Contains(platform, "Ubuntu"):
+		return "ubuntu"
+	default:
+		return "ec2-user"
+	}
+}
+
+// loginMap holds logins by instance ID, as found by instanceLogins.
+type loginMap map[string]string
+
+// login returns the instance's login from its ec2ssh:user tag, the map, or
+// else its platform.
+func (m loginMap) login(instance types.Instance) string {
+	if login := aws.ToString(ec2client.GetInstanceTag(instance, tagUser)); login != "" {
+		return login
+	}
+	if login, ok := m[aws.ToString(instance.InstanceId)]; ok {
+		return login
+	}
+	return platformLogin(instance)
+}
+
+// instanceLogins finds the login of each instance from its AMI, looking up
+// images per location with the client that found them. AMIs are cached, as
+// their names never change. Instances whose AMI is gone or cannot be
+// described are left out.
+func instanceLogins(clients []*ec2client.Client, instances []ec2client.RegionalInstance, logger *log.Logger) loginMap {
+	cache := loadImageLoginCache(logger)
+	defer cache.save(logger)
+
+	logins := make(loginMap, len(instances))
+	for _, client := range clients {
+		var uncached []string
+		for _, instance := range instances {
+			imageID := aws.ToString(instance.ImageId)
+			if imageID == "" || instance.Region != client.Region() || instance.Profile != client.Profile() {
+				continue
+			}
+			if _, ok := cache.get(client.Region(), imageID); !ok && !slices.Contains(uncached, imageID) {
+				uncached = append(uncached, imageID)
+			}
+		}
+
+		if len(uncached) > 0 {
+			images, err := client.DescribeImages(uncached)
+			if err != nil {
+				logger.Printf("unable to describe images in %s, guessing logins from platform: %v", client.Location(), err)
+			}
+			for imageID, image := range images {
+				cache.put(client.Region(), imageID, loginForImage(image))
+			}
+		}
+
+		for _, instance := range instances {
+			if instance.Region != client.Region() || instance.Profile != client.Profile() {
+				continue
+			}
+			if login, ok := cache.get(client.Region(), aws.ToString(instance.ImageId)); ok {
+				logins[aws.ToString(instance.InstanceId)] = login
+			}
+		}
+	}
+	return logins
+}
+
+// imageLoginCache is the on-disk cache of logins by region and AMI ID.
+type imageLoginCache struct {
+	path   string // Empty when there is nowhere to keep the cache
+	logins map[string]string
+	dirty  bool
+}
+
+// defaultImageLoginCachePath returns the cache file in the user cache directory.
+func defaultImageLoginCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ec2ssh", "image-logins.json"), nil
+}
+
+// loadImageLoginCache reads the cache, starting empty if it is missing or unreadable.
+func loadImageLoginCache(logger *log.Logger) *imageLoginCache {
+	cache := &imageLoginCache{logins: make(map[string]string)}
+
+	path, err := imageLoginCachePath()
+	if err != nil {
+		logger.Printf("not caching image logins: %v", err)
+		return cache
+	}
+	cache.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Printf("ignoring image login cache: %v", err)
+		}
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache.logins); err != nil {
+		logger.Printf("ignoring image login cache %s: %v", path, err)
+		cache.logins = make(map[string]string)
+	}
+	return cache
+}
+
+// get returns the cached login of an AMI.
+func (c *imageLoginCache) get(region, imageID string) (string, bool) {
+	login, ok := c.logins[region+"/"+imageID]
+	return login, ok
+}
+
+// put records the login of an AMI.
+func (c *imageLoginCache) put(region, imageID, login string) {
+	c.logins[region+"/"+imageID] = login
+	c.dirty = true
+}
+
+// save writes the cache back if it changed. Failures only cost another lookup.
+func (c *imageLoginCache) save(logger *log.Logger) {
+	if !c.dirty || c.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(c.logins, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(c.path), 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(c.path, data, 0o600)
+	}
+	if err != nil {
+		logger.Printf("unable to save image login cache: %v", err)
+	}
+}
+
+// defaultLogin returns the login for the session's instance when none is
+// given, from its AMI or else its platform, and where it came from.
+func (s *baseSSHSession) defaultLogin() (login, source string) {
+	instance := ec2client.RegionalInstance{Instance: s.instance, Region: s.client.Region(), Profile: s.client.Profile()}
+	imageID := aws.ToString(s.instance.ImageId)
+
+	if login, ok := instanceLogins([]*ec2client.Client{s.client}, []ec2client.RegionalInstance{instance}, s.logger)[*s.instance.InstanceId]; ok {
+		return login, fmt.Sprintf("AMI %s", imageID)
+	}
+	return platformLogin(s.instance), fmt.Sprintf("platform %q", aws.ToString(s.instance.PlatformDetails))
+}
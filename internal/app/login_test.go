@@ -0,0 +1,223 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginForImage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		image types.Image
+		want  string
+	}{
+		"amazon linux":  {image: types.Image{Name: aws.String("al2023-ami-2023.5.20240819.0-kernel-6.1-x86_64")}, want: "ec2-user"},
+		"ubuntu":        {image: types.Image{Name: aws.String("ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240801")}, want: "ubuntu"},
+		"debian":        {image: types.Image{Name: aws.String("debian-12-amd64-20240717-1811")}, want: "admin"},
+		"rhel":          {image: types.Image{Name: aws.String("RHEL-9.4.0_HVM-20240605-x86_64-82-Hourly2-GP3")}, want: "ec2-user"},
+		"centos 7":      {image: types.Image{Name: aws.String("CentOS Linux 7 x86_64 HVM EBS ENA 2002_01")}, want: "centos"},
+		"centos stream": {image: types.Image{Name: aws.String("CentOS Stream 9 x86_64 20240805")}, want: "ec2-user"},
+		"fedora":        {image: types.Image{Name: aws.String("Fedora-Cloud-Base-40-1.14.x86_64-hvm-us-east-1-gp3-0")}, want: "fedora"},
+		"fedora coreos": {image: types.Image{Name: aws.String("fedora-coreos-40.20240728.3.0-x86_64")}, want: "core"},
+		"rocky":         {image: types.Image{Name: aws.String("Rocky-9-EC2-Base-9.4-20240523.0.x86_64")}, want: "rocky"},
+		"bitnami":       {image: types.Image{Name: aws.String("bitnami-wordpress-6.6.1-0-r05-linux-debian-12-x86_64-hvm-ebs-nami")}, want: "bitnami"},
+		"description":   {image: types.Image{Name: aws.String("golden-2024-08"), Description: aws.String("Debian 12 with our agents")}, want: "admin"},
+		"windows":       {image: types.Image{Name: aws.String("Windows_Server-2022-English-Full-Base"), PlatformDetails: aws.String("Windows")}, want: "Administrator"},
+		"unknown":       {image: types.Image{Name: aws.String("my-app-2024-08-01")}, want: "ec2-user"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, loginForImage(tc.image))
+		})
+	}
+}
+
+func TestPlatformLogin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		platform *string
+		want     string
+	}{
+		"linux":      {platform: aws.String("Linux/UNIX"), want: "ec2-user"},
+		"rhel":       {platform: aws.String("Red Hat Enterprise Linux"), want: "ec2-user"},
+		"ubuntu pro": {platform: aws.String("Ubuntu Pro"), want: "ubuntu"},
+		"windows":    {platform: aws.String("Windows with SQL Server Standard"), want: "Administrator"},
+		"unknown":    {platform: nil, want: "ec2-user"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, platformLogin(types.Instance{PlatformDetails: tc.platform}))
+		})
+	}
+}
+
+// withImage sets the instance's AMI.
+func withImage(imageID string) func(*types.Instance) {
+	return func(i *types.Instance) { i.ImageId = aws.String(imageID) }
+}
+
+// useImageLoginCache points the image login cache at a temporary file.
+func useImageLoginCache(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ec2ssh", "image-logins.json")
+	orig := imageLoginCachePath
+	t.Cleanup(func() { imageLoginCachePath = orig })
+	imageLoginCachePath = func() (string, error) { return path, nil }
+	return path
+}
+
+func TestInstanceLogins(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+	cachePath := useImageLoginCache(t)
+	logger := log.New(io.Discard, "", 0)
+
+	usMock := new(mockEC2API)
+	usMock.On("DescribeImages", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeImagesInput) bool {
+		return assert.ObjectsAreEqual([]string{"ami-debian", "ami-gone"}, input.Filters[0].Values)
+	})).Return(&ec2.DescribeImagesOutput{Images: []types.Image{
+		{ImageId: aws.String("ami-debian"), Name: aws.String("debian-12-amd64")},
+	}}, nil).Once()
+	euMock := new(mockEC2API)
+	euMock.On("DescribeImages", mock.Anything, mock.Anything).Return(nil, errors.New("UnauthorizedOperation")).Once()
+
+	clients := []*ec2client.Client{
+		ec2client.NewTestClientInRegion("us-east-1", usMock, nil, nil),
+		ec2client.NewTestClientInRegion("eu-west-1", euMock, nil, nil),
+	}
+	instances := append(
+		inRegion("us-east-1",
+			ec2client.MakeInstance("i-1", withImage("ami-debian")),
+			ec2client.MakeInstance("i-2", withImage("ami-debian")),
+			ec2client.MakeInstance("i-3", withImage("ami-gone")),
+			ec2client.MakeInstance("i-4"),
+		),
+		inRegion("eu-west-1", ec2client.MakeInstance("i-5", withImage("ami-ubuntu")))...,
+	)
+
+	logins := instanceLogins(clients, instances, logger)
+
+	assert.Equal(t, loginMap{"i-1": "admin", "i-2": "admin"}, logins)
+	usMock.AssertExpectations(t)
+	euMock.AssertExpectations(t)
+
+	data, err := os.ReadFile(cachePath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"us-east-1/ami-debian": "admin"}`, string(data))
+
+	// Cached images are not described again
+	logins = instanceLogins(clients[:1], instances[:2], logger)
+	assert.Equal(t, loginMap{"i-1": "admin", "i-2": "admin"}, logins)
+	usMock.AssertNumberOfCalls(t, "DescribeImages", 1)
+}
+
+func TestLoginMap_Login(t *testing.T) {
+	t.Parallel()
+
+	logins := loginMap{"i-1": "admin"}
+
+	assert.Equal(t, "admin", logins.login(ec2client.MakeInstance("i-1")))
+	assert.Equal(t, "ec2-user", logins.login(ec2client.MakeInstance("i-2")))
+	assert.Equal(t, "ubuntu", loginMap(nil).login(ec2client.MakeInstance("i-3", func(i *types.Instance) {
+		i.PlatformDetails = aws.String("Ubuntu Pro")
+	})))
+	// The ec2ssh:user tag wins over the AMI
+	assert.Equal(t, "deploy", logins.login(ec2client.MakeInstance("i-1", ec2client.WithTag("ec2ssh:user", "deploy"))))
+}
+
+func TestSSHSession_Run_DefaultLogin(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	tests := map[string]struct {
+		args         []string
+		images       []types.Image
+		wantLogin    string
+		wantUserArg  bool
+		wantDescribe bool
+		wantNoPush   bool
+	}{
+		"from ami": {
+			args:         []string{"i-1234567890abcdef0"},
+			images:       []types.Image{{ImageId: aws.String("ami-1"), Name: aws.String("debian-12-amd64")}},
+			wantLogin:    "admin",
+			wantUserArg:  true,
+			wantDescribe: true,
+		},
+		"ami gone": {
+			args:         []string{"i-1234567890abcdef0"},
+			wantLogin:    "ec2-user",
+			wantUserArg:  true,
+			wantDescribe: true,
+		},
+		"user in destination": {
+			args:      []string{"rocky@i-1234567890abcdef0"},
+			wantLogin: "rocky",
+		},
+		"-l flag": {
+			args:      []string{"-l", "centos", "i-1234567890abcdef0"},
+			wantLogin: "centos",
+		},
+		"no key push": {
+			args:         []string{"--no-send-keys", "i-1234567890abcdef0"},
+			images:       []types.Image{{ImageId: aws.String("ami-1"), Name: aws.String("debian-12-amd64")}},
+			wantLogin:    "admin",
+			wantUserArg:  true,
+			wantDescribe: true,
+			wantNoPush:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			instance := testInstance
+			instance.ImageId = aws.String("ami-1")
+
+			var captured commandCapture
+			ec2Mock, connectMock := setupMocksForRun(t, instance, &captured)
+			ec2Mock.On("DescribeImages", mock.Anything, mock.Anything).Return(&ec2.DescribeImagesOutput{Images: tc.images}, nil)
+			connectMock.ExpectedCalls = nil
+			connectMock.On("SendSSHPublicKey", mock.Anything, mock.MatchedBy(func(input *ec2instanceconnect.SendSSHPublicKeyInput) bool {
+				return *input.InstanceOSUser == tc.wantLogin
+			})).Return(&ec2instanceconnect.SendSSHPublicKeyOutput{Success: true}, nil)
+
+			session, err := NewSSHSession(tc.args)
+			require.NoError(t, err)
+			require.NoError(t, session.Run())
+
+			if tc.wantNoPush {
+				connectMock.AssertNotCalled(t, "SendSSHPublicKey", mock.Anything, mock.Anything)
+			} else {
+				connectMock.AssertExpectations(t)
+			}
+			if tc.wantDescribe {
+				ec2Mock.AssertCalled(t, "DescribeImages", mock.Anything, mock.Anything)
+			} else {
+				ec2Mock.AssertNotCalled(t, "DescribeImages", mock.Anything, mock.Anything)
+			}
+			if tc.wantUserArg {
+				assert.Contains(t, captured.args, "-oUser="+tc.wantLogin)
+			} else {
+				assert.NotContains(t, captured.args, "-oUser="+tc.wantLogin)
+			}
+		})
+	}
+}
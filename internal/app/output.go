@@ -0,0 +1,220 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat selects how ec2list writes instances.
+// The zero value is the aligned table.
+type OutputFormat int
+
+const (
+	OutputTable OutputFormat = iota
+	OutputJSON
+	OutputJSONL
+	OutputCSV
+	OutputTSV
+	OutputYAML
+)
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+func (f *OutputFormat) UnmarshalText(text []byte) error {
+	formats := map[string]OutputFormat{
+		"table": OutputTable,
+		"json":  OutputJSON,
+		"jsonl": OutputJSONL,
+		"csv":   OutputCSV,
+		"tsv":   OutputTSV,
+		"yaml":  OutputYAML,
+	}
+	format, ok := formats[string(text)]
+	if !ok {
+		return fmt.Errorf("unknown output format: %s", text)
+	}
+	*f = format
+	return nil
+}
+
+// tableValue is implemented by values that read differently in tables than
+// in other formats, e.g. durations that are plain numbers elsewhere.
+type tableValue interface {
+	TableString() string
+}
+
+// columnKey returns the stable field name of a column in structured output,
+// e.g. "PRIVATE-IP" → "private_ip". Tag keys keep their case: "TAG:Env" → "tag:Env".
+func columnKey(column string) string {
+	if key, ok := strings.CutPrefix(column, tagColumnPrefix); ok {
+		return "tag:" + key
+	}
+	return strings.ReplaceAll(strings.ToLower(column), "-", "_")
+}
+
+// writeRows writes rows of column values in the given format. A nil value is
+// missing: "-" in tables, empty in CSV/TSV and null in JSON/YAML.
+// headers only affects table, CSV and TSV output.
+func writeRows(w io.Writer, format OutputFormat, columns []string, rows [][]any, headers bool) error {
+	switch format {
+	case OutputTable:
+		return writeTable(w, columns, rows, headers)
+	case OutputCSV:
+		return writeDelimited(w, ',', columns, rows, headers)
+	case OutputTSV:
+		return writeDelimited(w, '\t', columns, rows, headers)
+	case OutputJSON:
+		return writeJSON(w, columns, rows)
+	case OutputJSONL:
+		return writeJSONLines(w, columns, rows)
+	case OutputYAML:
+		return writeYAML(w, columns, rows)
+	default:
+		panic(fmt.Sprintf("unexpected OutputFormat: %d", format))
+	}
+}
+
+func writeTable(w io.Writer, columns []string, rows [][]any, headers bool) error {
+	writer := tabwriter.NewWriter(w, 0, 1, listPadding, ' ', 0)
+	if headers {
+		_, _ = fmt.Fprintln(writer, strings.Join(columns, "\t"))
+	}
+
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			switch value := value.(type) {
+			case nil:
+				cells[i] = "-"
+			case tableValue:
+				cells[i] = value.TableString()
+			default:
+				cells[i] = fmt.Sprint(value)
+			}
+		}
+		_, _ = fmt.Fprintln(writer, strings.Join(cells, "\t"))
+	}
+
+	return writer.Flush()
+}
+
+func writeDelimited(w io.Writer, comma rune, columns []string, rows [][]any, headers bool) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+
+	if headers {
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			if value != nil {
+				cells[i] = fmt.Sprint(value)
+			}
+		}
+		if err := writer.Write(cells); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// jsonObject encodes a row as a JSON object with keys in column order.
+func jsonObject(columns []string, row []any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, column := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(columnKey(column))
+		value, err := json.Marshal(row[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func writeJSON(w io.Writer, columns []string, rows [][]any) error {
+	var compact bytes.Buffer
+	compact.WriteByte('[')
+	for i, row := range rows {
+		if i > 0 {
+			compact.WriteByte(',')
+		}
+		object, err := jsonObject(columns, row)
+		if err != nil {
+			return err
+		}
+		compact.Write(object)
+	}
+	compact.WriteByte(']')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+
+	_, err := indented.WriteTo(w)
+	return err
+}
+
+func writeJSONLines(w io.Writer, columns []string, rows [][]any) error {
+	for _, row := range rows {
+		object, err := jsonObject(columns, row)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeYAML(w io.Writer, columns []string, rows [][]any) error {
+	// Build nodes by hand so mappings keep column order
+	document := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, row := range rows {
+		mapping := &yaml.Node{Kind: yaml.MappingNode}
+		for i, column := range columns {
+			var value yaml.Node
+			if err := value.Encode(row[i]); err != nil {
+				return err
+			}
+			mapping.Content = append(mapping.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: columnKey(column)},
+				&value,
+			)
+		}
+		document.Content = append(document.Content, mapping)
+	}
+
+	if len(rows) == 0 {
+		document.Style = yaml.FlowStyle
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputFormat_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    OutputFormat
+		wantErr bool
+	}{
+		"table":   {input: "table", want: OutputTable},
+		"json":    {input: "json", want: OutputJSON},
+		"jsonl":   {input: "jsonl", want: OutputJSONL},
+		"csv":     {input: "csv", want: OutputCSV},
+		"tsv":     {input: "tsv", want: OutputTSV},
+		"yaml":    {input: "yaml", want: OutputYAML},
+		"unknown": {input: "xml", wantErr: true},
+		"empty":   {input: "", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got OutputFormat
+			err := got.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown output format")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWriteRows(t *testing.T) {
+	t.Parallel()
+
+	columns := []string{"ID", "NAME", "PRIVATE-IP"}
+	rows := [][]any{
+		{"i-1", "web server, blue", "10.0.0.1"},
+		{"i-2", nil, "10.0.0.2"},
+	}
+
+	tests := map[string]struct {
+		format  OutputFormat
+		headers bool
+		want    string
+	}{
+		"table": {
+			format:  OutputTable,
+			headers: true,
+			want: "ID   NAME              PRIVATE-IP\n" +
+				"i-1  web server, blue  10.0.0.1\n" +
+				"i-2  -                 10.0.0.2\n",
+		},
+		"table without headers": {
+			format: OutputTable,
+			want: "i-1  web server, blue  10.0.0.1\n" +
+				"i-2  -                 10.0.0.2\n",
+		},
+		"csv quotes and leaves missing empty": {
+			format:  OutputCSV,
+			headers: true,
+			want: "ID,NAME,PRIVATE-IP\n" +
+				"i-1,\"web server, blue\",10.0.0.1\n" +
+				"i-2,,10.0.0.2\n",
+		},
+		"tsv without headers": {
+			format: OutputTSV,
+			want: "i-1\tweb server, blue\t10.0.0.1\n" +
+				"i-2\t\t10.0.0.2\n",
+		},
+		"json keeps column order and nulls": {
+			format:  OutputJSON,
+			headers: true,
+			want: `[
+  {
+    "id": "i-1",
+    "name": "web server, blue",
+    "private_ip": "10.0.0.1"
+  },
+  {
+    "id": "i-2",
+    "name": null,
+    "private_ip": "10.0.0.2"
+  }
+]
+`,
+		},
+		"jsonl": {
+			format: OutputJSONL,
+			want: `{"id":"i-1","name":"web server, blue","private_ip":"10.0.0.1"}
+{"id":"i-2","name":null,"private_ip":"10.0.0.2"}
+`,
+		},
+		"yaml": {
+			format:  OutputYAML,
+			headers: true,
+			want: `- id: i-1
+  name: web server, blue
+  private_ip: 10.0.0.1
+- id: i-2
+  name: null
+  private_ip: 10.0.0.2
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			err := writeRows(&buf, tc.format, columns, rows, tc.headers)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestWriteRows_Empty(t *testing.T) {
+	t.Parallel()
+
+	tests := map[OutputFormat]string{
+		OutputJSON:  "[]\n",
+		OutputJSONL: "",
+		OutputYAML:  "[]\n",
+		OutputCSV:   "ID\n",
+	}
+
+	for format, want := range tests {
+		var buf bytes.Buffer
+		require.NoError(t, writeRows(&buf, format, []string{"ID"}, nil, true))
+		assert.Equal(t, want, buf.String(), "format %d", format)
+	}
+}
+
+func TestWriteRows_YAMLQuotesAmbiguousStrings(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := writeRows(&buf, OutputYAML, []string{"NAME", "ACCOUNT"}, [][]any{{"yes", "012345678901"}}, true)
+
+	require.NoError(t, err)
+	assert.Equal(t, "- name: \"yes\"\n  account: \"012345678901\"\n", buf.String())
+}
+
+func TestColumnKey(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "id", columnKey("ID"))
+	assert.Equal(t, "private_dns", columnKey("PRIVATE-DNS"))
+	assert.Equal(t, "ipv6", columnKey("IPV6"))
+}
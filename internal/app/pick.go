@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/picker"
+	"golang.org/x/term"
+)
+
+// Package-level hooks for the interactive picker, overridden in tests.
+var (
+	isInteractive = defaultIsInteractive
+	pickInstance  = defaultPickInstance
+)
+
+// defaultIsInteractive reports whether both stdin and stderr are terminals.
+func defaultIsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// defaultPickInstance lets the user choose one instance using ec2list's default columns.
+// PROFILE and REGION columns are added when the instances span several profiles or regions.
+func defaultPickInstance(instances []ec2client.RegionalInstance) (ec2client.RegionalInstance, error) {
+	var multiProfile, multiRegion bool
+	for _, instance := range instances {
+		multiProfile = multiProfile || instance.Profile != instances[0].Profile
+		multiRegion = multiRegion || instance.Region != instances[0].Region
+	}
+
+	columns, err := parseListColumns(listColumnsFor(multiProfile, false, multiRegion))
+	if err != nil {
+		return ec2client.RegionalInstance{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeInstanceList(&buf, instances, columns, listLookups{}); err != nil {
+		return ec2client.RegionalInstance{}, err
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	index, err := picker.Pick(os.Stdin, os.Stderr, lines[0], lines[1:])
+	if err != nil {
+		return ec2client.RegionalInstance{}, err
+	}
+
+	return instances[index], nil
+}
+
+// resolveInstance finds the instance for destination with the given clients and
+// returns it with the client (region and profile) that found it.
+// On a terminal, an empty destination opens the picker over all running instances,
+// and an ambiguous one opens it over the matching candidates.
+func resolveInstance(clients []*ec2client.Client, destination string, dstType *ec2client.DstType, selector *ec2client.Selector) (*ec2client.Client, types.Instance, error) {
+	var match ec2client.RegionalInstance
+	var err error
+
+	if destination == "" {
+		match, err = pickRunningInstance(clients)
+	} else {
+		match, err = ec2client.GetInstanceInRegions(clients, destination, dstType, selector)
+
+		var ambiguous *ec2client.AmbiguousError
+		if errors.As(err, &ambiguous) && isInteractive() {
+			candidates := make([]ec2client.RegionalInstance, len(ambiguous.Candidates))
+			for i, candidate := range ambiguous.Candidates {
+				candidates[i] = ec2client.RegionalInstance{
+					Instance: candidate,
+					Region:   ambiguous.Regions[*candidate.InstanceId],
+					Profile:  ambiguous.Profiles[*candidate.InstanceId],
+				}
+			}
+			match, err = pickInstance(candidates)
+		}
+	}
+
+	if err != nil {
+		return nil, types.Instance{}, err
+	}
+
+	return clientFor(clients, match), match.Instance, nil
+}
+
+// pickRunningInstance opens the picker over all running instances.
+func pickRunningInstance(clients []*ec2client.Client) (ec2client.RegionalInstance, error) {
+	running := ec2client.States{Names: []string{string(types.InstanceStateNameRunning)}}
+
+	instances, err := ec2client.ListInstancesInRegions(clients, ec2client.ListQuery{
+		Filters: []types.Filter{running.Filter()},
+	})
+	if err != nil {
+		return ec2client.RegionalInstance{}, fmt.Errorf("unable to list instances: %w", err)
+	}
+
+	if len(instances) == 0 {
+		return ec2client.RegionalInstance{}, ec2client.ErrNoMatches
+	}
+
+	return pickInstance(instances)
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/ivoronin/ec2ssh/internal/awsclient"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// Package-level hooks for profile discovery, overridden in tests.
+var (
+	listProfiles = awsclient.ListProfiles
+	getAccountID = awsclient.GetAccountID
+)
+
+// validateProfileFlags checks that at most one way of choosing profiles is used.
+func validateProfileFlags(profile, profiles string, allProfiles bool) error {
+	if profiles != "" && allProfiles {
+		return fmt.Errorf("%w: --profiles and --all-profiles are mutually exclusive", ErrUsage)
+	}
+	if profile != "" && (profiles != "" || allProfiles) {
+		return fmt.Errorf("%w: --profile cannot be combined with --profiles or --all-profiles", ErrUsage)
+	}
+	return nil
+}
+
+// profilePattern returns the glob selecting the profiles to search,
+// empty when only the single --profile (or SDK default) is used.
+func profilePattern(profiles string, allProfiles bool) string {
+	if allProfiles {
+		return "*"
+	}
+	return profiles
+}
+
+// newSearchClients creates EC2 clients for every profile and region to search.
+// An empty pattern searches only profile; otherwise every configured profile
+// matching the glob is searched, with configs loaded in parallel, and
+// profiles that fail to load are skipped as by ec2client.SkipFailures.
+// Every client records the profile it was created with.
+func newSearchClients(region, profile, pattern string, regions *ec2client.Regions, logger *log.Logger) ([]*ec2client.Client, error) {
+	return loadSearchClients(region, profile, pattern, regions, false, logger)
+}
+
+// newStrictSearchClients is newSearchClients failing if any profile fails to
+// load, for callers that act on an instance being absent.
+func newStrictSearchClients(region, profile, pattern string, regions *ec2client.Regions, logger *log.Logger) ([]*ec2client.Client, error) {
+	return loadSearchClients(region, profile, pattern, regions, true, logger)
+}
+
+// loadSearchClients implements newSearchClients and newStrictSearchClients.
+func loadSearchClients(region, profile, pattern string, regions *ec2client.Regions, strict bool, logger *log.Logger) ([]*ec2client.Client, error) {
+	if pattern == "" {
+		return newProfileClients(region, profile, regions, logger)
+	}
+
+	configured, err := listProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := awsclient.MatchProfiles(configured, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no profiles match %s", pattern)
+	}
+
+	logger.Printf("searching profiles %v", profiles)
+
+	results := make([][]*ec2client.Client, len(profiles))
+	errs := make([]error, len(profiles))
+
+	var wg sync.WaitGroup
+	for i, profile := range profiles {
+		wg.Go(func() {
+			results[i], errs[i] = newProfileClients(region, profile, regions, logger)
+		})
+	}
+	wg.Wait()
+
+	var clients []*ec2client.Client
+	var failures []error
+	for i, profile := range profiles {
+		if errs[i] != nil {
+			failures = append(failures, fmt.Errorf("profile %s: %w", profile, errs[i]))
+			continue
+		}
+		clients = append(clients, results[i]...)
+	}
+
+	if strict && len(failures) > 0 {
+		return nil, errors.Join(failures...)
+	}
+	if err := ec2client.SkipFailures(logger, len(profiles), failures); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// newProfileClients loads the config for a single profile and creates a client per region.
+func newProfileClients(region, profile string, regions *ec2client.Regions, logger *log.Logger) ([]*ec2client.Client, error) {
+	cfg, err := loadAWSConfig(region, profile, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := newRegionalClients(cfg, regions, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, client := range clients {
+		client.SetProfile(profile)
+	}
+
+	return clients, nil
+}
+
+// lookupAccounts returns the account ID of every profile among clients.
+// Lookups run in parallel, one per profile; profiles whose lookup fails are
+// left out as by ec2client.SkipFailures.
+func lookupAccounts(clients []*ec2client.Client, logger *log.Logger) (map[string]string, error) {
+	var profiles []*ec2client.Client
+	seen := make(map[string]bool)
+	for _, client := range clients {
+		if !seen[client.Profile()] {
+			seen[client.Profile()] = true
+			profiles = append(profiles, client)
+		}
+	}
+
+	accounts := make([]string, len(profiles))
+	errs := make([]error, len(profiles))
+
+	var wg sync.WaitGroup
+	for i, client := range profiles {
+		wg.Go(func() {
+			accounts[i], errs[i] = getAccountID(client.Config())
+		})
+	}
+	wg.Wait()
+
+	result := make(map[string]string, len(profiles))
+	var failures []error
+	for i, client := range profiles {
+		if errs[i] != nil {
+			if client.Profile() == "" {
+				return nil, errs[i]
+			}
+			failures = append(failures, fmt.Errorf("profile %s: %w", client.Profile(), errs[i]))
+			continue
+		}
+		result[client.Profile()] = accounts[i]
+	}
+
+	if err := ec2client.SkipFailures(logger, len(profiles), failures); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
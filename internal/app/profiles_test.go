@@ -0,0 +1,104 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// profileClient creates a test client in region labelled with profile.
+func profileClient(profile, region string) *ec2client.Client {
+	client := ec2client.NewTestClientInRegion(region, new(mockEC2API), nil, nil)
+	client.SetProfile(profile)
+	return client
+}
+
+// captureWarnings redirects ec2client.WarningOutput for the rest of the test.
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	origWarningOutput := ec2client.WarningOutput
+	t.Cleanup(func() { ec2client.WarningOutput = origWarningOutput })
+	warnings := new(bytes.Buffer)
+	ec2client.WarningOutput = warnings
+	return warnings
+}
+
+func TestLookupAccounts(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	origGetAccountID := getAccountID
+	t.Cleanup(func() { getAccountID = origGetAccountID })
+
+	calls := make(chan string, 10)
+	getAccountID = func(cfg aws.Config) (string, error) {
+		calls <- cfg.Region
+		return "account-" + cfg.Region, nil
+	}
+
+	clients := []*ec2client.Client{
+		profileClient("prod-a", "us-east-1"),
+		profileClient("prod-a", "eu-west-1"),
+		profileClient("prod-b", "ap-south-1"),
+	}
+
+	accounts, err := lookupAccounts(clients, log.New(io.Discard, "", 0))
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"prod-a": "account-us-east-1", "prod-b": "account-ap-south-1"}, accounts)
+	assert.Len(t, calls, 2, "one lookup per profile")
+}
+
+func TestLookupAccounts_SkipsFailingProfile(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	origGetAccountID := getAccountID
+	t.Cleanup(func() { getAccountID = origGetAccountID })
+	warnings := captureWarnings(t)
+
+	getAccountID = func(cfg aws.Config) (string, error) {
+		if cfg.Region == "eu-west-1" {
+			return "", errors.New("ExpiredToken")
+		}
+		return "111111111111", nil
+	}
+
+	clients := []*ec2client.Client{
+		profileClient("prod-a", "us-east-1"),
+		profileClient("prod-b", "eu-west-1"),
+	}
+
+	accounts, err := lookupAccounts(clients, log.New(io.Discard, "", 0))
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"prod-a": "111111111111"}, accounts)
+	assert.Contains(t, warnings.String(), "warning: skipping profile prod-b: ExpiredToken")
+}
+
+func TestLookupAccounts_Error(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	origGetAccountID := getAccountID
+	t.Cleanup(func() { getAccountID = origGetAccountID })
+
+	getAccountID = func(cfg aws.Config) (string, error) {
+		return "", errors.New("ExpiredToken")
+	}
+
+	clients := []*ec2client.Client{
+		profileClient("prod-a", "us-east-1"),
+		profileClient("prod-b", "eu-west-1"),
+	}
+
+	_, err := lookupAccounts(clients, log.New(io.Discard, "", 0))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile prod-b: ExpiredToken")
+}
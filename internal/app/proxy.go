@@ -0,0 +1,232 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ivoronin/argsieve"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+	"github.com/mmmorris1975/ssm-session-client/ssmclient"
+)
+
+// Package-level hooks for the proxied connection, overridden in tests.
+var (
+	runTunnel    = tunnel.RunWithIO
+	runSSMTunnel = ssmclient.SSHSession
+)
+
+// defaultIdentityNames are the identities ssh tries without IdentityFile, in its order.
+var defaultIdentityNames = []string{"id_rsa", "id_ecdsa", "id_ecdsa_sk", "id_ed25519", "id_ed25519_sk"}
+
+// ProxySession connects stdin/stdout to an instance's SSH port, for use as
+// an ssh ProxyCommand: ProxyCommand ec2ssh --proxy %r@%h:%p
+type ProxySession struct {
+	baseSSHSession
+}
+
+// NewProxySession creates a ProxySession from command-line arguments.
+func NewProxySession(args []string) (*ProxySession, error) {
+	var session ProxySession
+
+	positional, err := argsieve.Parse(&session, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	session.ApplyImpliedFlags()
+	if err := session.Validate(); err != nil {
+		return nil, err
+	}
+
+	// ssh reads the certificate itself, the proxy cannot hand one over
+	if session.CAKey != "" {
+		return nil, fmt.Errorf("%w: --ca-key cannot be used with --proxy", ErrUsage)
+	}
+
+	// ssh was started with its own known_hosts options before the proxy runs
+	if session.HostKeys != nil {
+		return nil, fmt.Errorf("%w: --host-keys cannot be used with --proxy", ErrUsage)
+	}
+
+	if len(positional) != 1 {
+		return nil, fmt.Errorf("%w: --proxy requires exactly one destination (user@host:port)", ErrUsage)
+	}
+
+	target, err := parseProxyDestination(positional[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+	session.Target = target
+	// ssh always expands %p, to 22 unless a port is configured
+	session.defaultPort = target.Port() == "22"
+
+	return &session, nil
+}
+
+// parseProxyDestination parses ssh's expansion of %r@%h:%p. The port follows
+// the last colon, so an unbracketed IPv6 host is fine; the user and port are optional.
+func parseProxyDestination(destination string) (ssh.SSHTarget, error) {
+	login, rest := "", destination
+	if i := strings.LastIndex(destination, "@"); i != -1 {
+		login, rest = destination[:i], destination[i+1:]
+	}
+
+	host, port := rest, "22"
+	if i := strings.LastIndex(rest, ":"); i != -1 && !strings.HasSuffix(rest, "]") {
+		host, port = rest[:i], rest[i+1:]
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if host == "" {
+		return nil, fmt.Errorf("%w: missing hostname", ssh.ErrTarget)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, fmt.Errorf("%w: invalid port %s", ssh.ErrTarget, port)
+	}
+
+	url := "ssh://"
+	if login != "" {
+		url += login + "@"
+	}
+	if strings.Contains(host, ":") {
+		url += "[" + host + "]"
+	} else {
+		url += host
+	}
+
+	return ssh.NewSSHTarget(url + ":" + port)
+}
+
+// Run resolves the instance, pushes the public key and proxies stdin/stdout
+// to its SSH port over the chosen transport. Nothing else is written to
+// stdout, which belongs to ssh.
+func (s *ProxySession) Run() error {
+	s.initLogger()
+
+	clients, err := newSearchClients(s.Region, s.Profile, profilePattern(s.Profiles, s.AllProfiles), s.Regions, s.logger)
+	if err != nil {
+		return err
+	}
+
+	// No picker: the terminal, if any, belongs to ssh
+	match, err := ec2client.GetInstanceInRegions(clients, s.Target.Host(), s.DstType, s.Select)
+	if err != nil {
+		return fmt.Errorf("unable to get instance: %w", err)
+	}
+	s.client, s.instance = clientFor(clients, match), match.Instance
+
+	if err := s.applyInstanceTags(); err != nil {
+		return err
+	}
+	if err := s.resolveLogin(); err != nil {
+		return err
+	}
+
+	if !s.NoSendKeys {
+		if err := s.loadPublicKey(); err != nil {
+			return err
+		}
+		if err := s.sendSSHPublicKey(); err != nil {
+			return err
+		}
+	}
+
+	s.inferAddrType()
+
+	if err := s.setupTransport(); err != nil {
+		return err
+	}
+
+	port := s.sshPort()
+
+	switch s.transport {
+	case TransportDirect:
+		result, err := ec2client.GetInstanceAddr(s.instance, s.AddrType)
+		if err != nil {
+			return err
+		}
+		addr := net.JoinHostPort(result.Addr, port)
+		s.logger.Printf("proxying to %s", addr)
+		return runTunnel(addr, tunnel.DialTCP, os.Stdin, os.Stdout, os.Stderr)
+	case TransportEICE:
+		eiceID, err := s.resolveEICEID()
+		if err != nil {
+			return err
+		}
+		result, err := ec2client.GetEICEAddr(s.instance, s.AddrType)
+		if err != nil {
+			return err
+		}
+		uri, err := s.client.CreateEICETunnelURI(result.Addr, port, eiceID)
+		if err != nil {
+			return err
+		}
+		s.logger.Printf("proxying to %s via %s", result.Addr, eiceID)
+		return runTunnel(uri, tunnel.DefaultDialer, os.Stdin, os.Stdout, os.Stderr)
+	case TransportSSM:
+		remotePort, err := strconv.Atoi(port)
+		if err != nil {
+			return err
+		}
+		s.logger.Printf("proxying to %s via SSM", *s.instance.InstanceId)
+		return runSSMTunnel(s.client.Config(), &ssmclient.PortForwardingInput{
+			Target:     *s.instance.InstanceId,
+			RemotePort: remotePort,
+		})
+	default:
+		panic(fmt.Sprintf("unexpected Transport: %d", int(s.transport)))
+	}
+}
+
+// loadPublicKey reads the public key to push: the --agent identity, that of -i,
+// or else of the first default identity that ssh would also try. A .pub file
+// next to the private key is preferred, so that encrypted keys need no passphrase.
+func (s *ProxySession) loadPublicKey() error {
+	if s.Agent != nil {
+		publicKey, err := s.agentPublicKey()
+		if err != nil {
+			return err
+		}
+		s.publicKey = publicKey
+		return nil
+	}
+
+	identity := s.IdentityFile
+	if identity == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("unable to find default identity: %w", err)
+		}
+		for _, name := range defaultIdentityNames {
+			path := filepath.Join(home, ".ssh", name)
+			if _, err := os.Stat(path); err == nil {
+				identity = path
+				break
+			}
+		}
+		if identity == "" {
+			return errors.New("no identity file found in ~/.ssh, use -i or --no-send-keys")
+		}
+	}
+
+	s.logger.Printf("pushing public key of %s", identity)
+
+	if publicKey, err := os.ReadFile(identity + ".pub"); err == nil {
+		s.publicKey = string(publicKey)
+		return nil
+	}
+
+	publicKey, err := getPublicKey(identity)
+	if err != nil {
+		return fmt.Errorf("unable to read public key from %s: %w", identity, err)
+	}
+	s.publicKey = publicKey
+	return nil
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+	"github.com/mmmorris1975/ssm-session-client/ssmclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProxyDestination(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input     string
+		wantLogin string
+		wantHost  string
+		wantPort  string
+		wantErr   bool
+	}{
+		"full":          {input: "ec2-user@i-0123456789abcdef0:22", wantLogin: "ec2-user", wantHost: "i-0123456789abcdef0", wantPort: "22"},
+		"no user":       {input: "web-server:2222", wantHost: "web-server", wantPort: "2222"},
+		"no port":       {input: "admin@web-server", wantLogin: "admin", wantHost: "web-server", wantPort: "22"},
+		"ipv6":          {input: "admin@2001:db8::1:22", wantLogin: "admin", wantHost: "2001:db8::1", wantPort: "22"},
+		"bracketed":     {input: "[2001:db8::1]:2222", wantHost: "2001:db8::1", wantPort: "2222"},
+		"tags":          {input: "admin@Env=prod,Role=api:22", wantLogin: "admin", wantHost: "Env=prod,Role=api", wantPort: "22"},
+		"at in user":    {input: "me@corp@web:22", wantLogin: "me@corp", wantHost: "web", wantPort: "22"},
+		"missing host":  {input: "admin@:22", wantErr: true},
+		"invalid port":  {input: "web:ssh", wantErr: true},
+		"port too high": {input: "web:70000", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			target, err := parseProxyDestination(tc.input)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantLogin, target.Login())
+			assert.Equal(t, tc.wantHost, target.Host())
+			assert.Equal(t, tc.wantPort, target.Port())
+		})
+	}
+}
+
+func TestNewProxySession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args        []string
+		wantErr     bool
+		errContains string
+	}{
+		"destination":        {args: []string{"ec2-user@web:22"}},
+		"with options":       {args: []string{"-i", "/home/me/.ssh/id_ed25519", "--transport", "auto", "--profile", "prod", "web:22"}},
+		"no destination":     {args: []string{}, wantErr: true, errContains: "exactly one destination"},
+		"two destinations":   {args: []string{"web:22", "db:22"}, wantErr: true, errContains: "exactly one destination"},
+		"ssh option":         {args: []string{"-o", "User=root", "web:22"}, wantErr: true},
+		"conflicting tunnel": {args: []string{"--use-eice", "--use-ssm", "web:22"}, wantErr: true, errContains: "mutually exclusive"},
+		"invalid port":       {args: []string{"web:port"}, wantErr: true, errContains: "invalid port"},
+		"ca key":             {args: []string{"--ca-key", "~/.ssh/ca", "web:22"}, wantErr: true, errContains: "--ca-key cannot be used with --proxy"},
+		"host keys":          {args: []string{"--host-keys", "auto", "web:22"}, wantErr: true, errContains: "--host-keys cannot be used with --proxy"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			session, err := NewProxySession(tc.args)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrUsage), "expected ErrUsage, got: %v", err)
+				if tc.errContains != "" {
+					assert.Contains(t, err.Error(), tc.errContains)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, session.Target)
+		})
+	}
+}
+
+// proxyCapture records how a ProxySession connected.
+type proxyCapture struct {
+	uri     string
+	ssmPort int
+	ssmID   string
+}
+
+// setupProxyMocks sets up DI mocks for ProxySession.Run() and a home
+// directory holding only id_ed25519.
+func setupProxyMocks(t *testing.T, captured *proxyCapture) (*mockEC2API, *mockEC2InstanceConnectAPI) {
+	t.Helper()
+
+	ec2Mock, connectMock := setupMocksForRun(t, testInstance, nil)
+
+	origRunTunnel := runTunnel
+	origRunSSMTunnel := runSSMTunnel
+	t.Cleanup(func() {
+		runTunnel = origRunTunnel
+		runSSMTunnel = origRunSSMTunnel
+	})
+
+	runTunnel = func(uri string, dial tunnel.Dialer, stdin io.Reader, stdout, stderr io.Writer) error {
+		captured.uri = uri
+		return nil
+	}
+	runSSMTunnel = func(cfg aws.Config, opts *ssmclient.PortForwardingInput) error {
+		captured.ssmID = opts.Target
+		captured.ssmPort = opts.RemotePort
+		return nil
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".ssh"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), []byte("private"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519.pub"), []byte("ssh-ed25519 AAAA me@laptop\n"), 0o600))
+
+	return ec2Mock, connectMock
+}
+
+func TestProxySession_Run_Direct(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured proxyCapture
+	setupProxyMocks(t, &captured)
+
+	// A fresh mock checks which key and login were pushed
+	connectMock := new(mockEC2InstanceConnectAPI)
+	connectMock.On("SendSSHPublicKey", mock.Anything, mock.MatchedBy(func(input *ec2instanceconnect.SendSSHPublicKeyInput) bool {
+		return *input.InstanceOSUser == "ec2-user" && *input.SSHPublicKey == "ssh-ed25519 AAAA me@laptop\n"
+	})).Return(&ec2instanceconnect.SendSSHPublicKeyOutput{Success: true}, nil)
+	ec2Mock := new(mockEC2API)
+	ec2Mock.On("DescribeInstances", mock.Anything, mock.Anything).Return(
+		ec2client.MakeDescribeOutput(ec2client.MakeReservation(testInstance)), nil)
+	newEC2Client = func(cfg aws.Config, logger *log.Logger) (*ec2client.Client, error) {
+		return ec2client.NewTestClient(ec2Mock, connectMock, new(mockHTTPRequestSigner)), nil
+	}
+
+	session, err := NewProxySession([]string{"ec2-user@i-1234567890abcdef0:2222"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, "52.1.2.3:2222", captured.uri)
+	connectMock.AssertExpectations(t)
+}
+
+func TestProxySession_Run_PortTag(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	tests := map[string]struct {
+		destination string
+		wantURI     string
+	}{
+		"tag wins over 22":         {destination: "ec2-user@i-1234567890abcdef0:22", wantURI: "52.1.2.3:2222"},
+		"other port wins over tag": {destination: "ec2-user@i-1234567890abcdef0:2200", wantURI: "52.1.2.3:2200"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var captured proxyCapture
+			setupProxyMocks(t, &captured)
+
+			instance := testInstance
+			instance.Tags = []types.Tag{{Key: aws.String("ec2ssh:port"), Value: aws.String("2222")}}
+			setupMocksForRun(t, instance, nil)
+
+			session, err := NewProxySession([]string{tc.destination})
+			require.NoError(t, err)
+
+			err = session.Run()
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantURI, captured.uri)
+		})
+	}
+}
+
+func TestProxySession_Run_IdentityFile(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured proxyCapture
+	setupProxyMocks(t, &captured)
+
+	origGetPublicKey := getPublicKey
+	t.Cleanup(func() { getPublicKey = origGetPublicKey })
+
+	var derivedFrom string
+	getPublicKey = func(path string) (string, error) {
+		derivedFrom = path
+		return "ssh-ed25519 BBBB", nil
+	}
+
+	// No .pub next to the key, so it is derived from the private key
+	session, err := NewProxySession([]string{"-i", "/keys/deploy", "admin@10.0.0.1:22"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, "/keys/deploy", derivedFrom)
+	assert.Equal(t, "ssh-ed25519 BBBB", session.publicKey)
+	assert.Equal(t, "10.0.0.1:22", captured.uri)
+}
+
+func TestProxySession_Run_SSM(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured proxyCapture
+	setupProxyMocks(t, &captured)
+
+	session, err := NewProxySession([]string{"--use-ssm", "ec2-user@i-1234567890abcdef0:22"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, "i-1234567890abcdef0", captured.ssmID)
+	assert.Equal(t, 22, captured.ssmPort)
+	assert.Empty(t, captured.uri)
+}
+
+func TestProxySession_Run_EICE(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured proxyCapture
+	ec2Mock, _ := setupProxyMocks(t, &captured)
+
+	signer := new(mockHTTPRequestSigner)
+	signer.On("PresignHTTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return("wss://eice.example.com/openTunnel?signed", nil, nil)
+	ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, mock.Anything).Return(
+		ec2client.MakeEICEOutput(ec2client.MakeEICE("eice-123", "vpc-123", "subnet-456", "eice.example.com")), nil)
+	newEC2Client = func(cfg aws.Config, logger *log.Logger) (*ec2client.Client, error) {
+		return ec2client.NewTestClient(ec2Mock, new(mockEC2InstanceConnectAPI), signer), nil
+	}
+
+	session, err := NewProxySession([]string{"--use-eice", "--no-send-keys", "ec2-user@i-1234567890abcdef0:22"})
+	require.NoError(t, err)
+
+	err = session.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, "wss://eice.example.com/openTunnel?signed", captured.uri)
+}
+
+func TestProxySession_Run_NoIdentity(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	var captured proxyCapture
+	setupProxyMocks(t, &captured)
+	t.Setenv("HOME", t.TempDir())
+
+	session, err := NewProxySession([]string{"ec2-user@i-1234567890abcdef0:22"})
+	require.NoError(t, err)
+
+	err = session.Run()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no identity file found")
+	assert.Empty(t, captured.uri)
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// newRegionalClients creates an EC2 client for every region to search.
+// nil regions means the single region from cfg.
+func newRegionalClients(cfg aws.Config, regions *ec2client.Regions, logger *log.Logger) ([]*ec2client.Client, error) {
+	if regions == nil {
+		client, err := newEC2Client(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return []*ec2client.Client{client}, nil
+	}
+
+	names := regions.Names
+	if regions.All {
+		client, err := newEC2Client(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		names, err = client.ListRegions()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no regions to search")
+	}
+
+	logger.Printf("searching regions %v", names)
+
+	clients := make([]*ec2client.Client, 0, len(names))
+	for _, name := range names {
+		regionalCfg := cfg.Copy()
+		regionalCfg.Region = name
+
+		client, err := newEC2Client(regionalCfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// clientFor returns the client that found match, by region and profile.
+func clientFor(clients []*ec2client.Client, match ec2client.RegionalInstance) *ec2client.Client {
+	for _, client := range clients {
+		if client.Region() == match.Region && client.Profile() == match.Profile {
+			return client
+		}
+	}
+	panic(fmt.Sprintf("internal error: no client for %s in region %q", match.Profile, match.Region))
+}
@@ -71,6 +71,7 @@ func NewSCPSession(args []string) (*SCPSession, error) {
 	}
 
 	session.PassArgs = remaining
+	session.portFlag = "-P"
 
 	return &session, nil
 }
@@ -43,6 +43,11 @@ func NewSFTPSession(args []string) (*SFTPSession, error) {
 	}
 
 	session.PassArgs = remaining
+	session.portFlag = "-P"
+
+	session.newTarget = func(host string) (ssh.Target, error) {
+		return ssh.NewSFTPTarget(host)
+	}
 
 	return &session, nil
 }
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ivoronin/argsieve"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+)
+
+// socksHandshakeTimeout bounds the SOCKS handshake, as a connection holds
+// one of the --max-connections slots while it lasts. Overridden in tests.
+var socksHandshakeTimeout = 10 * time.Second
+
+// SOCKSListen is the local address the --socks proxy listens on.
+type SOCKSListen struct {
+	Addr string
+	Port int // 0 = any free port
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts [laddr:]lport, where laddr defaults to localhost and an IPv6
+// address is written in brackets, e.g. "1080" or "[::1]:1080".
+func (l *SOCKSListen) UnmarshalText(text []byte) error {
+	fields := splitForwardSpec(string(text))
+
+	listen := SOCKSListen{Addr: "localhost"}
+	switch len(fields) {
+	case 1:
+	case 2:
+		listen.Addr, fields = fields[0], fields[1:]
+	default:
+		return fmt.Errorf("invalid SOCKS address: %s (expected [laddr:]lport)", text)
+	}
+
+	var err error
+	if listen.Port, err = strconv.Atoi(fields[0]); err != nil || listen.Port < 0 || listen.Port > 65535 {
+		return fmt.Errorf("invalid SOCKS address: %s (bad port %s)", text, fields[0])
+	}
+	if listen.Addr == "" {
+		return fmt.Errorf("invalid SOCKS address: %s (empty address)", text)
+	}
+
+	*l = listen
+	return nil
+}
+
+// SOCKSSession runs a local SOCKS5 proxy whose connections are made from the
+// instance's network: through EC2 Instance Connect Endpoint to private IP
+// addresses in its VPC, or with --use-ssm through SSM port forwarding
+// sessions to any host the instance can reach. No ssh is involved.
+type SOCKSSession struct {
+	baseInstanceSession
+	EICEID   string       `long:"eice-id"`  // Empty = endpoint in the instance's VPC
+	UseEICE  bool         `long:"use-eice"` // The default
+	UseSSM   bool         `long:"use-ssm"`
+	Listen   *SOCKSListen `long:"socks"`
+	MaxConns MaxConns     `long:"max-connections"` // 0 = defaultMaxConns
+
+	// Runtime
+	client     *ec2client.Client // EC2 API client for the instance's profile and region
+	instanceID string            // Instance the connections are made through
+	eiceID     string            // Endpoint for EICE tunnels
+}
+
+// NewSOCKSSession creates a SOCKSSession from command-line arguments.
+func NewSOCKSSession(args []string) (*SOCKSSession, error) {
+	var session SOCKSSession
+
+	positional, err := argsieve.Parse(&session, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	if session.Listen == nil {
+		return nil, fmt.Errorf("%w: --socks requires [laddr:]lport", ErrUsage)
+	}
+	if session.UseEICE && session.UseSSM {
+		return nil, fmt.Errorf("%w: --use-eice and --use-ssm are mutually exclusive", ErrUsage)
+	}
+	if session.UseSSM && session.EICEID != "" {
+		return nil, fmt.Errorf("%w: --eice-id cannot be combined with --use-ssm", ErrUsage)
+	}
+
+	if err := validateProfileFlags(session.Profile, session.Profiles, session.AllProfiles); err != nil {
+		return nil, err
+	}
+
+	switch len(positional) {
+	case 0:
+	case 1:
+		session.Destination = positional[0]
+	default:
+		return nil, fmt.Errorf("%w: unexpected argument %s", ErrUsage, positional[1])
+	}
+
+	if session.MaxConns == 0 {
+		session.MaxConns = defaultMaxConns
+	}
+
+	return &session, nil
+}
+
+// Run resolves the instance and serves SOCKS connections until interrupted.
+func (s *SOCKSSession) Run() error {
+	if err := s.resolve(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.Listen.Addr, strconv.Itoa(s.Listen.Port)))
+	if err != nil {
+		return fmt.Errorf("unable to listen: %w", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	via := "SSM"
+	if !s.UseSSM {
+		via = s.eiceID
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "SOCKS5 proxy on %s through %s via %s\n", listener.Addr(), s.instanceID, via)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.serve(ctx, listener, os.Stderr)
+}
+
+// resolve finds the instance and, for EICE, its endpoint.
+func (s *SOCKSSession) resolve() error {
+	client, instance, err := s.resolveTunnelInstance()
+	if err != nil {
+		return err
+	}
+	s.client, s.instanceID = client, *instance.InstanceId
+
+	if s.UseSSM {
+		return nil
+	}
+
+	s.eiceID, err = findEICEID(client, instance, s.EICEID)
+	return err
+}
+
+// serve answers SOCKS connections on listener until ctx is done, logging
+// each one to log.
+func (s *SOCKSSession) serve(ctx context.Context, listener net.Listener, log io.Writer) error {
+	forwarder := &tunnel.Forwarder{
+		Dial: func(local net.Conn) (tunnel.TunnelConnection, error) {
+			return s.dial(ctx, local)
+		},
+		MaxConns: int(s.MaxConns),
+		Log:      log,
+	}
+	return forwarder.Serve(ctx, listener)
+}
+
+// dial reads the SOCKS request on local, opens a tunnel to its destination
+// and tells the client whether that worked.
+func (s *SOCKSSession) dial(ctx context.Context, local net.Conn) (tunnel.TunnelConnection, error) {
+	target, err := tunnel.ReadSOCKSRequest(local, socksHandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, code, err := s.dialTarget(ctx, target)
+	if err != nil {
+		_ = tunnel.WriteSOCKSReply(local, code)
+		return nil, fmt.Errorf("%s: %w", target, err)
+	}
+	if err := tunnel.WriteSOCKSReply(local, tunnel.SOCKSSucceeded); err != nil {
+		remote.Close()
+		return nil, err
+	}
+
+	s.logger.Printf("%s: connected to %s", local.RemoteAddr(), target)
+	return remote, nil
+}
+
+// dialTarget opens a tunnel to target, a host:port, and returns the SOCKS
+// reply code for the client if that fails.
+func (s *SOCKSSession) dialTarget(ctx context.Context, target string) (tunnel.TunnelConnection, byte, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, tunnel.SOCKSGeneralFailure, err
+	}
+
+	if s.UseSSM {
+		portNumber, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, tunnel.SOCKSGeneralFailure, err
+		}
+		remote, err := dialSSMHost(ctx, s.client.Config(), s.instanceID, host, portNumber)
+		if err != nil {
+			return nil, tunnel.SOCKSHostUnreachable, err
+		}
+		return remote, tunnel.SOCKSSucceeded, nil
+	}
+
+	// EICE connects to private addresses only; names would have to be
+	// resolved in the VPC
+	if net.ParseIP(host) == nil {
+		return nil, tunnel.SOCKSAddressNotSupported, fmt.Errorf("EICE needs an IP address, not %s (resolve names locally or use --use-ssm)", host)
+	}
+	uri, err := s.client.CreateEICETunnelURI(host, port, s.eiceID)
+	if err != nil {
+		return nil, tunnel.SOCKSGeneralFailure, err
+	}
+	remote, err := dialEICE(uri)
+	if err != nil {
+		return nil, tunnel.SOCKSHostUnreachable, err
+	}
+	return remote, tunnel.SOCKSSucceeded, nil
+}
@@ -0,0 +1,308 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/gorilla/websocket"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSOCKSListen_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    SOCKSListen
+		wantErr string
+	}{
+		"port only":          {input: "1080", want: SOCKSListen{Addr: "localhost", Port: 1080}},
+		"with local address": {input: "0.0.0.0:1080", want: SOCKSListen{Addr: "0.0.0.0", Port: 1080}},
+		"ipv6 local address": {input: "[::1]:1080", want: SOCKSListen{Addr: "::1", Port: 1080}},
+		"any port":           {input: "0", want: SOCKSListen{Addr: "localhost", Port: 0}},
+		"remote port given":  {input: "localhost:1080:80", wantErr: "expected [laddr:]lport"},
+		"bad port":           {input: "socks", wantErr: "bad port socks"},
+		"port out of range":  {input: "65536", wantErr: "bad port 65536"},
+		"empty address":      {input: ":1080", wantErr: "empty address"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var listen SOCKSListen
+			err := listen.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, listen)
+		})
+	}
+}
+
+func TestNewSOCKSSession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args         []string
+		wantUseSSM   bool
+		wantMaxConns MaxConns
+		wantDest     string
+		wantErr      string
+	}{
+		"eice by default": {
+			args:         []string{"--socks", "1080", "bastion"},
+			wantMaxConns: defaultMaxConns,
+			wantDest:     "bastion",
+		},
+		"ssm": {
+			args:         []string{"--socks", "1080", "--use-ssm", "--max-connections", "2", "bastion"},
+			wantUseSSM:   true,
+			wantMaxConns: 2,
+			wantDest:     "bastion",
+		},
+		"no destination": {
+			args:         []string{"--socks", "1080"},
+			wantMaxConns: defaultMaxConns,
+		},
+		"eice and ssm": {
+			args:    []string{"--socks", "1080", "--use-eice", "--use-ssm", "bastion"},
+			wantErr: "mutually exclusive",
+		},
+		"eice id with ssm": {
+			args:    []string{"--socks", "1080", "--eice-id", "eice-123", "--use-ssm", "bastion"},
+			wantErr: "--eice-id cannot be combined with --use-ssm",
+		},
+		"extra argument": {
+			args:    []string{"--socks", "1080", "bastion", "curl"},
+			wantErr: "unexpected argument curl",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			session, err := NewSOCKSSession(tc.args)
+
+			if tc.wantErr != "" {
+				require.ErrorIs(t, err, ErrUsage)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUseSSM, session.UseSSM)
+			assert.Equal(t, tc.wantMaxConns, session.MaxConns)
+			assert.Equal(t, tc.wantDest, session.Destination)
+		})
+	}
+}
+
+// startFakeEICE starts a WebSocket server standing in for an EC2 Instance
+// Connect Endpoint whose tunnels echo what they are sent, and returns its
+// tunnel URI.
+func startFakeEICE(t *testing.T) string {
+	t.Helper()
+
+	var upgrader websocket.Upgrader
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/openTunnel"
+}
+
+// startSOCKSSession resolves session's instance and serves SOCKS connections
+// on a free local port until the test ends, returning that port's address.
+func startSOCKSSession(t *testing.T, session *SOCKSSession) string {
+	t.Helper()
+
+	require.NoError(t, session.resolve())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- session.serve(ctx, listener, io.Discard) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	return listener.Addr().String()
+}
+
+// socksConnect sends a SOCKS5 CONNECT request for the encoded address to the
+// proxy at addr and returns the connection and the reply code.
+func socksConnect(t *testing.T, addr string, dst []byte) (net.Conn, byte) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, err = conn.Write([]byte{0x05, 0x01, 0x00})
+	require.NoError(t, err)
+	method := make([]byte, 2)
+	_, err = io.ReadFull(conn, method)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x05, 0x00}, method)
+
+	_, err = conn.Write(append([]byte{0x05, 0x01, 0x00}, dst...))
+	require.NoError(t, err)
+	reply := make([]byte, 10)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+
+	return conn, reply[1]
+}
+
+// assertEcho checks that what is written to conn comes back.
+func assertEcho(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	got := make([]byte, 4)
+	_, err = io.ReadFull(conn, got)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(got))
+}
+
+func TestSOCKSSession_EICE(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	uri := startFakeEICE(t)
+
+	ec2Mock, _ := setupMocksForRun(t, testInstance, nil)
+	presigned := make(chan string, 1)
+	signer := new(mockHTTPRequestSigner)
+	signer.On("PresignHTTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			presigned <- args.Get(2).(*http.Request).URL.RawQuery
+		}).
+		Return(uri, nil, nil)
+	ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, mock.Anything).Return(
+		ec2client.MakeEICEOutput(ec2client.MakeEICE("eice-123", "vpc-123", "subnet-456", "eice.example.com")), nil)
+	newEC2Client = func(cfg aws.Config, logger *log.Logger) (*ec2client.Client, error) {
+		return ec2client.NewTestClient(ec2Mock, new(mockEC2InstanceConnectAPI), signer), nil
+	}
+
+	session, err := NewSOCKSSession([]string{"--socks", "0", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+	addr := startSOCKSSession(t, session)
+	assert.Equal(t, "eice-123", session.eiceID)
+
+	// CONNECT 10.0.1.20:80 is tunneled to that address through the endpoint
+	conn, code := socksConnect(t, addr, []byte{0x01, 10, 0, 1, 20, 0x00, 0x50})
+	require.Equal(t, tunnel.SOCKSSucceeded, code)
+	query := <-presigned
+	assert.Contains(t, query, "privateIpAddress=10.0.1.20")
+	assert.Contains(t, query, "remotePort=80")
+	assert.Contains(t, query, "instanceConnectEndpointId=eice-123")
+	assertEcho(t, conn)
+
+	// Names cannot be tunneled through the endpoint
+	conn, code = socksConnect(t, addr, append([]byte{0x03, 8}, "db.local\x00\x50"...))
+	assert.Equal(t, tunnel.SOCKSAddressNotSupported, code)
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestSOCKSSession_SSM(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	uri := startFakeEICE(t)
+	setupMocksForRun(t, testInstance, nil)
+
+	origDialSSM := dialSSM
+	t.Cleanup(func() { dialSSM = origDialSSM })
+	dialed := make(chan string, 1)
+	dialSSM = func(ctx context.Context, cfg aws.Config, instanceID, host string, port int) (tunnel.TunnelConnection, error) {
+		dialed <- instanceID + " " + net.JoinHostPort(host, strconv.Itoa(port))
+		return tunnel.DefaultDialer(uri)
+	}
+
+	session, err := NewSOCKSSession([]string{"--socks", "0", "--use-ssm", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+	addr := startSOCKSSession(t, session)
+
+	// Names are resolved from the instance
+	conn, code := socksConnect(t, addr, append([]byte{0x03, 12}, "api.internal\x01\xbb"...))
+	require.Equal(t, tunnel.SOCKSSucceeded, code)
+	assert.Equal(t, "i-1234567890abcdef0 api.internal:443", <-dialed)
+	assertEcho(t, conn)
+
+	// Loopback is the instance itself, without the remote host document
+	conn, code = socksConnect(t, addr, []byte{0x01, 127, 0, 0, 1, 0x00, 0x50})
+	require.Equal(t, tunnel.SOCKSSucceeded, code)
+	assert.Equal(t, "i-1234567890abcdef0 :80", <-dialed)
+	assertEcho(t, conn)
+}
+
+func TestSOCKSSession_SilentClientReleasesSlot(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	uri := startFakeEICE(t)
+	setupMocksForRun(t, testInstance, nil)
+
+	origDialSSM := dialSSM
+	origTimeout := socksHandshakeTimeout
+	t.Cleanup(func() {
+		dialSSM = origDialSSM
+		socksHandshakeTimeout = origTimeout
+	})
+	dialSSM = func(ctx context.Context, cfg aws.Config, instanceID, host string, port int) (tunnel.TunnelConnection, error) {
+		return tunnel.DefaultDialer(uri)
+	}
+	socksHandshakeTimeout = 50 * time.Millisecond
+
+	session, err := NewSOCKSSession([]string{"--socks", "0", "--use-ssm", "--max-connections", "1", "i-1234567890abcdef0"})
+	require.NoError(t, err)
+	addr := startSOCKSSession(t, session)
+
+	// Takes the only slot and never says anything
+	silent, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = silent.Close() })
+
+	// Served once the silent client's handshake times out
+	conn, code := socksConnect(t, addr, append([]byte{0x03, 12}, "api.internal\x01\xbb"...))
+	require.Equal(t, tunnel.SOCKSSucceeded, code)
+	assertEcho(t, conn)
+
+	_, err = silent.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
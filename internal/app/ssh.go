@@ -58,6 +58,13 @@ func NewSSHSession(args []string) (*SSHSession, error) {
 	// Copy -l flag to baseSession for EC2IC fallback chain
 	session.loginFlag = session.Login
 
+	// ssh takes the port as -p, scp and sftp as -P
+	session.portFlag = "-p"
+
+	session.newTarget = func(host string) (ssh.Target, error) {
+		return ssh.NewSSHTarget(host)
+	}
+
 	return &session, nil
 }
 
@@ -7,7 +7,6 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"os/user"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
@@ -56,28 +55,54 @@ func defaultExecuteCommand(command string, args []string, logger *log.Logger) er
 type baseSSHSession struct {
 	// --- CLI Configuration (populated by argsieve from command-line flags) ---
 	Region       string              `long:"region"`
+	Regions      *ec2client.Regions  `long:"regions"` // nil = Region only
 	Profile      string              `long:"profile"`
+	Profiles     string              `long:"profiles"` // Glob over configured profiles
+	AllProfiles  bool                `long:"all-profiles"`
 	EICEID       string              `long:"eice-id"`
 	DstType      *ec2client.DstType  `long:"destination-type"` // nil = auto-detect
 	AddrType     *ec2client.AddrType `long:"address-type"`     // nil = auto-detect
+	Select       *ec2client.Selector `long:"select"`           // nil = multiple matches are an error
 	IdentityFile string              `short:"i"`
 	UseEICE      bool                `long:"use-eice"`
 	UseSSM       bool                `long:"use-ssm"`
+	Transport    *Transports         `long:"transport"` // nil = --use-eice/--use-ssm, otherwise direct
 	NoSendKeys   bool                `long:"no-send-keys"`
+	Agent        *AgentMode          `long:"agent"`        // nil = -i or an ephemeral key file
+	KeyType      *ssh.KeyType        `long:"key-type"`     // nil = ec2ssh:key-type tag, EC2SSH_KEY_TYPE or ed25519
+	CAKey        string              `long:"ca-key"`       // Certify keys with this CA instead of pushing them
+	KeyDelivery  *KeyDelivery        `long:"key-delivery"` // nil = Instance Connect, SSM fallback
+	HostKeys     *HostKeySource      `long:"host-keys"`    // nil = trust on first use
 	Debug        bool                `long:"debug"`
 
 	// --- Parsed Session Parameters (set after argument parsing) ---
 	Target    ssh.Target // Parsed target (provides Login, Host, SetHost, String)
 	PassArgs  []string   // Passthrough args for the underlying command
 	loginFlag string     // Login from -l flag (SSH only), for EC2IC fallback chain
+	portFlag  string     // Passthrough option carrying the port (-p or -P), for the direct probe
+	// defaultPort marks the port on the command line as possibly ssh's
+	// default, as %p is with --proxy, so the ec2ssh:port tag wins over 22
+	defaultPort bool
+
+	// newTarget builds a Target for an instance chosen in the picker when no
+	// destination was given. nil = session always needs a destination (SCP).
+	newTarget func(host string) (ssh.Target, error)
 
 	// --- Runtime State (set during run()) ---
-	client         *ec2client.Client // EC2 API client
-	instance       types.Instance    // Resolved EC2 instance
-	privateKeyPath string            // Path to SSH private key
-	publicKey      string            // SSH public key content
-	proxyCommand   string            // ProxyCommand for EICE/SSM tunneling
-	logger         *log.Logger       // Debug logger
+	client          *ec2client.Client // EC2 API client for the instance's profile and region
+	instance        types.Instance    // Resolved EC2 instance
+	privateKeyPath  string            // Path to SSH private key
+	publicKey       string            // SSH public key content
+	certificatePath string            // Path to the --ca-key certificate for the key
+	knownHostsFile  string            // known_hosts with the instance's --host-keys
+	defaultKeyType  *ssh.KeyType      // Type of generated keys from EC2SSH_KEY_TYPE
+	defaultUser     string            // Login from the ec2ssh:user tag or the AMI, passed to ssh as User
+	login           string            // Login the key is authorized for, set by resolveLogin
+	tagPort         string            // Port from the ec2ssh:port tag, passed to ssh as Port
+	transport       Transport         // How the instance is reached
+	eiceID          string            // EICE endpoint for TransportEICE, from --eice-id or the VPC
+	proxyCommand    string            // ProxyCommand for EICE/SSM tunneling
+	logger          *log.Logger       // Debug logger
 }
 
 // appendOptArg appends a formatted option to args if value is non-empty.
@@ -89,23 +114,33 @@ func appendOptArg(args []string, format, value string) []string {
 	return args
 }
 
-// baseArgs returns common SSH options: ProxyCommand, identity file, HostKeyAlias, and passthrough args.
+// baseArgs returns common SSH options: ProxyCommand, identity file, HostKeyAlias,
+// known_hosts for --host-keys, and passthrough args.
 func (s *baseSSHSession) baseArgs() []string {
 	var args []string
 	args = appendOptArg(args, "-oProxyCommand=%s", s.proxyCommand)
 	args = appendOptArg(args, "-i%s", s.privateKeyPath)
+	args = appendOptArg(args, "-oCertificateFile=%s", s.certificatePath)
+	args = appendOptArg(args, "-oUser=%s", s.defaultUser)
+	args = appendOptArg(args, "-oPort=%s", s.tagPort)
+	if s.Agent != nil && s.privateKeyPath != "" {
+		args = append(args, "-oIdentitiesOnly=yes")
+	}
 	// Skip HostKeyAlias in passthrough mode (no destination → no instance lookup)
 	if s.instance.InstanceId != nil {
 		args = append(args, fmt.Sprintf("-oHostKeyAlias=%s", *s.instance.InstanceId))
 	}
+	if s.knownHostsFile != "" {
+		args = append(args, "-oUserKnownHostsFile="+s.knownHostsFile, "-oStrictHostKeyChecking=yes")
+	}
 	args = append(args, s.PassArgs...)
 	return args
 }
 
 // ApplyImpliedFlags sets flags implied by other flags.
-// EICEID implies UseEICE.
+// EICEID implies UseEICE, unless --transport chooses the transport.
 func (s *baseSSHSession) ApplyImpliedFlags() {
-	if s.EICEID != "" {
+	if s.EICEID != "" && s.Transport == nil {
 		s.UseEICE = true
 	}
 }
@@ -115,7 +150,28 @@ func (s *baseSSHSession) Validate() error {
 	if s.UseEICE && s.UseSSM {
 		return fmt.Errorf("%w: --use-eice and --use-ssm are mutually exclusive", ErrUsage)
 	}
-	return nil
+	if s.Transport != nil && (s.UseEICE || s.UseSSM) {
+		return fmt.Errorf("%w: --transport cannot be combined with --use-eice or --use-ssm", ErrUsage)
+	}
+	if s.Agent != nil && s.IdentityFile != "" {
+		return fmt.Errorf("%w: --agent cannot be combined with -i", ErrUsage)
+	}
+	if err := s.validateKeyType(); err != nil {
+		return err
+	}
+	if s.CAKey != "" && s.NoSendKeys {
+		return fmt.Errorf("%w: --ca-key cannot be combined with --no-send-keys", ErrUsage)
+	}
+	if s.KeyDelivery != nil && (s.CAKey != "" || s.NoSendKeys) {
+		return fmt.Errorf("%w: --key-delivery cannot be combined with --ca-key or --no-send-keys", ErrUsage)
+	}
+	return validateProfileFlags(s.Profile, s.Profiles, s.AllProfiles)
+}
+
+// canPick reports whether a missing destination can be chosen interactively.
+// Passthrough args without a destination (e.g. ssh -V) keep passthrough mode.
+func (s *baseSSHSession) canPick() bool {
+	return s.newTarget != nil && len(s.PassArgs) == 0 && isInteractive()
 }
 
 // initLogger initializes the debug logger based on the Debug flag.
@@ -126,11 +182,17 @@ func (s *baseSSHSession) initLogger() {
 	}
 }
 
+// setupSSHKeys selects the key to push and pass to ssh: an ssh-agent identity
+// with --agent, the -i identity, or else an ephemeral keypair in tmpDir.
 func (s *baseSSHSession) setupSSHKeys(tmpDir string) error {
 	var err error
 
+	if s.Agent != nil {
+		return s.setupAgentKey(tmpDir)
+	}
+
 	if s.IdentityFile == "" {
-		s.privateKeyPath, s.publicKey, err = generateKeypair(tmpDir)
+		s.privateKeyPath, s.publicKey, err = generateKeypair(tmpDir, s.keyType())
 		if err != nil {
 			return fmt.Errorf("unable to generate ephemeral SSH keypair: %w", err)
 		}
@@ -145,48 +207,104 @@ func (s *baseSSHSession) setupSSHKeys(tmpDir string) error {
 	return nil
 }
 
-// sendSSHPublicKey sends the public key to the instance via EC2 Instance Connect.
-// Login fallback chain: Target.Login() → loginFlag (-l) → OS user.
+// resolveLogin sets the login the key is authorized for and ssh logs in as.
+// Login fallback chain: Target.Login() → loginFlag (-l) → the ec2ssh:user tag
+// → the instance's AMI. A login from the tag or the AMI is also passed to ssh,
+// which would otherwise use the OS user.
 // Requires: s.Target != nil (caller must check; run() ensures this via passthrough mode check).
-func (s *baseSSHSession) sendSSHPublicKey() error {
+func (s *baseSSHSession) resolveLogin() error {
 	if s.Target == nil {
-		return errors.New("internal error: sendSSHPublicKey called without target")
+		return errors.New("internal error: resolveLogin called without target")
 	}
-	login := s.Target.Login()
-	if login == "" {
-		login = s.loginFlag
+
+	var source string
+	switch {
+	case s.Target.Login() != "":
+		s.login, source = s.Target.Login(), "destination"
+	case s.loginFlag != "":
+		s.login, source = s.loginFlag, "-l"
+	case s.defaultUser != "":
+		s.login, source = s.defaultUser, "tag "+tagUser
+	default:
+		s.login, source = s.defaultLogin()
+		s.defaultUser = s.login
 	}
-	if login == "" {
-		u, err := user.Current()
+
+	s.logger.Printf("using login %s from %s", s.login, source)
+	return nil
+}
+
+// sendSSHPublicKey authorizes the public key on the instance for the login,
+// through EC2 Instance Connect or SSM as chosen by --key-delivery.
+// Requires: resolveLogin() has been called.
+func (s *baseSSHSession) sendSSHPublicKey() error {
+	return s.deliverPublicKey(s.login)
+}
+
+// inferAddrType infers the address type from the destination type if not explicitly set.
+func (s *baseSSHSession) inferAddrType() {
+	if s.AddrType != nil {
+		return
+	}
+	effectiveDstType := s.DstType
+	if effectiveDstType == nil {
+		host, _ := ec2client.SplitDestinationIndex(s.Target.Host())
+		guessed := ec2client.GuessDestinationType(host)
+		effectiveDstType = &guessed
+	}
+	s.AddrType = ec2client.DstTypeToAddrType(*effectiveDstType)
+}
+
+// resolveEICEID returns --eice-id, or else finds an endpoint in the instance's VPC.
+// The result is kept for the ProxyCommand.
+func (s *baseSSHSession) resolveEICEID() (string, error) {
+	if s.eiceID != "" {
+		return s.eiceID, nil
+	}
+
+	eiceID, err := findEICEID(s.client, s.instance, s.EICEID)
+	if err != nil {
+		return "", err
+	}
+	s.eiceID = eiceID
+
+	return s.eiceID, nil
+}
+
+// setupTransport decides how to reach the instance: the first working one in
+// --transport order, otherwise as chosen by --use-eice or --use-ssm.
+func (s *baseSSHSession) setupTransport() error {
+	switch {
+	case s.Transport != nil:
+		transport, err := s.chooseTransport()
 		if err != nil {
-			return fmt.Errorf("unable to determine current user for key push: %w", err)
+			return err
 		}
-		login = u.Username
-	}
-	if err := s.client.SendSSHPublicKey(s.instance, login, s.publicKey); err != nil {
-		return fmt.Errorf("unable to send SSH public key: %w", err)
+		s.transport = transport
+	case s.UseSSM:
+		s.transport = TransportSSM
+	case s.UseEICE:
+		s.transport = TransportEICE
+	default:
+		s.transport = TransportDirect
 	}
 	return nil
 }
 
-// setupProxyCommand configures the SSH ProxyCommand for EICE or SSM tunneling.
+// setupProxyCommand configures the SSH ProxyCommand for the EICE or SSM transport.
 // Uses %p for port substitution by SSH.
 func (s *baseSSHSession) setupProxyCommand() error {
 	args := []string{os.Args[0]}
 
-	if s.UseSSM {
+	switch s.transport {
+	case TransportSSM:
 		args = append(args, "--ssm-tunnel")
 		args = append(args, "--instance-id", *s.instance.InstanceId)
 		args = append(args, "--port", "%p")
-	} else if s.UseEICE {
-		// Resolve EICE ID if not explicitly provided
-		eiceID := s.EICEID
-		if eiceID == "" {
-			eice, err := s.client.GuessEICEByVPCAndSubnet(*s.instance.VpcId, *s.instance.SubnetId)
-			if err != nil {
-				return fmt.Errorf("unable to find EICE endpoint: %w", err)
-			}
-			eiceID = *eice.InstanceConnectEndpointId
+	case TransportEICE:
+		eiceID, err := s.resolveEICEID()
+		if err != nil {
+			return err
 		}
 
 		// Get host address for EICE tunnel (private IPv4 or IPv6, not public)
@@ -199,15 +317,21 @@ func (s *baseSSHSession) setupProxyCommand() error {
 		args = append(args, "--host", result.Addr)
 		args = append(args, "--port", "%p")
 		args = append(args, "--eice-id", eiceID)
-	} else {
-		panic("internal error: unknown tunnel type")
+	default:
+		panic(fmt.Sprintf("internal error: no proxy command for transport %s", s.transport))
 	}
 
-	if s.Region != "" {
-		args = append(args, "--region", s.Region)
+	// With --regions the instance may live outside the configured region
+	region := s.Region
+	if s.Regions != nil {
+		region = s.client.Region()
 	}
-	if s.Profile != "" {
-		args = append(args, "--profile", s.Profile)
+	if region != "" {
+		args = append(args, "--region", region)
+	}
+	// The client carries --profile, or the profile the instance was found in
+	if s.client.Profile() != "" {
+		args = append(args, "--profile", s.client.Profile())
 	}
 	if s.Debug {
 		args = append(args, "--debug")
@@ -220,30 +344,29 @@ func (s *baseSSHSession) setupProxyCommand() error {
 // run executes the session command. Called by embedded types.
 // buildArgs is called after setup completes, ensuring runtime fields are populated.
 // If Target is nil, passthrough mode is used - the command is executed
-// directly with just the args from buildArgs() (e.g., for ssh -V).
+// directly with just the args from buildArgs() (e.g., for ssh -V) - unless
+// the destination can be picked interactively.
 func (s *baseSSHSession) run(command string, buildArgs func() []string) error {
 	// Initialize logger
 	s.initLogger()
 
 	// Passthrough mode: no target means skip AWS work entirely
-	if s.Target == nil {
+	if s.Target == nil && !s.canPick() {
 		return executeCommand(command, buildArgs(), s.logger)
 	}
 
-	// Load AWS config
-	cfg, err := loadAWSConfig(s.Region, s.Profile, s.logger)
+	// Create EC2 clients, one per searched profile and region
+	clients, err := newSearchClients(s.Region, s.Profile, profilePattern(s.Profiles, s.AllProfiles), s.Regions, s.logger)
 	if err != nil {
 		return err
 	}
 
-	// Create EC2 client
-	s.client, err = newEC2Client(cfg, s.logger)
-	if err != nil {
-		return err
+	// Get instance (empty destination opens the picker)
+	var destination string
+	if s.Target != nil {
+		destination = s.Target.Host()
 	}
-
-	// Get instance
-	s.instance, err = s.client.GetInstance(s.Target.Host(), s.DstType)
+	s.client, s.instance, err = resolveInstance(clients, destination, s.DstType, s.Select)
 	if err != nil {
 		return fmt.Errorf("unable to get instance: %w", err)
 	}
@@ -254,6 +377,27 @@ func (s *baseSSHSession) run(command string, buildArgs func() []string) error {
 		panic("ec2ssh: AWS returned instance without InstanceId - this should never happen")
 	}
 
+	// Picked instance becomes the destination
+	if s.Target == nil {
+		s.Target, err = s.newTarget(*s.instance.InstanceId)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Connection settings the command line leaves open come from instance tags
+	if err := s.applyInstanceTags(); err != nil {
+		return err
+	}
+	if err := s.applyKeyTypeTag(); err != nil {
+		return err
+	}
+
+	// Resolved whatever the auth mode, as ssh needs the login too
+	if err := s.resolveLogin(); err != nil {
+		return err
+	}
+
 	// Create temp dir for ephemeral keys
 	tmpDir, err := os.MkdirTemp("", "ec2ssh")
 	if err != nil {
@@ -266,25 +410,32 @@ func (s *baseSSHSession) run(command string, buildArgs func() []string) error {
 		return err
 	}
 
-	// Send SSH public key
-	if !s.NoSendKeys {
+	// Authorize the key: certify it with --ca-key, or push it with Instance Connect
+	switch {
+	case s.CAKey != "":
+		if err := s.issueCertificate(tmpDir); err != nil {
+			return err
+		}
+	case !s.NoSendKeys:
 		if err := s.sendSSHPublicKey(); err != nil {
 			return err
 		}
 	}
 
-	// Infer address type from destination type if not explicitly set
-	if s.AddrType == nil {
-		effectiveDstType := s.DstType
-		if effectiveDstType == nil {
-			guessed := ec2client.GuessDestinationType(s.Target.Host())
-			effectiveDstType = &guessed
+	if s.HostKeys != nil {
+		if err := s.setupHostKeys(); err != nil {
+			return err
 		}
-		s.AddrType = ec2client.DstTypeToAddrType(*effectiveDstType)
+	}
+
+	s.inferAddrType()
+
+	if err := s.setupTransport(); err != nil {
+		return err
 	}
 
 	// Setup destination address and proxy command (EICE or SSM)
-	if s.UseEICE || s.UseSSM {
+	if s.transport != TransportDirect {
 		if err := s.setupProxyCommand(); err != nil {
 			return err
 		}
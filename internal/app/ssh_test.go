@@ -19,8 +19,13 @@ func TestNewSSHSession(t *testing.T) {
 		wantHost       string
 		wantDstType    *ec2client.DstType  // nil = auto-detect (default)
 		wantAddrType   *ec2client.AddrType // nil = auto-detect (default)
+		wantSelect     *ec2client.Selector // nil = ambiguity is an error (default)
+		wantRegions    *ec2client.Regions  // nil = configured region only (default)
+		wantProfiles   string
+		wantAllProfs   bool
 		wantUseEICE    bool
 		wantUseSSM     bool
+		wantTransport  *Transports // nil = --use-eice/--use-ssm decide (default)
 		wantNoSendKeys bool
 		wantErr        bool
 		errContains    string
@@ -77,6 +82,16 @@ func TestNewSSHSession(t *testing.T) {
 			wantHost:    "my-server",
 			wantDstType: dstTypePtr(ec2client.DstTypeNameTag),
 		},
+		"destination type tags": {
+			args:        []string{"--destination-type", "tags", "Backup"},
+			wantHost:    "Backup",
+			wantDstType: dstTypePtr(ec2client.DstTypeTags),
+		},
+		"tag expression destination": {
+			args:      []string{"ec2-user@Env=prod,Role=api"},
+			wantLogin: "ec2-user",
+			wantHost:  "Env=prod,Role=api",
+		},
 		"address type private": {
 			args:         []string{"--address-type", "private", "myhost"},
 			wantHost:     "myhost",
@@ -108,6 +123,57 @@ func TestNewSSHSession(t *testing.T) {
 			wantDstType: dstTypePtr(ec2client.DstTypePrivateDNSName),
 		},
 
+		// Instance selection
+		"select newest": {
+			args:       []string{"--select", "newest", "web"},
+			wantHost:   "web",
+			wantSelect: &ec2client.Selector{Kind: ec2client.SelectNewest},
+		},
+		"select index": {
+			args:       []string{"--select", "2", "web"},
+			wantHost:   "web",
+			wantSelect: &ec2client.Selector{Kind: ec2client.SelectIndex, Index: 2},
+		},
+
+		// Multi-region lookup
+		"regions list": {
+			args:        []string{"--regions", "us-east-1,eu-west-1", "web"},
+			wantHost:    "web",
+			wantRegions: &ec2client.Regions{Names: []string{"us-east-1", "eu-west-1"}},
+		},
+		"all regions": {
+			args:        []string{"--regions", "all", "web"},
+			wantHost:    "web",
+			wantRegions: &ec2client.Regions{All: true},
+		},
+		"invalid regions": {
+			args:        []string{"--regions", ",", "web"},
+			wantErr:     true,
+			errContains: "invalid region list",
+		},
+
+		// Multi-profile lookup
+		"profiles glob": {
+			args:         []string{"--profiles", "prod-*", "web"},
+			wantHost:     "web",
+			wantProfiles: "prod-*",
+		},
+		"all profiles": {
+			args:         []string{"--all-profiles", "web"},
+			wantHost:     "web",
+			wantAllProfs: true,
+		},
+		"profiles and all profiles mutually exclusive": {
+			args:        []string{"--profiles", "prod-*", "--all-profiles", "web"},
+			wantErr:     true,
+			errContains: "mutually exclusive",
+		},
+		"profile with profiles": {
+			args:        []string{"--profile", "dev", "--profiles", "prod-*", "web"},
+			wantErr:     true,
+			errContains: "--profile cannot be combined",
+		},
+
 		// Tunnel options
 		"use eice flag": {
 			args:        []string{"--use-eice", "myhost"},
@@ -124,6 +190,21 @@ func TestNewSSHSession(t *testing.T) {
 			wantHost:    "myhost",
 			wantUseEICE: true,
 		},
+		"transport auto": {
+			args:          []string{"--transport", "auto", "myhost"},
+			wantHost:      "myhost",
+			wantTransport: &Transports{Order: []Transport{TransportDirect, TransportEICE, TransportSSM}},
+		},
+		"transport order": {
+			args:          []string{"--transport", "ssm,eice", "myhost"},
+			wantHost:      "myhost",
+			wantTransport: &Transports{Order: []Transport{TransportSSM, TransportEICE}},
+		},
+		"eice id with transport does not force eice": {
+			args:          []string{"--transport", "auto", "--eice-id", "eice-123", "myhost"},
+			wantHost:      "myhost",
+			wantTransport: &Transports{Order: []Transport{TransportDirect, TransportEICE, TransportSSM}},
+		},
 		"no send keys flag": {
 			args:           []string{"--no-send-keys", "myhost"},
 			wantHost:       "myhost",
@@ -141,11 +222,84 @@ func TestNewSSHSession(t *testing.T) {
 			wantErr:     true,
 			errContains: "unknown address type",
 		},
+		"invalid select": {
+			args:        []string{"--select", "latest", "myhost"},
+			wantErr:     true,
+			errContains: "unknown selector",
+		},
 		"eice and ssm mutually exclusive": {
 			args:        []string{"--use-eice", "--use-ssm", "myhost"},
 			wantErr:     true,
 			errContains: "mutually exclusive",
 		},
+		"unknown transport": {
+			args:        []string{"--transport", "telnet", "myhost"},
+			wantErr:     true,
+			errContains: "unknown transport",
+		},
+		"transport with use ssm": {
+			args:        []string{"--transport", "auto", "--use-ssm", "myhost"},
+			wantErr:     true,
+			errContains: "--transport cannot be combined",
+		},
+		"unknown agent mode": {
+			args:        []string{"--agent", "forward", "myhost"},
+			wantErr:     true,
+			errContains: "unknown agent mode",
+		},
+		"agent with identity file": {
+			args:        []string{"--agent", "use", "-i", "~/.ssh/id_ed25519", "myhost"},
+			wantErr:     true,
+			errContains: "--agent cannot be combined with -i",
+		},
+		"unknown key type": {
+			args:        []string{"--key-type", "dsa", "myhost"},
+			wantErr:     true,
+			errContains: "unknown key type",
+		},
+		"ecdsa key pushed with instance connect": {
+			args:        []string{"--key-type", "ecdsa", "myhost"},
+			wantErr:     true,
+			errContains: "does not accept ecdsa keys",
+		},
+		"key type with identity file": {
+			args:        []string{"--key-type", "rsa", "-i", "~/.ssh/id_rsa", "myhost"},
+			wantErr:     true,
+			errContains: "--key-type only applies to generated keys",
+		},
+		"key type with agent use": {
+			args:        []string{"--key-type", "rsa", "--agent", "use", "myhost"},
+			wantErr:     true,
+			errContains: "--key-type only applies to generated keys",
+		},
+		"ecdsa key with ca": {
+			args:     []string{"--key-type", "ecdsa", "--ca-key", "~/.ssh/ca", "myhost"},
+			wantHost: "myhost",
+		},
+		"unknown host key source": {
+			args:        []string{"--host-keys", "dns", "myhost"},
+			wantErr:     true,
+			errContains: "unknown host key source",
+		},
+		"unknown key delivery": {
+			args:        []string{"--key-delivery", "scp", "myhost"},
+			wantErr:     true,
+			errContains: "unknown key delivery",
+		},
+		"key delivery with ca": {
+			args:        []string{"--key-delivery", "ssm", "--ca-key", "~/.ssh/ca", "myhost"},
+			wantErr:     true,
+			errContains: "--key-delivery cannot be combined",
+		},
+		"ecdsa key through ssm": {
+			args:     []string{"--key-type", "ecdsa", "--key-delivery", "ssm", "myhost"},
+			wantHost: "myhost",
+		},
+		"ca with no send keys": {
+			args:        []string{"--ca-key", "~/.ssh/ca", "--no-send-keys", "myhost"},
+			wantErr:     true,
+			errContains: "--ca-key cannot be combined with --no-send-keys",
+		},
 	}
 
 	for name, tc := range tests {
@@ -173,8 +327,13 @@ func TestNewSSHSession(t *testing.T) {
 			}
 			assert.Equal(t, tc.wantDstType, session.DstType, "dstType")
 			assert.Equal(t, tc.wantAddrType, session.AddrType, "addrType")
+			assert.Equal(t, tc.wantSelect, session.Select, "select")
+			assert.Equal(t, tc.wantRegions, session.Regions, "regions")
+			assert.Equal(t, tc.wantProfiles, session.Profiles, "profiles")
+			assert.Equal(t, tc.wantAllProfs, session.AllProfiles, "allProfiles")
 			assert.Equal(t, tc.wantUseEICE, session.UseEICE, "useEICE")
 			assert.Equal(t, tc.wantUseSSM, session.UseSSM, "useSSM")
+			assert.Equal(t, tc.wantTransport, session.Transport, "transport")
 			assert.Equal(t, tc.wantNoSendKeys, session.NoSendKeys, "noSendKeys")
 		})
 	}
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/ivoronin/argsieve"
-	"github.com/ivoronin/ec2ssh/internal/awsclient"
 	"github.com/ivoronin/ec2ssh/internal/ec2client"
 	"github.com/ivoronin/ec2ssh/internal/ssh"
 	"github.com/ivoronin/ec2ssh/internal/ssmcommand"
@@ -34,11 +33,15 @@ func (d *Duration) UnmarshalText(text []byte) error {
 // When CommandWithArgs is set, executes command via SSM RunCommand API.
 type SSMSession struct {
 	// CLI Configuration
-	Region         string             `long:"region"`
-	Profile        string             `long:"profile"`
-	DstType        *ec2client.DstType `long:"destination-type"` // nil = auto-detect
-	Debug          bool               `long:"debug"`
-	CommandTimeout Duration           `long:"timeout"` // Timeout for command execution (default: 60s)
+	Region         string              `long:"region"`
+	Regions        *ec2client.Regions  `long:"regions"` // nil = Region only
+	Profile        string              `long:"profile"`
+	Profiles       string              `long:"profiles"` // Glob over configured profiles
+	AllProfiles    bool                `long:"all-profiles"`
+	DstType        *ec2client.DstType  `long:"destination-type"` // nil = auto-detect
+	Select         *ec2client.Selector `long:"select"`           // nil = multiple matches are an error
+	Debug          bool                `long:"debug"`
+	CommandTimeout Duration            `long:"timeout"` // Timeout for command execution (default: 60s)
 
 	// Parsed values
 	Destination     string
@@ -57,6 +60,10 @@ func NewSSMSession(args []string) (*SSMSession, error) {
 		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
 	}
 
+	if err := validateProfileFlags(session.Profile, session.Profiles, session.AllProfiles); err != nil {
+		return nil, err
+	}
+
 	// Parse destination from first positional
 	if len(positional) > 0 {
 		target, err := ssh.NewSSHTarget(positional[0])
@@ -71,10 +78,6 @@ func NewSSMSession(args []string) (*SSMSession, error) {
 		session.CommandWithArgs = positional[1:]
 	}
 
-	if session.Destination == "" {
-		return nil, fmt.Errorf("%w: missing destination", ErrUsage)
-	}
-
 	// Set default timeout for command execution
 	if session.CommandTimeout == 0 {
 		session.CommandTimeout = Duration(60 * time.Second)
@@ -91,20 +94,19 @@ func (s *SSMSession) Run() error {
 		s.logger.SetOutput(os.Stderr)
 	}
 
-	// Load AWS config
-	cfg, err := awsclient.LoadConfig(s.Region, s.Profile, s.logger)
-	if err != nil {
-		return err
+	// Missing destination is only allowed when it can be picked interactively
+	if s.Destination == "" && !isInteractive() {
+		return fmt.Errorf("%w: missing destination", ErrUsage)
 	}
 
-	// Create EC2 client to resolve instance
-	client, err := newEC2Client(cfg, s.logger)
+	// Create EC2 clients to resolve instance, one per searched profile and region
+	clients, err := newSearchClients(s.Region, s.Profile, profilePattern(s.Profiles, s.AllProfiles), s.Regions, s.logger)
 	if err != nil {
 		return err
 	}
 
-	// Get instance
-	instance, err := client.GetInstance(s.Destination, s.DstType)
+	// Get instance (empty destination opens the picker)
+	client, instance, err := resolveInstance(clients, s.Destination, s.DstType, s.Select)
 	if err != nil {
 		return err
 	}
@@ -113,6 +115,9 @@ func (s *SSMSession) Run() error {
 		panic("ec2ssh: AWS returned instance without InstanceId - this should never happen")
 	}
 
+	// SSM calls must use the profile and region the instance was found in
+	cfg := client.Config()
+
 	// Dispatch based on command presence
 	if len(s.CommandWithArgs) > 0 {
 		s.logger.Printf("running command on instance %s", *instance.InstanceId)
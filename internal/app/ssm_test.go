@@ -15,9 +15,12 @@ func TestNewSSMSession(t *testing.T) {
 	tests := map[string]struct {
 		args        []string
 		wantHost    string
-		wantDstType *ec2client.DstType // nil = auto-detect (default)
-		wantCommand []string           // expected CommandWithArgs
-		wantTimeout Duration           // expected CommandTimeout
+		wantDstType *ec2client.DstType  // nil = auto-detect (default)
+		wantSelect  *ec2client.Selector // nil = ambiguity is an error (default)
+		wantRegions *ec2client.Regions  // nil = configured region only (default)
+		wantProfile string              // expected Profiles glob
+		wantCommand []string            // expected CommandWithArgs
+		wantTimeout Duration            // expected CommandTimeout
 		wantErr     bool
 		errContains string
 	}{
@@ -32,6 +35,11 @@ func TestNewSSMSession(t *testing.T) {
 			wantHost:    "i-1234567890abcdef0",
 			wantTimeout: Duration(60 * time.Second),
 		},
+		"missing destination - picked or rejected by Run": {
+			args:        []string{},
+			wantHost:    "",
+			wantTimeout: Duration(60 * time.Second),
+		},
 		"private ip": {
 			args:        []string{"10.0.0.1"},
 			wantHost:    "10.0.0.1",
@@ -92,6 +100,24 @@ func TestNewSSMSession(t *testing.T) {
 			wantDstType: dstTypePtr(ec2client.DstTypeNameTag),
 			wantTimeout: Duration(60 * time.Second),
 		},
+		"with select": {
+			args:        []string{"--select", "newest", "web"},
+			wantHost:    "web",
+			wantSelect:  &ec2client.Selector{Kind: ec2client.SelectNewest},
+			wantTimeout: Duration(60 * time.Second),
+		},
+		"with regions": {
+			args:        []string{"--regions", "all", "web"},
+			wantHost:    "web",
+			wantRegions: &ec2client.Regions{All: true},
+			wantTimeout: Duration(60 * time.Second),
+		},
+		"with profiles": {
+			args:        []string{"--profiles", "prod-*", "web"},
+			wantHost:    "web",
+			wantProfile: "prod-*",
+			wantTimeout: Duration(60 * time.Second),
+		},
 		"with debug": {
 			args:        []string{"--debug", "i-123"},
 			wantHost:    "i-123",
@@ -99,16 +125,21 @@ func TestNewSSMSession(t *testing.T) {
 		},
 
 		// Error cases
-		"missing destination": {
-			args:        []string{},
-			wantErr:     true,
-			errContains: "missing destination",
-		},
 		"invalid destination type": {
 			args:        []string{"--destination-type", "invalid", "i-123"},
 			wantErr:     true,
 			errContains: "unknown destination type",
 		},
+		"invalid select": {
+			args:        []string{"--select", "latest", "web"},
+			wantErr:     true,
+			errContains: "unknown selector",
+		},
+		"profile with all profiles": {
+			args:        []string{"--profile", "dev", "--all-profiles", "web"},
+			wantErr:     true,
+			errContains: "--profile cannot be combined",
+		},
 		"unknown flag": {
 			args:        []string{"--unknown-flag", "i-123"},
 			wantErr:     true,
@@ -135,6 +166,9 @@ func TestNewSSMSession(t *testing.T) {
 
 			assert.Equal(t, tc.wantHost, session.Destination, "destination")
 			assert.Equal(t, tc.wantDstType, session.DstType, "dstType")
+			assert.Equal(t, tc.wantSelect, session.Select, "select")
+			assert.Equal(t, tc.wantRegions, session.Regions, "regions")
+			assert.Equal(t, tc.wantProfile, session.Profiles, "profiles")
 			assert.Equal(t, tc.wantCommand, session.CommandWithArgs, "commandWithArgs")
 			assert.Equal(t, tc.wantTimeout, session.CommandTimeout, "commandTimeout")
 		})
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+)
+
+// transportProbeTimeout bounds the TCP connect that checks direct reachability.
+const transportProbeTimeout = 3 * time.Second
+
+// probeTCP checks that addr accepts TCP connections, overridden in tests.
+var probeTCP = defaultProbeTCP
+
+func defaultProbeTCP(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Transport is a way of reaching an instance's SSH port.
+type Transport int
+
+const (
+	TransportDirect Transport = iota // TCP to the instance address
+	TransportEICE                    // Tunnel through an EC2 Instance Connect Endpoint
+	TransportSSM                     // SSM Session Manager port forwarding
+)
+
+func (t Transport) String() string {
+	switch t {
+	case TransportDirect:
+		return "direct"
+	case TransportEICE:
+		return "eice"
+	case TransportSSM:
+		return "ssm"
+	default:
+		panic(fmt.Sprintf("unexpected Transport: %d", int(t)))
+	}
+}
+
+// defaultTransportOrder is the order tried by --transport auto.
+var defaultTransportOrder = []Transport{TransportDirect, TransportEICE, TransportSSM}
+
+// Transports is the order in which --transport tries transports.
+// Use a pointer to Transports where nil means --use-eice/--use-ssm decide.
+type Transports struct {
+	Order []Transport
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts "auto" for the default order, or a comma-separated list of
+// direct, eice and ssm in the order to try them.
+func (t *Transports) UnmarshalText(text []byte) error {
+	if string(text) == "auto" {
+		*t = Transports{Order: slices.Clone(defaultTransportOrder)}
+		return nil
+	}
+
+	names := map[string]Transport{
+		"direct": TransportDirect,
+		"eice":   TransportEICE,
+		"ssm":    TransportSSM,
+	}
+
+	var order []Transport
+	for _, name := range strings.Split(string(text), ",") {
+		transport, ok := names[name]
+		if !ok {
+			return fmt.Errorf("unknown transport: %s", name)
+		}
+		if slices.Contains(order, transport) {
+			return fmt.Errorf("duplicate transport: %s", name)
+		}
+		order = append(order, transport)
+	}
+
+	*t = Transports{Order: order}
+	return nil
+}
+
+// String returns the flag value of the transport order, e.g. "direct,eice,ssm".
+func (t Transports) String() string {
+	names := make([]string, len(t.Order))
+	for i, transport := range t.Order {
+		names[i] = transport.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// chooseTransport returns the first transport in --transport order that can
+// reach the instance, or an error listing why each one cannot.
+func (s *baseSSHSession) chooseTransport() (Transport, error) {
+	var reasons []string
+
+	for _, transport := range s.Transport.Order {
+		err := s.checkTransport(transport)
+		if err == nil {
+			s.logger.Printf("using transport %s", transport)
+			return transport, nil
+		}
+
+		s.logger.Printf("transport %s unavailable: %v", transport, err)
+		reasons = append(reasons, fmt.Sprintf("%s: %v", transport, err))
+	}
+
+	return 0, fmt.Errorf("no transport can reach instance %s (%s)", *s.instance.InstanceId, strings.Join(reasons, "; "))
+}
+
+// checkTransport reports why transport cannot reach the instance, or nil if it can.
+// Direct is probed with a TCP connect; EICE needs an endpoint in the VPC and a
+// private address; SSM needs the agent to be online.
+func (s *baseSSHSession) checkTransport(transport Transport) error {
+	switch transport {
+	case TransportDirect:
+		result, err := ec2client.GetInstanceAddr(s.instance, s.AddrType)
+		if err != nil {
+			return err
+		}
+		return probeTCP(net.JoinHostPort(result.Addr, s.sshPort()), transportProbeTimeout)
+	case TransportEICE:
+		if _, err := ec2client.GetEICEAddr(s.instance, s.AddrType); err != nil {
+			return err
+		}
+		_, err := s.resolveEICEID()
+		return err
+	case TransportSSM:
+		id := *s.instance.InstanceId
+		pings, err := getSSMPingStatuses(s.client.Config(), []string{id})
+		if err != nil {
+			return err
+		}
+		switch ping := pings[id]; ping {
+		case string(ssmtypes.PingStatusOnline):
+			return nil
+		case "":
+			return errors.New("instance is not managed by SSM")
+		default:
+			return fmt.Errorf("SSM agent is %s", ping)
+		}
+	default:
+		panic(fmt.Sprintf("unexpected Transport: %d", int(transport)))
+	}
+}
+
+// sshPort returns the SSH port the command will connect to: the port given on
+// the command line, else the ec2ssh:port tag, otherwise 22. The tag also wins
+// over a command-line port that may be ssh's default (see defaultPort).
+func (s *baseSSHSession) sshPort() string {
+	if s.defaultPort && s.tagPort != "" {
+		return s.tagPort
+	}
+	if port := s.cliPort(); port != "" {
+		return port
+	}
+	if s.tagPort != "" {
+		return s.tagPort
+	}
+	return "22"
+}
+
+// cliPort returns the port given on the command line: in an scp:// or sftp://
+// target, with the port flag or -oPort. Like ssh, the first one given wins.
+// Empty if none; ports set in ssh_config are not seen.
+func (s *baseSSHSession) cliPort() string {
+	if target, ok := s.Target.(interface{ Port() string }); ok && target.Port() != "" {
+		return target.Port()
+	}
+
+	for i := 0; i < len(s.PassArgs); i++ {
+		arg := s.PassArgs[i]
+		switch {
+		case s.portFlag != "" && arg == s.portFlag && i+1 < len(s.PassArgs):
+			return s.PassArgs[i+1]
+		case s.portFlag != "" && strings.HasPrefix(arg, s.portFlag) && len(arg) > len(s.portFlag):
+			return arg[len(s.portFlag):]
+		case arg == "-o" && i+1 < len(s.PassArgs):
+			i++
+			if port := portOption(s.PassArgs[i]); port != "" {
+				return port
+			}
+		case strings.HasPrefix(arg, "-o"):
+			if port := portOption(arg[2:]); port != "" {
+				return port
+			}
+		}
+	}
+
+	return ""
+}
+
+// portOption returns the value of an ssh "Port=N" or "Port N" option,
+// or empty if option sets something else.
+func portOption(option string) string {
+	key, value, ok := strings.Cut(option, "=")
+	if !ok {
+		key, value, ok = strings.Cut(option, " ")
+	}
+	if ok && strings.EqualFold(strings.TrimSpace(key), "Port") {
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/ivoronin/ec2ssh/internal/ec2client"
+	"github.com/ivoronin/ec2ssh/internal/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransports_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		want        []Transport
+		wantErr     bool
+		errContains string
+	}{
+		"auto":        {input: "auto", want: []Transport{TransportDirect, TransportEICE, TransportSSM}},
+		"single":      {input: "ssm", want: []Transport{TransportSSM}},
+		"custom":      {input: "eice,direct", want: []Transport{TransportEICE, TransportDirect}},
+		"unknown":     {input: "direct,telnet", wantErr: true, errContains: "unknown transport: telnet"},
+		"duplicate":   {input: "ssm,ssm", wantErr: true, errContains: "duplicate transport"},
+		"empty entry": {input: "direct,", wantErr: true, errContains: "unknown transport"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got Transports
+			err := got.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.Order)
+		})
+	}
+}
+
+func TestBaseSSHSession_sshPort(t *testing.T) {
+	t.Parallel()
+
+	sftpURL, err := ssh.NewSFTPTarget("sftp://admin@web:2200/var/log")
+	require.NoError(t, err)
+	proxyDefault, err := parseProxyDestination("admin@web:22")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		target      ssh.Target
+		portFlag    string
+		passArgs    []string
+		tagPort     string
+		defaultPort bool
+		want        string
+	}{
+		"default":               {portFlag: "-p", want: "22"},
+		"separate flag":         {portFlag: "-p", passArgs: []string{"-p", "2222"}, want: "2222"},
+		"joined flag":           {portFlag: "-P", passArgs: []string{"-P2222"}, want: "2222"},
+		"other flag ignored":    {portFlag: "-P", passArgs: []string{"-p", "2222"}, want: "22"},
+		"port option":           {portFlag: "-p", passArgs: []string{"-o", "Port=2022"}, want: "2022"},
+		"joined port option":    {portFlag: "-p", passArgs: []string{"-oport 2023"}, want: "2023"},
+		"unrelated option":      {portFlag: "-p", passArgs: []string{"-o", "User=root"}, want: "22"},
+		"first one wins":        {portFlag: "-p", passArgs: []string{"-p", "2222", "-oPort=22"}, want: "2222"},
+		"first option wins":     {portFlag: "-p", passArgs: []string{"-oPort=2022", "-o", "Port 22", "-p", "2222"}, want: "2022"},
+		"url target port wins":  {target: sftpURL, portFlag: "-P", passArgs: []string{"-P", "2222"}, want: "2200"},
+		"tag port":              {portFlag: "-p", tagPort: "2022", want: "2022"},
+		"flag wins over tag":    {portFlag: "-p", passArgs: []string{"-p", "2222"}, tagPort: "2022", want: "2222"},
+		"tag wins over default": {target: proxyDefault, tagPort: "2022", defaultPort: true, want: "2022"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &baseSSHSession{Target: tc.target, portFlag: tc.portFlag, PassArgs: tc.passArgs, tagPort: tc.tagPort, defaultPort: tc.defaultPort}
+			assert.Equal(t, tc.want, s.sshPort())
+		})
+	}
+}
+
+func TestBaseSSHSession_chooseTransport(t *testing.T) {
+	// No t.Parallel() - modifies global DI vars
+
+	origProbeTCP := probeTCP
+	origGetSSMPingStatuses := getSSMPingStatuses
+	t.Cleanup(func() {
+		probeTCP = origProbeTCP
+		getSSMPingStatuses = origGetSSMPingStatuses
+	})
+
+	instance := ec2client.MakeInstance("i-1",
+		ec2client.WithPrivateIP("10.0.0.1"), ec2client.WithPublicIP("52.1.2.3"),
+		ec2client.WithVPC("vpc-1"), ec2client.WithSubnet("subnet-1"))
+
+	tests := map[string]struct {
+		order       []Transport
+		probeErr    error
+		endpoints   []types.Ec2InstanceConnectEndpoint
+		ping        string
+		want        Transport
+		wantEICEID  string
+		errContains string
+	}{
+		"direct reachable": {
+			order: defaultTransportOrder,
+			want:  TransportDirect,
+		},
+		"falls back to eice": {
+			order:      defaultTransportOrder,
+			probeErr:   errors.New("i/o timeout"),
+			endpoints:  []types.Ec2InstanceConnectEndpoint{ec2client.MakeEICE("eice-1", "vpc-1", "subnet-1", "eice.example.com")},
+			want:       TransportEICE,
+			wantEICEID: "eice-1",
+		},
+		"falls back to ssm": {
+			order:    defaultTransportOrder,
+			probeErr: errors.New("connection refused"),
+			ping:     "Online",
+			want:     TransportSSM,
+		},
+		"custom order": {
+			order:      []Transport{TransportEICE, TransportDirect},
+			endpoints:  []types.Ec2InstanceConnectEndpoint{ec2client.MakeEICE("eice-1", "vpc-1", "subnet-1", "eice.example.com")},
+			want:       TransportEICE,
+			wantEICEID: "eice-1",
+		},
+		"nothing works": {
+			order:       defaultTransportOrder,
+			probeErr:    errors.New("i/o timeout"),
+			ping:        "ConnectionLost",
+			errContains: "no transport can reach instance i-1 (direct: i/o timeout; eice: unable to find EICE endpoint",
+		},
+		"not managed by ssm": {
+			order:       []Transport{TransportSSM},
+			errContains: "ssm: instance is not managed by SSM",
+		},
+		"ssm agent offline": {
+			order:       []Transport{TransportSSM},
+			ping:        "ConnectionLost",
+			errContains: "ssm: SSM agent is ConnectionLost",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var probed []string
+			probeTCP = func(addr string, timeout time.Duration) error {
+				probed = append(probed, addr)
+				return tc.probeErr
+			}
+			getSSMPingStatuses = func(cfg aws.Config, instanceIDs []string) (map[string]string, error) {
+				if tc.ping == "" {
+					return map[string]string{}, nil
+				}
+				return map[string]string{instanceIDs[0]: tc.ping}, nil
+			}
+
+			ec2Mock := new(mockEC2API)
+			ec2Mock.On("DescribeInstanceConnectEndpoints", mock.Anything, mock.Anything).Return(
+				ec2client.MakeEICEOutput(tc.endpoints...), nil)
+
+			s := &baseSSHSession{
+				Transport: &Transports{Order: tc.order},
+				client:    ec2client.NewTestClient(ec2Mock, nil, nil),
+				instance:  instance,
+				logger:    log.New(io.Discard, "", 0),
+			}
+
+			got, err := s.chooseTransport()
+
+			if tc.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantEICEID, s.eiceID)
+			if tc.order[0] == TransportDirect {
+				assert.Equal(t, "52.1.2.3:22", probed[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package awsclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// GetAccountID returns the AWS account ID the config's credentials belong to.
+func GetAccountID(cfg aws.Config) (string, error) {
+	result, err := sts.NewFromConfig(cfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("unable to get caller identity: %w", err)
+	}
+
+	return aws.ToString(result.Account), nil
+}
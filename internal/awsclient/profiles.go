@@ -0,0 +1,117 @@
+package awsclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// ListProfiles returns the names of profiles defined in the shared config and
+// credentials files, sorted. AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE
+// are honored like in the SDK. Missing files are ignored.
+func ListProfiles() ([]string, error) {
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = config.DefaultSharedConfigFilename()
+	}
+
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		credentialsFile = config.DefaultSharedCredentialsFilename()
+	}
+
+	var profiles []string
+
+	for _, file := range []struct {
+		name     string
+		isConfig bool
+	}{
+		{configFile, true},
+		{credentialsFile, false},
+	} {
+		names, err := readProfileNames(file.name, file.isConfig)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, names...)
+	}
+
+	slices.Sort(profiles)
+
+	return slices.Compact(profiles), nil
+}
+
+func readProfileNames(name string, isConfig bool) ([]string, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read profiles: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	names, err := parseProfileNames(f, isConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read profiles from %s: %w", name, err)
+	}
+
+	return names, nil
+}
+
+// parseProfileNames extracts profile names from INI section headers.
+// The config file names profiles "[profile name]" (except "[default]") and
+// also holds non-profile sections such as "[sso-session name]"; the
+// credentials file uses bare "[name]".
+func parseProfileNames(r io.Reader, isConfig bool) ([]string, error) {
+	var names []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+
+		section := strings.TrimSpace(line[1 : len(line)-1])
+		if isConfig && section != "default" {
+			var ok bool
+			if section, ok = strings.CutPrefix(section, "profile "); !ok {
+				continue
+			}
+			section = strings.TrimSpace(section)
+		}
+
+		if section != "" {
+			names = append(names, section)
+		}
+	}
+
+	return names, scanner.Err()
+}
+
+// MatchProfiles returns the profiles whose names match the glob pattern
+// (path.Match syntax).
+func MatchProfiles(profiles []string, pattern string) ([]string, error) {
+	var matched []string
+
+	for _, profile := range profiles {
+		ok, err := path.Match(pattern, profile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile pattern %s: %w", pattern, err)
+		}
+		if ok {
+			matched = append(matched, profile)
+		}
+	}
+
+	return matched, nil
+}
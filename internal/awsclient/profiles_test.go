@@ -0,0 +1,124 @@
+package awsclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProfileNames(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    string
+		isConfig bool
+		want     []string
+	}{
+		"config profiles": {
+			input:    "[default]\nregion = us-east-1\n\n[profile prod-a]\nregion = eu-west-1\n[profile  prod-b ]\n",
+			isConfig: true,
+			want:     []string{"default", "prod-a", "prod-b"},
+		},
+		"config skips other sections": {
+			input:    "[sso-session corp]\nsso_region = us-east-1\n[services s3]\n[profile dev]\n",
+			isConfig: true,
+			want:     []string{"dev"},
+		},
+		"credentials bare names": {
+			input: "[default]\naws_access_key_id = x\n[legacy]\n",
+			want:  []string{"default", "legacy"},
+		},
+		"comments and values ignored": {
+			input:    "# [profile commented]\n; note\nkey = [value]\n[profile real]\n",
+			isConfig: true,
+			want:     []string{"real"},
+		},
+		"empty": {
+			input: "",
+			want:  nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseProfileNames(strings.NewReader(tc.input), tc.isConfig)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMatchProfiles(t *testing.T) {
+	t.Parallel()
+
+	profiles := []string{"default", "dev", "prod-eu", "prod-us"}
+
+	tests := map[string]struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		"prefix glob":  {pattern: "prod-*", want: []string{"prod-eu", "prod-us"}},
+		"all":          {pattern: "*", want: profiles},
+		"exact":        {pattern: "dev", want: []string{"dev"}},
+		"single char":  {pattern: "prod-?s", want: []string{"prod-us"}},
+		"no match":     {pattern: "staging-*", want: nil},
+		"invalid glob": {pattern: "prod-[", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := MatchProfiles(profiles, tc.pattern)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "invalid profile pattern")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	// No t.Parallel() - sets environment variables
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+
+	require.NoError(t, os.WriteFile(configFile, []byte("[default]\n[profile prod]\n[profile dev]\n"), 0o600))
+	require.NoError(t, os.WriteFile(credentialsFile, []byte("[default]\n[legacy]\n"), 0o600))
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+
+	profiles, err := ListProfiles()
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "dev", "legacy", "prod"}, profiles)
+}
+
+func TestListProfiles_MissingFiles(t *testing.T) {
+	// No t.Parallel() - sets environment variables
+
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	profiles, err := ListProfiles()
+
+	require.NoError(t, err)
+	assert.Empty(t, profiles)
+}
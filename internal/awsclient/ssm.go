@@ -0,0 +1,46 @@
+package awsclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// ssmInstanceBatch is the most instance IDs asked about per DescribeInstanceInformation
+// call, the API's page size limit, so every batch fits in a single page.
+const ssmInstanceBatch = 50
+
+// GetSSMPingStatuses returns the SSM agent ping status (Online, ConnectionLost or
+// Inactive) of each given instance. Instances not managed by SSM are absent.
+func GetSSMPingStatuses(cfg aws.Config, instanceIDs []string) (map[string]string, error) {
+	client := ssm.NewFromConfig(cfg)
+	statuses := make(map[string]string, len(instanceIDs))
+
+	for start := 0; start < len(instanceIDs); start += ssmInstanceBatch {
+		batch := instanceIDs[start:min(start+ssmInstanceBatch, len(instanceIDs))]
+
+		input := &ssm.DescribeInstanceInformationInput{
+			Filters: []ssmtypes.InstanceInformationStringFilter{
+				{Key: aws.String("InstanceIds"), Values: batch},
+			},
+			MaxResults: aws.Int32(ssmInstanceBatch),
+		}
+
+		paginator := ssm.NewDescribeInstanceInformationPaginator(client, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(context.TODO())
+			if err != nil {
+				return nil, fmt.Errorf("unable to describe SSM instance information: %w", err)
+			}
+
+			for _, info := range page.InstanceInformationList {
+				statuses[aws.ToString(info.InstanceId)] = string(info.PingStatus)
+			}
+		}
+	}
+
+	return statuses, nil
+}
@@ -38,6 +38,20 @@ func (a *AddrType) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// String returns the flag value of the address type.
+func (a AddrType) String() string {
+	switch a {
+	case AddrTypePrivate:
+		return "private"
+	case AddrTypePublic:
+		return "public"
+	case AddrTypeIPv6:
+		return "ipv6"
+	default:
+		panic(fmt.Sprintf("unexpected AddrType: %d", int(a)))
+	}
+}
+
 // GetInstanceAddr returns the appropriate IP address for an instance.
 // If addrType is nil, auto-detects by trying public → ipv6 → private.
 func GetInstanceAddr(instance types.Instance, addrType *AddrType) (InstanceAddr, error) {
@@ -103,6 +117,11 @@ func GetInstanceName(instance types.Instance) *string {
 	return getInstanceTagValue(instance, "Name")
 }
 
+// GetInstanceTag returns the value of an instance tag, nil if it is not set.
+func GetInstanceTag(instance types.Instance, key string) *string {
+	return getInstanceTagValue(instance, key)
+}
+
 func getInstanceTagValue(instance types.Instance, tagKey string) *string {
 	for _, tag := range instance.Tags {
 		if *tag.Key == tagKey {
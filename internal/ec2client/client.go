@@ -16,7 +16,9 @@ type Client struct {
 	connectClient EC2InstanceConnectAPI
 	signer        HTTPRequestSigner
 	credentials   aws.Credentials
+	config        aws.Config
 	region        string
+	profile       string
 	logger        *log.Logger
 }
 
@@ -33,7 +35,32 @@ func NewClient(cfg aws.Config, logger *log.Logger) (*Client, error) {
 		connectClient: ec2instanceconnect.NewFromConfig(cfg),
 		signer:        signerV4.NewSigner(),
 		credentials:   credentials,
+		config:        cfg,
 		region:        cfg.Region,
 		logger:        logger,
 	}, nil
 }
+
+// Config returns the AWS config the client was created from.
+func (c *Client) Config() aws.Config {
+	return c.config
+}
+
+// Profile returns the AWS profile recorded with SetProfile, empty for the SDK default.
+func (c *Client) Profile() string {
+	return c.profile
+}
+
+// SetProfile records the AWS profile the client's config was loaded with.
+// The profile labels instances found by the client and is passed on to tunnels.
+func (c *Client) SetProfile(profile string) {
+	c.profile = profile
+}
+
+// Location returns "profile/region", or just the region without a profile.
+func (c *Client) Location() string {
+	if c.profile == "" {
+		return c.region
+	}
+	return c.profile + "/" + c.region
+}
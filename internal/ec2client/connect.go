@@ -2,10 +2,12 @@ package ec2client
 
 import (
 	"context"
+	"errors"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	connecttypes "github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect/types"
 )
 
 // SendSSHPublicKey pushes an SSH public key to an instance via EC2 Instance Connect.
@@ -25,3 +27,12 @@ func (c *Client) SendSSHPublicKey(instance types.Instance, instanceOSUser string
 
 	return err
 }
+
+// IsInstanceConnectUnsupported reports whether err from SendSSHPublicKey means
+// that Instance Connect cannot serve the instance at all, so that another way
+// of delivering the key is worth trying. Permission and argument errors are not.
+func IsInstanceConnectUnsupported(err error) bool {
+	var unavailable *connecttypes.EC2InstanceUnavailableException
+	var typeInvalid *connecttypes.EC2InstanceTypeInvalidException
+	return errors.As(err, &unavailable) || errors.As(err, &typeInvalid)
+}
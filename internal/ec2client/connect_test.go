@@ -2,9 +2,11 @@ package ec2client
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	connecttypes "github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -110,3 +112,27 @@ func TestClient_SendSSHPublicKey(t *testing.T) {
 		})
 	}
 }
+
+func TestIsInstanceConnectUnsupported(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":                  {err: nil, want: false},
+		"instance unavailable": {err: &connecttypes.EC2InstanceUnavailableException{}, want: true},
+		"instance type":        {err: &connecttypes.EC2InstanceTypeInvalidException{}, want: true},
+		"wrapped":              {err: fmt.Errorf("send: %w", &connecttypes.EC2InstanceUnavailableException{}), want: true},
+		"auth":                 {err: &connecttypes.AuthException{}, want: false},
+		"invalid args":         {err: &connecttypes.InvalidArgsException{}, want: false},
+		"other":                {err: errors.New("ThrottlingException: Rate exceeded"), want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, IsInstanceConnectUnsupported(tc.err))
+		})
+	}
+}
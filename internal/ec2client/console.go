@@ -0,0 +1,33 @@
+package ec2client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// GetConsoleOutput returns the instance's buffered console output, as posted
+// shortly after its last start. Empty if the instance has not posted any yet.
+func (c *Client) GetConsoleOutput(instance types.Instance) (string, error) {
+	c.logger.Printf("getting console output of instance %s", *instance.InstanceId)
+
+	output, err := c.ec2Client.GetConsoleOutput(context.TODO(), &ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(*instance.InstanceId),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.Output == nil {
+		return "", nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(*output.Output)
+	if err != nil {
+		return "", fmt.Errorf("invalid console output: %w", err)
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,64 @@
+package ec2client
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetConsoleOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		output      *ec2.GetConsoleOutputOutput
+		err         error
+		want        string
+		errContains string
+	}{
+		"decodes output": {
+			output: &ec2.GetConsoleOutputOutput{Output: aws.String(base64.StdEncoding.EncodeToString([]byte("cloud-init done\n")))},
+			want:   "cloud-init done\n",
+		},
+		"no output yet": {
+			output: &ec2.GetConsoleOutputOutput{},
+			want:   "",
+		},
+		"invalid encoding": {
+			output:      &ec2.GetConsoleOutputOutput{Output: aws.String("not base64!")},
+			errContains: "invalid console output",
+		},
+		"api error": {
+			err:         errors.New("UnauthorizedOperation"),
+			errContains: "UnauthorizedOperation",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockEC2 := new(MockEC2API)
+			mockEC2.On("GetConsoleOutput", mock.Anything, mock.MatchedBy(func(input *ec2.GetConsoleOutputInput) bool {
+				return *input.InstanceId == "i-1"
+			})).Return(tc.output, tc.err)
+
+			client := NewTestClient(mockEC2, nil, nil)
+			output, err := client.GetConsoleOutput(MakeInstance("i-1"))
+
+			if tc.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, output)
+		})
+	}
+}
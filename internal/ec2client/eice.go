@@ -51,6 +51,23 @@ func (c *Client) getEICEByID(instanceConnectEndpointID string) (*types.Ec2Instan
 func (c *Client) GuessEICEByVPCAndSubnet(vpcID string, subnetID string) (*types.Ec2InstanceConnectEndpoint, error) {
 	c.logger.Printf("searching for EICE by vpcID %s and subnetID %s", vpcID, subnetID)
 
+	endpoints, err := c.ListEICEsInVPC(vpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	eice := SelectEICE(endpoints, subnetID)
+	if eice == nil {
+		return nil, fmt.Errorf("unable to find an endpoint matching instance vpcID=%s: %w", vpcID, ErrNoMatches)
+	}
+
+	c.logger.Printf("selected endpoint %s in subnet %s", *eice.InstanceConnectEndpointId, *eice.SubnetId)
+
+	return eice, nil
+}
+
+// ListEICEsInVPC returns the ready (create-complete) EICE endpoints in the given VPC.
+func (c *Client) ListEICEsInVPC(vpcID string) ([]types.Ec2InstanceConnectEndpoint, error) {
 	input := &ec2.DescribeInstanceConnectEndpointsInput{
 		Filters: []types.Filter{
 			{
@@ -76,27 +93,26 @@ func (c *Client) GuessEICEByVPCAndSubnet(vpcID string, subnetID string) (*types.
 
 		c.logger.Printf("found %d endpoints", len(page.InstanceConnectEndpoints))
 
-		// Look for an endpoint in the same subnet
-		for _, eice := range page.InstanceConnectEndpoints {
-			endpoints = append(endpoints, eice)
+		endpoints = append(endpoints, page.InstanceConnectEndpoints...)
+	}
 
-			if *eice.SubnetId == subnetID {
-				eiceID := *eice.InstanceConnectEndpointId
-				c.logger.Printf("selected first endpoint matching instance vpc and subnet: %s", eiceID)
+	return endpoints, nil
+}
 
-				return &eice, nil
-			}
+// SelectEICE picks the endpoint in the given subnet, or else the first one.
+// Returns nil when there are no endpoints.
+func SelectEICE(endpoints []types.Ec2InstanceConnectEndpoint, subnetID string) *types.Ec2InstanceConnectEndpoint {
+	for i := range endpoints {
+		if aws.ToString(endpoints[i].SubnetId) == subnetID {
+			return &endpoints[i]
 		}
 	}
 
-	// If we didn't find an endpoint in the same subnet, return the first one
 	if len(endpoints) > 0 {
-		c.logger.Printf("found endpoint ID %s matching instance vpc", *endpoints[0].InstanceConnectEndpointId)
-
-		return &endpoints[0], nil
+		return &endpoints[0]
 	}
 
-	return nil, fmt.Errorf("unable to find an endpoint matching instance vpcID=%s: %w", vpcID, ErrNoMatches)
+	return nil
 }
 
 // CreateEICETunnelURI creates a signed WebSocket tunnel URI for EICE connection.
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -232,3 +233,39 @@ func TestClient_CreateEICETunnelURI(t *testing.T) {
 // 2. Prefer endpoint in same subnet
 // 3. Fall back to any endpoint in the VPC
 // 4. Return error if no endpoints found
+
+func TestSelectEICE(t *testing.T) {
+	t.Parallel()
+
+	endpoints := []types.Ec2InstanceConnectEndpoint{
+		MakeEICE("eice-1", "vpc-1", "subnet-1", "one.example.com"),
+		MakeEICE("eice-2", "vpc-1", "subnet-2", "two.example.com"),
+	}
+
+	tests := map[string]struct {
+		endpoints []types.Ec2InstanceConnectEndpoint
+		subnetID  string
+		wantID    string
+	}{
+		"same subnet":  {endpoints: endpoints, subnetID: "subnet-2", wantID: "eice-2"},
+		"first in vpc": {endpoints: endpoints, subnetID: "subnet-3", wantID: "eice-1"},
+		"no subnet":    {endpoints: endpoints, subnetID: "", wantID: "eice-1"},
+		"no endpoints": {endpoints: nil, subnetID: "subnet-1"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := SelectEICE(tc.endpoints, tc.subnetID)
+
+			if tc.wantID == "" {
+				assert.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+			assert.Equal(t, tc.wantID, *got.InstanceConnectEndpointId)
+		})
+	}
+}
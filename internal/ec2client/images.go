@@ -0,0 +1,38 @@
+package ec2client
+
+import (
+	"context"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// imageBatchSize is the most image IDs per DescribeImages filter.
+const imageBatchSize = 200
+
+// DescribeImages returns the images with the given IDs by ID, including
+// deprecated ones. Deregistered or inaccessible images are left out rather
+// than failing the call.
+func (c *Client) DescribeImages(imageIDs []string) (map[string]types.Image, error) {
+	images := make(map[string]types.Image, len(imageIDs))
+
+	for batch := range slices.Chunk(imageIDs, imageBatchSize) {
+		c.logger.Printf("describing %d images", len(batch))
+
+		// A filter, unlike ImageIds, does not fail on unknown IDs
+		output, err := c.ec2Client.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{
+			Filters:           []types.Filter{{Name: aws.String("image-id"), Values: batch}},
+			IncludeDeprecated: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range output.Images {
+			images[aws.ToString(image.ImageId)] = image
+		}
+	}
+
+	return images, nil
+}
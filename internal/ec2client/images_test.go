@@ -0,0 +1,68 @@
+package ec2client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DescribeImages(t *testing.T) {
+	t.Parallel()
+
+	mockEC2 := new(MockEC2API)
+	mockEC2.On("DescribeImages", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeImagesInput) bool {
+		return *input.Filters[0].Name == "image-id" && *input.IncludeDeprecated &&
+			assert.ObjectsAreEqual([]string{"ami-1", "ami-gone"}, input.Filters[0].Values)
+	})).Return(&ec2.DescribeImagesOutput{
+		Images: []types.Image{{ImageId: aws.String("ami-1"), Name: aws.String("debian-12-amd64")}},
+	}, nil)
+
+	client := NewTestClient(mockEC2, nil, nil)
+	images, err := client.DescribeImages([]string{"ami-1", "ami-gone"})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]types.Image{"ami-1": {ImageId: aws.String("ami-1"), Name: aws.String("debian-12-amd64")}}, images)
+}
+
+func TestClient_DescribeImages_Batches(t *testing.T) {
+	t.Parallel()
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("ami-%d", i)
+	}
+
+	mockEC2 := new(MockEC2API)
+	mockEC2.On("DescribeImages", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeImagesInput) bool {
+		return len(input.Filters[0].Values) == imageBatchSize
+	})).Return(&ec2.DescribeImagesOutput{}, nil).Once()
+	mockEC2.On("DescribeImages", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeImagesInput) bool {
+		return len(input.Filters[0].Values) == 50
+	})).Return(&ec2.DescribeImagesOutput{}, nil).Once()
+
+	client := NewTestClient(mockEC2, nil, nil)
+	_, err := client.DescribeImages(ids)
+
+	require.NoError(t, err)
+	mockEC2.AssertExpectations(t)
+}
+
+func TestClient_DescribeImages_Error(t *testing.T) {
+	t.Parallel()
+
+	mockEC2 := new(MockEC2API)
+	mockEC2.On("DescribeImages", mock.Anything, mock.Anything).Return(nil, errors.New("UnauthorizedOperation"))
+
+	client := NewTestClient(mockEC2, nil, nil)
+	_, err := client.DescribeImages([]string{"ami-1"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "UnauthorizedOperation")
+}
@@ -23,6 +23,7 @@ const (
 	DstTypeIPv6
 	DstTypePrivateDNSName
 	DstTypeNameTag
+	DstTypeTags
 )
 
 // UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
@@ -35,6 +36,7 @@ func (d *DstType) UnmarshalText(text []byte) error {
 		"ipv6":        DstTypeIPv6,
 		"private_dns": DstTypePrivateDNSName,
 		"name_tag":    DstTypeNameTag,
+		"tags":        DstTypeTags,
 	}
 	t, ok := types[string(text)]
 	if !ok {
@@ -52,88 +54,155 @@ var ErrNoMatches = errors.New("no matching instances found")
 
 // GetInstanceByID retrieves an instance by its ID.
 func (c *Client) GetInstanceByID(instanceID string) (types.Instance, error) {
+	instances, err := c.findInstancesByID(instanceID)
+	if err != nil {
+		return types.Instance{}, err
+	}
+
+	return instances[0], nil
+}
+
+// GetRunningInstanceByFilter retrieves a running instance matching the given filter.
+// When several instances match, selector picks one; nil selector makes that an error.
+func (c *Client) GetRunningInstanceByFilter(filterName, filterValue string, selector *Selector) (types.Instance, error) {
+	instances, err := c.findRunningInstancesByFilter(filterName, filterValue)
+	if err != nil {
+		return types.Instance{}, err
+	}
+
+	instance, err := SelectInstance(instances, selector)
+	if err != nil {
+		return types.Instance{}, fmt.Errorf("unable to select an instance with %s=%s: %w", filterName, filterValue, err)
+	}
+
+	c.logger.Printf("selected instance %s", *instance.InstanceId)
+
+	return instance, nil
+}
+
+func (c *Client) findInstancesByID(instanceID string) ([]types.Instance, error) {
 	c.logger.Printf("searching for instance by ID %s", instanceID)
 
 	input := &ec2.DescribeInstancesInput{
 		InstanceIds: []string{instanceID},
 	}
 
-	instance, err := c.getFirstMatchingInstance(input)
+	instances, err := c.getMatchingInstances(input)
 	if err != nil {
-		return types.Instance{}, fmt.Errorf("unable to find an instance with ID=%s: %w", instanceID, err)
+		return nil, fmt.Errorf("unable to find an instance with ID=%s: %w", instanceID, err)
 	}
 
-	return instance, nil
+	return instances, nil
 }
 
-// GetRunningInstanceByFilter retrieves a running instance matching the given filter.
-func (c *Client) GetRunningInstanceByFilter(filterName, filterValue string) (types.Instance, error) {
+func (c *Client) findRunningInstancesByFilter(filterName, filterValue string) ([]types.Instance, error) {
 	c.logger.Printf("searching for instance by %s=%s", filterName, filterValue)
 
-	input := &ec2.DescribeInstancesInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String(filterName),
-				Values: []string{filterValue},
-			},
-			{
-				Name:   aws.String("instance-state-name"),
-				Values: []string{"running"},
-			},
+	filters := []types.Filter{
+		{
+			Name:   aws.String(filterName),
+			Values: []string{filterValue},
 		},
 	}
 
-	instance, err := c.getFirstMatchingInstance(input)
+	instances, err := c.getRunningInstances(filters)
 	if err != nil {
-		return types.Instance{}, fmt.Errorf("unable to find a runnning instance with %s=%s: %w", filterName, filterValue, err)
+		return nil, fmt.Errorf("unable to find a runnning instance with %s=%s: %w", filterName, filterValue, err)
 	}
 
-	return instance, nil
+	return instances, nil
 }
 
-// ListInstances returns all instances in the region.
-func (c *Client) ListInstances() ([]types.Instance, error) {
-	c.logger.Printf("listing all instances")
-
-	input := &ec2.DescribeInstancesInput{}
+// findRunningInstancesByTags finds running instances matching a tag expression.
+// See ParseTagExpression for the syntax.
+func (c *Client) findRunningInstancesByTags(expression string) ([]types.Instance, error) {
+	c.logger.Printf("searching for instance by tags %s", expression)
 
-	result, err := c.ec2Client.DescribeInstances(context.TODO(), input)
+	filters, err := ParseTagExpression(expression)
 	if err != nil {
 		return nil, err
 	}
 
-	var instances []types.Instance
-
-	for _, reservation := range result.Reservations {
-		instances = append(instances, reservation.Instances...)
+	instances, err := c.getRunningInstances(filters)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find a runnning instance with tags %s: %w", expression, err)
 	}
 
 	return instances, nil
 }
 
-func (c *Client) getFirstMatchingInstance(input *ec2.DescribeInstancesInput) (types.Instance, error) {
-	result, err := c.ec2Client.DescribeInstances(context.TODO(), input)
+// getRunningInstances returns all running instances matching the filters.
+func (c *Client) getRunningInstances(filters []types.Filter) ([]types.Instance, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: append(filters, types.Filter{
+			Name:   aws.String("instance-state-name"),
+			Values: []string{"running"},
+		}),
+	}
+
+	return c.getMatchingInstances(input)
+}
+
+// ListInstances returns all instances in the region matching the query's filters.
+// All pages are fetched; an empty result is not an error.
+func (c *Client) ListInstances(query ListQuery) ([]types.Instance, error) {
+	c.logger.Printf("listing instances with %d filters", len(query.Filters))
+
+	pageSize := query.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	input := &ec2.DescribeInstancesInput{
+		Filters:    query.Filters,
+		MaxResults: aws.Int32(int32(pageSize)),
+	}
+
+	return c.describeInstances(input)
+}
+
+// getMatchingInstances returns all instances matching the input across all pages.
+// Returns ErrNoMatches if nothing matches.
+func (c *Client) getMatchingInstances(input *ec2.DescribeInstancesInput) ([]types.Instance, error) {
+	instances, err := c.describeInstances(input)
 	if err != nil {
-		return types.Instance{}, err
+		return nil, err
 	}
 
-	c.logger.Printf("found %d reservations", len(result.Reservations))
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoMatches, c.region)
+	}
+
+	c.logger.Printf("found %d matching instances", len(instances))
+
+	return instances, nil
+}
+
+// describeInstances returns all instances matching the input across all pages.
+func (c *Client) describeInstances(input *ec2.DescribeInstancesInput) ([]types.Instance, error) {
+	var instances []types.Instance
+
+	paginator := ec2.NewDescribeInstancesPaginator(c.ec2Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
 
-	for rsvIdx, reservation := range result.Reservations {
-		c.logger.Printf("found %d instances in reservation %d", len(reservation.Instances), rsvIdx)
+		c.logger.Printf("found %d reservations", len(page.Reservations))
 
-		for _, instance := range reservation.Instances {
-			c.logger.Printf("selected first matching instance %s", *instance.InstanceId)
+		for rsvIdx, reservation := range page.Reservations {
+			c.logger.Printf("found %d instances in reservation %d", len(reservation.Instances), rsvIdx)
 
-			return instance, nil
+			instances = append(instances, reservation.Instances...)
 		}
 	}
 
-	return types.Instance{}, fmt.Errorf("%w in %s", ErrNoMatches, c.region)
+	return instances, nil
 }
 
 // DstTypeToAddrType maps a destination type to an address type.
-// Returns nil for DstType values that don't imply a specific address type (ID, NameTag).
+// Returns nil for DstType values that don't imply a specific address type (ID, NameTag, Tags).
 func DstTypeToAddrType(dstType DstType) *AddrType {
 	switch dstType {
 	case DstTypePrivateIP, DstTypePrivateDNSName:
@@ -146,13 +215,15 @@ func DstTypeToAddrType(dstType DstType) *AddrType {
 		t := AddrTypeIPv6
 		return &t
 	default:
-		return nil // DstTypeID, DstTypeNameTag don't imply specific address type
+		return nil // DstTypeID, DstTypeNameTag, DstTypeTags don't imply specific address type
 	}
 }
 
 // GuessDestinationType infers the destination type from the destination string.
 func GuessDestinationType(dst string) DstType {
 	switch {
+	case IsTagExpression(dst):
+		return DstTypeTags
 	case strings.HasPrefix(dst, "ip-"),
 		strings.HasSuffix(dst, ".ec2.internal"),
 		strings.HasSuffix(dst, ".compute.internal"):
@@ -175,9 +246,10 @@ func GuessDestinationType(dst string) DstType {
 	}
 }
 
-// GetInstance retrieves an instance using the specified destination type and value.
+// FindInstances returns all instances matching the destination.
 // If dstType is nil, auto-detects the type from the destination string.
-func (c *Client) GetInstance(destination string, dstType *DstType) (types.Instance, error) {
+// Only running instances are matched, except when looking up by instance ID.
+func (c *Client) FindInstances(destination string, dstType *DstType) ([]types.Instance, error) {
 	// nil means auto-detect
 	if dstType == nil {
 		guessed := GuessDestinationType(destination)
@@ -189,7 +261,7 @@ func (c *Client) GetInstance(destination string, dstType *DstType) (types.Instan
 
 	switch *dstType {
 	case DstTypeID:
-		return c.GetInstanceByID(destination)
+		return c.findInstancesByID(destination)
 	case DstTypePrivateIP:
 		filterName = "private-ip-address"
 	case DstTypePublicIP:
@@ -204,9 +276,35 @@ func (c *Client) GetInstance(destination string, dstType *DstType) (types.Instan
 		}
 	case DstTypeNameTag:
 		filterName = "tag:Name"
+	case DstTypeTags:
+		return c.findRunningInstancesByTags(destination)
 	default:
 		panic(fmt.Sprintf("unexpected DstType: %d", *dstType))
 	}
 
-	return c.GetRunningInstanceByFilter(filterName, destination)
+	return c.findRunningInstancesByFilter(filterName, destination)
+}
+
+// GetInstance retrieves an instance using the specified destination type and value.
+// If dstType is nil, auto-detects the type from the destination string.
+// A trailing "#N" in the destination selects the N-th match and overrides selector.
+func (c *Client) GetInstance(destination string, dstType *DstType, selector *Selector) (types.Instance, error) {
+	destination, indexSelector := SplitDestinationIndex(destination)
+	if indexSelector != nil {
+		selector = indexSelector
+	}
+
+	instances, err := c.FindInstances(destination, dstType)
+	if err != nil {
+		return types.Instance{}, err
+	}
+
+	instance, err := SelectInstance(instances, selector)
+	if err != nil {
+		return types.Instance{}, fmt.Errorf("unable to select an instance for %s: %w", destination, err)
+	}
+
+	c.logger.Printf("selected instance %s", *instance.InstanceId)
+
+	return instance, nil
 }
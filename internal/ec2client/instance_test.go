@@ -3,6 +3,7 @@ package ec2client
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/stretchr/testify/assert"
@@ -42,6 +43,10 @@ func TestDstType_UnmarshalText(t *testing.T) {
 			input: "name_tag",
 			want:  DstTypeNameTag,
 		},
+		"tags": {
+			input: "tags",
+			want:  DstTypeTags,
+		},
 		"empty string is error": {
 			input:   "",
 			wantErr: true, // Use *DstType with nil for auto-detect
@@ -164,6 +169,20 @@ func TestGuessDestinationType(t *testing.T) {
 			want: DstTypeIPv6,
 		},
 
+		// Tag expressions
+		"tag expression single": {
+			dst:  "Env=prod",
+			want: DstTypeTags,
+		},
+		"tag expression multiple": {
+			dst:  "Env=prod,Role=api",
+			want: DstTypeTags,
+		},
+		"tag expression with wildcard": {
+			dst:  "Role=api-*,Backup",
+			want: DstTypeTags,
+		},
+
 		// Name tags (fallback)
 		"name tag simple": {
 			dst:  "my-server",
@@ -330,7 +349,7 @@ func TestClient_GetRunningInstanceByFilter(t *testing.T) {
 			tc.mockSetup(mockEC2)
 
 			client := NewTestClient(mockEC2, nil, nil)
-			instance, err := client.GetRunningInstanceByFilter(tc.filterName, tc.filterValue)
+			instance, err := client.GetRunningInstanceByFilter(tc.filterName, tc.filterValue, nil)
 
 			if tc.wantErr {
 				require.Error(t, err)
@@ -348,12 +367,25 @@ func TestClient_GetRunningInstanceByFilter(t *testing.T) {
 func TestClient_GetInstance(t *testing.T) {
 	t.Parallel()
 
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	twoWebServers := func(m *MockEC2API) {
+		m.On("DescribeInstances", mock.Anything, mock.Anything).Return(
+			MakeDescribeOutput(MakeReservation(
+				MakeInstance("i-newer", WithNameTag("web"), WithLaunchTime(newer)),
+				MakeInstance("i-older", WithNameTag("web"), WithLaunchTime(older)),
+			)),
+			nil,
+		)
+	}
+
 	tests := map[string]struct {
-		dstType     *DstType // nil = auto-detect
+		dstType     *DstType  // nil = auto-detect
+		selector    *Selector // nil = ambiguity is an error
 		destination string
 		mockSetup   func(*MockEC2API)
 		wantID      string
-		wantErr     bool
+		wantErr     error
 	}{
 		"nil auto-detects instance id": {
 			dstType:     nil,
@@ -458,6 +490,49 @@ func TestClient_GetInstance(t *testing.T) {
 			},
 			wantID: "i-named",
 		},
+		"auto-detected tag expression": {
+			destination: "Env=prod,Role=api*,Backup",
+			mockSetup: func(m *MockEC2API) {
+				m.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+					filters := make(map[string]string)
+					for _, f := range input.Filters {
+						filters[*f.Name] = f.Values[0]
+					}
+					return len(filters) == 4 &&
+						filters["tag:Env"] == "prod" &&
+						filters["tag:Role"] == "api*" &&
+						filters["tag:Backup"] == "*" &&
+						filters["instance-state-name"] == "running"
+				})).Return(
+					MakeDescribeOutput(MakeReservation(MakeInstance("i-tagged"))),
+					nil,
+				)
+			},
+			wantID: "i-tagged",
+		},
+		"explicit tags with key only": {
+			dstType:     DstTypePtr(DstTypeTags),
+			destination: "Backup",
+			mockSetup: func(m *MockEC2API) {
+				m.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+					for _, f := range input.Filters {
+						if *f.Name == "tag:Backup" && f.Values[0] == "*" {
+							return true
+						}
+					}
+					return false
+				})).Return(
+					MakeDescribeOutput(MakeReservation(MakeInstance("i-backup"))),
+					nil,
+				)
+			},
+			wantID: "i-backup",
+		},
+		"invalid tag expression": {
+			destination: "Env=prod,,Role=api",
+			mockSetup:   func(*MockEC2API) {},
+			wantErr:     ErrTagExpression,
+		},
 		"explicit ipv6": {
 			dstType:     DstTypePtr(DstTypeIPv6),
 			destination: "2001:db8::1",
@@ -485,7 +560,50 @@ func TestClient_GetInstance(t *testing.T) {
 					nil,
 				)
 			},
-			wantErr: true,
+			wantErr: ErrNoMatches,
+		},
+		"multiple matches without selector": {
+			destination: "web",
+			mockSetup:   twoWebServers,
+			wantErr:     ErrAmbiguous,
+		},
+		"multiple matches select newest": {
+			destination: "web",
+			selector:    &Selector{Kind: SelectNewest},
+			mockSetup:   twoWebServers,
+			wantID:      "i-newer",
+		},
+		"multiple matches select oldest": {
+			destination: "web",
+			selector:    &Selector{Kind: SelectOldest},
+			mockSetup:   twoWebServers,
+			wantID:      "i-older",
+		},
+		"index suffix selects in launch order": {
+			destination: "web#2",
+			mockSetup: func(m *MockEC2API) {
+				m.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+					for _, f := range input.Filters {
+						if *f.Name == "tag:Name" && f.Values[0] == "web" {
+							return true
+						}
+					}
+					return false
+				})).Return(
+					MakeDescribeOutput(MakeReservation(
+						MakeInstance("i-newer", WithNameTag("web"), WithLaunchTime(newer)),
+						MakeInstance("i-older", WithNameTag("web"), WithLaunchTime(older)),
+					)),
+					nil,
+				)
+			},
+			wantID: "i-newer",
+		},
+		"index suffix overrides selector": {
+			destination: "web#1",
+			selector:    &Selector{Kind: SelectNewest},
+			mockSetup:   twoWebServers,
+			wantID:      "i-older",
 		},
 	}
 
@@ -497,10 +615,10 @@ func TestClient_GetInstance(t *testing.T) {
 			tc.mockSetup(mockEC2)
 
 			client := NewTestClient(mockEC2, nil, nil)
-			instance, err := client.GetInstance(tc.destination, tc.dstType)
+			instance, err := client.GetInstance(tc.destination, tc.dstType, tc.selector)
 
-			if tc.wantErr {
-				require.Error(t, err)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
 				return
 			}
 
@@ -570,7 +688,7 @@ func TestClient_ListInstances(t *testing.T) {
 			tc.mockSetup(mockEC2)
 
 			client := NewTestClient(mockEC2, nil, nil)
-			instances, err := client.ListInstances()
+			instances, err := client.ListInstances(ListQuery{})
 
 			if tc.wantErr {
 				require.Error(t, err)
@@ -615,6 +733,10 @@ func TestDstTypeToAddrType(t *testing.T) {
 			dstType: DstTypeNameTag,
 			want:    nil,
 		},
+		"tags maps to nil (auto-detect)": {
+			dstType: DstTypeTags,
+			want:    nil,
+		},
 	}
 
 	for name, tc := range tests {
@@ -0,0 +1,87 @@
+package ec2client
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// DefaultPageSize is the number of instances requested per DescribeInstances
+// call when no page size is given. It is the API maximum.
+const DefaultPageSize PageSize = 1000
+
+// PageSize is the number of instances requested per DescribeInstances call.
+type PageSize int32
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// The EC2 API accepts 5 to 1000.
+func (p *PageSize) UnmarshalText(text []byte) error {
+	size, err := strconv.Atoi(string(text))
+	if err != nil || size < 5 || size > 1000 {
+		return fmt.Errorf("invalid page size: %s (must be between 5 and 1000)", text)
+	}
+	*p = PageSize(size)
+	return nil
+}
+
+// Filters collects EC2 API filters given as name=value.
+// Each UnmarshalText call appends one filter, so the flag may be repeated.
+type Filters struct {
+	List []types.Filter
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts name=value, where value may be a comma-separated list of alternatives,
+// e.g. "instance-type=t3.micro,t3.small" or "tag:Env=prod".
+func (f *Filters) UnmarshalText(text []byte) error {
+	name, value, ok := strings.Cut(string(text), "=")
+	if !ok || name == "" || value == "" {
+		return fmt.Errorf("invalid filter: %s (expected name=value)", text)
+	}
+
+	values := strings.Split(value, ",")
+	if slices.Contains(values, "") {
+		return fmt.Errorf("invalid filter: %s (empty value)", text)
+	}
+
+	f.List = append(f.List, types.Filter{Name: aws.String(name), Values: values})
+	return nil
+}
+
+// States selects instances by state.
+// Use a pointer to States where nil means any state.
+type States struct {
+	Names []string
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts a comma-separated list of instance states, e.g. "running,stopped".
+func (s *States) UnmarshalText(text []byte) error {
+	valid := types.InstanceStateNameRunning.Values()
+
+	var names []string
+	for _, name := range strings.Split(string(text), ",") {
+		if !slices.Contains(valid, types.InstanceStateName(name)) {
+			return fmt.Errorf("unknown instance state: %s", name)
+		}
+		names = append(names, name)
+	}
+
+	*s = States{Names: names}
+	return nil
+}
+
+// Filter returns the instance-state-name filter for the states.
+func (s States) Filter() types.Filter {
+	return types.Filter{Name: aws.String("instance-state-name"), Values: s.Names}
+}
+
+// ListQuery narrows and pages ListInstances.
+type ListQuery struct {
+	Filters  []types.Filter // Server-side EC2 filters, nil = all instances
+	PageSize PageSize       // 0 = DefaultPageSize
+}
@@ -0,0 +1,184 @@
+package ec2client
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageSize_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    PageSize
+		wantErr bool
+	}{
+		"minimum":      {input: "5", want: 5},
+		"maximum":      {input: "1000", want: 1000},
+		"too small":    {input: "4", wantErr: true},
+		"too large":    {input: "1001", wantErr: true},
+		"not a number": {input: "many", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got PageSize
+			err := got.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "invalid page size")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFilters_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		inputs  []string
+		want    []types.Filter
+		wantErr bool
+	}{
+		"single": {
+			inputs: []string{"instance-type=t3.micro"},
+			want:   []types.Filter{{Name: aws.String("instance-type"), Values: []string{"t3.micro"}}},
+		},
+		"multiple values": {
+			inputs: []string{"instance-type=t3.micro,t3.small"},
+			want:   []types.Filter{{Name: aws.String("instance-type"), Values: []string{"t3.micro", "t3.small"}}},
+		},
+		"repeated accumulates": {
+			inputs: []string{"tag:Env=prod", "vpc-id=vpc-123"},
+			want: []types.Filter{
+				{Name: aws.String("tag:Env"), Values: []string{"prod"}},
+				{Name: aws.String("vpc-id"), Values: []string{"vpc-123"}},
+			},
+		},
+		"value with equals": {
+			inputs: []string{"tag:Query=a=b"},
+			want:   []types.Filter{{Name: aws.String("tag:Query"), Values: []string{"a=b"}}},
+		},
+		"missing equals":  {inputs: []string{"running"}, wantErr: true},
+		"empty name":      {inputs: []string{"=x"}, wantErr: true},
+		"empty value":     {inputs: []string{"vpc-id="}, wantErr: true},
+		"empty list item": {inputs: []string{"vpc-id=a,,b"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got Filters
+			var err error
+			for _, input := range tc.inputs {
+				if err = got.UnmarshalText([]byte(input)); err != nil {
+					break
+				}
+			}
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "invalid filter")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.List)
+		})
+	}
+}
+
+func TestStates_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		"single":        {input: "running", want: []string{"running"}},
+		"list":          {input: "running,stopped", want: []string{"running", "stopped"}},
+		"shutting down": {input: "shutting-down", want: []string{"shutting-down"}},
+		"unknown state": {input: "running,asleep", wantErr: true},
+		"empty":         {input: "", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got States
+			err := got.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown instance state")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.Names)
+			assert.Equal(t, types.Filter{Name: aws.String("instance-state-name"), Values: tc.want}, got.Filter())
+		})
+	}
+}
+
+func TestClient_ListInstances_Paginates(t *testing.T) {
+	t.Parallel()
+
+	filters := []types.Filter{{Name: aws.String("tag:Env"), Values: []string{"prod"}}}
+
+	mockEC2 := new(MockEC2API)
+	firstPage := MakeDescribeOutput(MakeReservation(MakeInstance("i-1"), MakeInstance("i-2")))
+	firstPage.NextToken = aws.String("page-2")
+	mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return input.NextToken == nil
+	})).Return(firstPage, nil).Once()
+	mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return input.NextToken != nil && *input.NextToken == "page-2"
+	})).Return(MakeDescribeOutput(MakeReservation(MakeInstance("i-3"))), nil).Once()
+
+	client := NewTestClient(mockEC2, nil, nil)
+	instances, err := client.ListInstances(ListQuery{Filters: filters, PageSize: 50})
+
+	require.NoError(t, err)
+	require.Len(t, instances, 3)
+	assert.Equal(t, "i-3", *instances[2].InstanceId)
+	mockEC2.AssertExpectations(t)
+
+	for _, call := range mockEC2.Calls {
+		input := call.Arguments.Get(1).(*ec2.DescribeInstancesInput)
+		assert.Equal(t, filters, input.Filters)
+		assert.Equal(t, int32(50), aws.ToInt32(input.MaxResults))
+	}
+}
+
+func TestClient_ListInstances_DefaultPageSize(t *testing.T) {
+	t.Parallel()
+
+	mockEC2 := new(MockEC2API)
+	mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return aws.ToInt32(input.MaxResults) == int32(DefaultPageSize)
+	})).Return(MakeDescribeOutput(), nil)
+
+	client := NewTestClient(mockEC2, nil, nil)
+	instances, err := client.ListInstances(ListQuery{})
+
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+	mockEC2.AssertExpectations(t)
+}
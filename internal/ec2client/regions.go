@@ -0,0 +1,285 @@
+package ec2client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// RegionsAll is the Regions flag value that expands to every region enabled for the account.
+const RegionsAll = "all"
+
+// WarningOutput receives the warnings of Warnf, overridden in tests.
+var WarningOutput io.Writer = os.Stderr
+
+// Warnf prints a warning that does not stop the operation to WarningOutput,
+// and logs it to logger for --debug output.
+func Warnf(logger *log.Logger, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	_ = logger.Output(2, message)
+	_, _ = fmt.Fprintf(WarningOutput, "warning: %s\n", message)
+}
+
+// SkipFailures lets a search over several locations (profiles or regions)
+// go on without those that failed, such as a profile with expired
+// credentials or a region that denies access. Each failure is warned about;
+// an error is returned only when all total locations failed.
+func SkipFailures(logger *log.Logger, total int, failures []error) error {
+	if len(failures) == total {
+		return errors.Join(failures...)
+	}
+	for _, err := range failures {
+		Warnf(logger, "skipping %v", err)
+	}
+	return nil
+}
+
+// Regions selects the regions to search.
+// Use a pointer to Regions where nil means the single region from the AWS config.
+type Regions struct {
+	Names []string // Explicit region names, empty when All is set
+	All   bool     // Every region enabled for the account
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts "all" or a comma-separated list of region names.
+func (r *Regions) UnmarshalText(text []byte) error {
+	if string(text) == RegionsAll {
+		*r = Regions{All: true}
+		return nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(text), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == RegionsAll {
+			return fmt.Errorf("invalid region list: %s", text)
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	*r = Regions{Names: names}
+	return nil
+}
+
+// Region returns the region the client operates in.
+func (c *Client) Region() string {
+	return c.region
+}
+
+// ListRegions returns the names of regions enabled for the account, sorted.
+func (c *Client) ListRegions() ([]string, error) {
+	c.logger.Printf("listing enabled regions")
+
+	result, err := c.ec2Client.DescribeRegions(context.TODO(), &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list regions: %w", err)
+	}
+
+	var regions []string
+	for _, region := range result.Regions {
+		if region.RegionName != nil {
+			regions = append(regions, *region.RegionName)
+		}
+	}
+	slices.Sort(regions)
+
+	c.logger.Printf("found %d enabled regions", len(regions))
+
+	return regions, nil
+}
+
+// RegionalInstance is an instance together with the region and profile it was found in.
+type RegionalInstance struct {
+	types.Instance
+	Region  string
+	Profile string // Empty for the SDK default profile
+}
+
+// newRegionalInstance labels instance with the client's region and profile.
+func newRegionalInstance(instance types.Instance, client *Client) RegionalInstance {
+	return RegionalInstance{Instance: instance, Region: client.Region(), Profile: client.Profile()}
+}
+
+// forEachClient calls fn for every client concurrently and returns results in client order.
+func forEachClient[T any](clients []*Client, fn func(*Client) (T, error)) ([]T, []error) {
+	results := make([]T, len(clients))
+	errs := make([]error, len(clients))
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Go(func() {
+			results[i], errs[i] = fn(client)
+		})
+	}
+	wg.Wait()
+
+	return results, errs
+}
+
+// FindInstancesInRegions runs FindInstances with every client concurrently
+// and pools the matches. Clients may differ by region and profile. Locations
+// without matches are skipped; ErrNoMatches is returned only if none has any.
+// Failing locations are skipped as by SkipFailures.
+func FindInstancesInRegions(clients []*Client, destination string, dstType *DstType) ([]RegionalInstance, error) {
+	results, errs := forEachClient(clients, func(c *Client) ([]types.Instance, error) {
+		return c.FindInstances(destination, dstType)
+	})
+
+	var matches []RegionalInstance
+	var searched []string
+	var failures []error
+
+	for i, client := range clients {
+		if err := errs[i]; err != nil {
+			if errors.Is(err, ErrNoMatches) {
+				searched = append(searched, client.Location())
+				continue
+			}
+			failures = append(failures, fmt.Errorf("%s: %w", client.Location(), err))
+			continue
+		}
+		for _, instance := range results[i] {
+			matches = append(matches, newRegionalInstance(instance, client))
+		}
+	}
+
+	if len(failures) > 0 {
+		if err := SkipFailures(clients[0].logger, len(clients), failures); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("unable to find an instance for %s: %w in %s", destination, ErrNoMatches, strings.Join(searched, ", "))
+	}
+
+	return matches, nil
+}
+
+// GetInstanceInRegions is GetInstance across several regions and profiles:
+// matches from all clients are pooled before the selector is applied. On
+// ambiguity the returned *AmbiguousError carries the region and profile of
+// every candidate.
+func GetInstanceInRegions(clients []*Client, destination string, dstType *DstType, selector *Selector) (RegionalInstance, error) {
+	if len(clients) == 1 {
+		instance, err := clients[0].GetInstance(destination, dstType, selector)
+		return newRegionalInstance(instance, clients[0]), withLocation(err, clients[0])
+	}
+
+	destination, indexSelector := SplitDestinationIndex(destination)
+	if indexSelector != nil {
+		selector = indexSelector
+	}
+
+	matches, err := FindInstancesInRegions(clients, destination, dstType)
+	if err != nil {
+		return RegionalInstance{}, err
+	}
+
+	regions := make(map[string]string, len(matches))
+	profiles := make(map[string]string, len(matches))
+	instances := make([]types.Instance, len(matches))
+	for i, match := range matches {
+		regions[*match.InstanceId] = match.Region
+		profiles[*match.InstanceId] = match.Profile
+		instances[i] = match.Instance
+	}
+
+	instance, err := SelectInstance(instances, selector)
+	if err != nil {
+		var ambiguous *AmbiguousError
+		if errors.As(err, &ambiguous) {
+			ambiguous.Regions = regions
+			ambiguous.Profiles = profiles
+		}
+		return RegionalInstance{}, fmt.Errorf("unable to select an instance for %s: %w", destination, err)
+	}
+
+	return RegionalInstance{
+		Instance: instance,
+		Region:   regions[*instance.InstanceId],
+		Profile:  profiles[*instance.InstanceId],
+	}, nil
+}
+
+// withLocation records the single client's region and profile on an ambiguity error.
+func withLocation(err error, client *Client) error {
+	var ambiguous *AmbiguousError
+	if errors.As(err, &ambiguous) {
+		ambiguous.Regions = make(map[string]string, len(ambiguous.Candidates))
+		ambiguous.Profiles = make(map[string]string, len(ambiguous.Candidates))
+		for _, candidate := range ambiguous.Candidates {
+			ambiguous.Regions[*candidate.InstanceId] = client.Region()
+			ambiguous.Profiles[*candidate.InstanceId] = client.Profile()
+		}
+	}
+	return err
+}
+
+// ListInstancesInRegions lists instances matching query with every client concurrently.
+// Results are grouped by client in client order. Failing locations are
+// skipped as by SkipFailures.
+func ListInstancesInRegions(clients []*Client, query ListQuery) ([]RegionalInstance, error) {
+	results, errs := forEachClient(clients, func(c *Client) ([]types.Instance, error) {
+		return c.ListInstances(query)
+	})
+
+	if len(clients) == 1 && errs[0] != nil {
+		return nil, errs[0]
+	}
+
+	var instances []RegionalInstance
+	var failures []error
+	for i, client := range clients {
+		if errs[i] != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", client.Location(), errs[i]))
+			continue
+		}
+		for _, instance := range results[i] {
+			instances = append(instances, newRegionalInstance(instance, client))
+		}
+	}
+
+	if len(failures) > 0 {
+		if err := SkipFailures(clients[0].logger, len(clients), failures); err != nil {
+			return nil, err
+		}
+	}
+
+	return instances, nil
+}
+
+// ListInstancesInRegionsStrict is ListInstancesInRegions failing if any
+// location fails, for callers that act on an instance being absent.
+func ListInstancesInRegionsStrict(clients []*Client, query ListQuery) ([]RegionalInstance, error) {
+	results, errs := forEachClient(clients, func(c *Client) ([]types.Instance, error) {
+		return c.ListInstances(query)
+	})
+
+	var instances []RegionalInstance
+	for i, client := range clients {
+		if errs[i] != nil {
+			if len(clients) == 1 {
+				return nil, errs[i]
+			}
+			return nil, fmt.Errorf("%s: %w", client.Location(), errs[i])
+		}
+		for _, instance := range results[i] {
+			instances = append(instances, newRegionalInstance(instance, client))
+		}
+	}
+
+	return instances, nil
+}
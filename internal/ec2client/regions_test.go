@@ -0,0 +1,385 @@
+package ec2client
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegions_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    Regions
+		wantErr bool
+	}{
+		"all": {
+			input: "all",
+			want:  Regions{All: true},
+		},
+		"single region": {
+			input: "us-east-1",
+			want:  Regions{Names: []string{"us-east-1"}},
+		},
+		"list keeps order": {
+			input: "eu-west-1,us-east-1",
+			want:  Regions{Names: []string{"eu-west-1", "us-east-1"}},
+		},
+		"spaces and duplicates": {
+			input: "us-east-1, eu-west-1 ,us-east-1",
+			want:  Regions{Names: []string{"us-east-1", "eu-west-1"}},
+		},
+		"empty string": {
+			input:   "",
+			wantErr: true,
+		},
+		"empty element": {
+			input:   "us-east-1,,eu-west-1",
+			wantErr: true,
+		},
+		"all mixed with names": {
+			input:   "all,us-east-1",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got Regions
+			err := got.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "invalid region list")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClient_ListRegions(t *testing.T) {
+	t.Parallel()
+
+	mockEC2 := new(MockEC2API)
+	mockEC2.On("DescribeRegions", mock.Anything, mock.Anything).Return(
+		MakeDescribeRegionsOutput("us-west-2", "eu-west-1", "us-east-1"), nil,
+	)
+
+	regions, err := NewTestClient(mockEC2, nil, nil).ListRegions()
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1", "us-east-1", "us-west-2"}, regions)
+}
+
+func TestClient_ListRegions_Error(t *testing.T) {
+	t.Parallel()
+
+	mockEC2 := new(MockEC2API)
+	mockEC2.On("DescribeRegions", mock.Anything, mock.Anything).Return(nil, errors.New("access denied"))
+
+	_, err := NewTestClient(mockEC2, nil, nil).ListRegions()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to list regions")
+}
+
+// regionalClient creates a test client in region whose DescribeInstances returns instances.
+func regionalClient(region string, instances ...types.Instance) *Client {
+	mockEC2 := new(MockEC2API)
+	output := MakeDescribeOutput()
+	if len(instances) > 0 {
+		output = MakeDescribeOutput(MakeReservation(instances...))
+	}
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything).Return(output, nil)
+	return NewTestClientInRegion(region, mockEC2, nil, nil)
+}
+
+func TestFindInstancesInRegions(t *testing.T) {
+	t.Parallel()
+
+	clients := []*Client{
+		regionalClient("us-east-1"),
+		regionalClient("eu-west-1", MakeInstance("i-eu", WithNameTag("web"))),
+		regionalClient("ap-south-1", MakeInstance("i-ap", WithNameTag("web"))),
+	}
+
+	matches, err := FindInstancesInRegions(clients, "web", nil)
+
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "i-eu", *matches[0].InstanceId)
+	assert.Equal(t, "eu-west-1", matches[0].Region)
+	assert.Equal(t, "i-ap", *matches[1].InstanceId)
+	assert.Equal(t, "ap-south-1", matches[1].Region)
+}
+
+func TestFindInstancesInRegions_NoMatches(t *testing.T) {
+	t.Parallel()
+
+	clients := []*Client{regionalClient("us-east-1"), regionalClient("eu-west-1")}
+
+	_, err := FindInstancesInRegions(clients, "web", nil)
+
+	require.ErrorIs(t, err, ErrNoMatches)
+	assert.Contains(t, err.Error(), "us-east-1, eu-west-1")
+}
+
+// failingClient creates a test client in region whose DescribeInstances fails with err.
+func failingClient(region string, err error) *Client {
+	mockEC2 := new(MockEC2API)
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything).Return(nil, err)
+	return NewTestClientInRegion(region, mockEC2, nil, nil)
+}
+
+// captureWarnings redirects WarningOutput for the rest of the test.
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	origWarningOutput := WarningOutput
+	t.Cleanup(func() { WarningOutput = origWarningOutput })
+	warnings := new(bytes.Buffer)
+	WarningOutput = warnings
+	return warnings
+}
+
+func TestFindInstancesInRegions_SkipsFailingRegion(t *testing.T) {
+	// No t.Parallel() - modifies WarningOutput
+
+	warnings := captureWarnings(t)
+	clients := []*Client{
+		regionalClient("us-east-1", MakeInstance("i-us", WithNameTag("web"))),
+		failingClient("eu-west-1", errors.New("UnauthorizedOperation")),
+	}
+
+	matches, err := FindInstancesInRegions(clients, "web", nil)
+
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "i-us", *matches[0].InstanceId)
+	assert.Contains(t, warnings.String(), "warning: skipping eu-west-1: ")
+	assert.Contains(t, warnings.String(), "UnauthorizedOperation")
+}
+
+func TestFindInstancesInRegions_AllRegionsFail(t *testing.T) {
+	// No t.Parallel() - modifies WarningOutput
+
+	warnings := captureWarnings(t)
+	clients := []*Client{
+		failingClient("us-east-1", errors.New("ExpiredToken")),
+		failingClient("eu-west-1", errors.New("UnauthorizedOperation")),
+	}
+
+	_, err := FindInstancesInRegions(clients, "web", nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "us-east-1: ")
+	assert.Contains(t, err.Error(), "ExpiredToken")
+	assert.Contains(t, err.Error(), "eu-west-1: ")
+	assert.Contains(t, err.Error(), "UnauthorizedOperation")
+	assert.Empty(t, warnings.String())
+}
+
+func TestGetInstanceInRegions(t *testing.T) {
+	t.Parallel()
+
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		clients     []*Client
+		destination string
+		selector    *Selector
+		wantID      string
+		wantRegion  string
+		wantErr     error
+	}{
+		"single match in another region": {
+			clients: []*Client{
+				regionalClient("us-east-1"),
+				regionalClient("eu-west-1", MakeInstance("i-eu", WithNameTag("web"))),
+			},
+			destination: "web",
+			wantID:      "i-eu",
+			wantRegion:  "eu-west-1",
+		},
+		"ambiguous across regions": {
+			clients: []*Client{
+				regionalClient("us-east-1", MakeInstance("i-us", WithNameTag("web"))),
+				regionalClient("eu-west-1", MakeInstance("i-eu", WithNameTag("web"))),
+			},
+			destination: "web",
+			wantErr:     ErrAmbiguous,
+		},
+		"selector across regions": {
+			clients: []*Client{
+				regionalClient("us-east-1", MakeInstance("i-us", WithNameTag("web"), WithLaunchTime(older))),
+				regionalClient("eu-west-1", MakeInstance("i-eu", WithNameTag("web"), WithLaunchTime(newer))),
+			},
+			destination: "web",
+			selector:    &Selector{Kind: SelectNewest},
+			wantID:      "i-eu",
+			wantRegion:  "eu-west-1",
+		},
+		"index suffix across regions": {
+			clients: []*Client{
+				regionalClient("us-east-1", MakeInstance("i-us", WithNameTag("web"), WithLaunchTime(older))),
+				regionalClient("eu-west-1", MakeInstance("i-eu", WithNameTag("web"), WithLaunchTime(newer))),
+			},
+			destination: "web#1",
+			wantID:      "i-us",
+			wantRegion:  "us-east-1",
+		},
+		"single client": {
+			clients:     []*Client{regionalClient("us-west-2", MakeInstance("i-only", WithNameTag("web")))},
+			destination: "web",
+			wantID:      "i-only",
+			wantRegion:  "us-west-2",
+		},
+		"no matches anywhere": {
+			clients:     []*Client{regionalClient("us-east-1"), regionalClient("eu-west-1")},
+			destination: "web",
+			wantErr:     ErrNoMatches,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			match, err := GetInstanceInRegions(tc.clients, tc.destination, nil, tc.selector)
+
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantID, *match.InstanceId)
+			assert.Equal(t, tc.wantRegion, match.Region)
+		})
+	}
+}
+
+func TestGetInstanceInRegions_AmbiguityListsRegions(t *testing.T) {
+	t.Parallel()
+
+	clients := []*Client{
+		regionalClient("us-east-1", MakeInstance("i-us", WithNameTag("web"))),
+		regionalClient("eu-west-1", MakeInstance("i-eu", WithNameTag("web"))),
+	}
+
+	_, err := GetInstanceInRegions(clients, "web", nil, nil)
+
+	var ambiguous *AmbiguousError
+	require.True(t, errors.As(err, &ambiguous))
+	assert.Equal(t, map[string]string{"i-us": "us-east-1", "i-eu": "eu-west-1"}, ambiguous.Regions)
+	assert.Regexp(t, `i-eu\s+web\s+eu-west-1`, err.Error())
+	assert.Regexp(t, `i-us\s+web\s+us-east-1`, err.Error())
+}
+
+func TestGetInstanceInRegions_SingleClientAmbiguityRecordsRegion(t *testing.T) {
+	t.Parallel()
+
+	clients := []*Client{
+		regionalClient("us-east-1", MakeInstance("i-1", WithNameTag("web")), MakeInstance("i-2", WithNameTag("web"))),
+	}
+
+	_, err := GetInstanceInRegions(clients, "web", nil, nil)
+
+	var ambiguous *AmbiguousError
+	require.True(t, errors.As(err, &ambiguous))
+	assert.Equal(t, map[string]string{"i-1": "us-east-1", "i-2": "us-east-1"}, ambiguous.Regions)
+	assert.NotContains(t, err.Error(), "us-east-1 ", "single-region output has no REGION column")
+}
+
+func TestListInstancesInRegions(t *testing.T) {
+	t.Parallel()
+
+	clients := []*Client{
+		regionalClient("us-east-1", MakeInstance("i-us1"), MakeInstance("i-us2")),
+		regionalClient("eu-west-1"),
+		regionalClient("ap-south-1", MakeInstance("i-ap")),
+	}
+
+	instances, err := ListInstancesInRegions(clients, ListQuery{})
+
+	require.NoError(t, err)
+	require.Len(t, instances, 3)
+	assert.Equal(t, "us-east-1", instances[0].Region)
+	assert.Equal(t, "us-east-1", instances[1].Region)
+	assert.Equal(t, "i-ap", *instances[2].InstanceId)
+	assert.Equal(t, "ap-south-1", instances[2].Region)
+}
+
+func TestListInstancesInRegions_SkipsFailingProfile(t *testing.T) {
+	// No t.Parallel() - modifies WarningOutput
+
+	warnings := captureWarnings(t)
+	expired := failingClient("us-east-1", errors.New("ExpiredToken"))
+	expired.SetProfile("prod-a")
+	clients := []*Client{expired, regionalClient("us-east-1", MakeInstance("i-b"))}
+
+	instances, err := ListInstancesInRegions(clients, ListQuery{})
+
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "i-b", *instances[0].InstanceId)
+	assert.Contains(t, warnings.String(), "warning: skipping prod-a/us-east-1: ")
+}
+
+func TestGetInstanceInRegions_AcrossProfiles(t *testing.T) {
+	t.Parallel()
+
+	prodA := regionalClient("us-east-1")
+	prodA.SetProfile("prod-a")
+	prodB := regionalClient("us-east-1", MakeInstance("i-b", WithNameTag("db")))
+	prodB.SetProfile("prod-b")
+
+	match, err := GetInstanceInRegions([]*Client{prodA, prodB}, "db", nil, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "i-b", *match.InstanceId)
+	assert.Equal(t, "prod-b", match.Profile)
+	assert.Equal(t, "us-east-1", match.Region)
+}
+
+func TestGetInstanceInRegions_AmbiguityListsProfiles(t *testing.T) {
+	t.Parallel()
+
+	prodA := regionalClient("us-east-1", MakeInstance("i-a", WithNameTag("web")))
+	prodA.SetProfile("prod-a")
+	prodB := regionalClient("us-east-1", MakeInstance("i-b", WithNameTag("web")))
+	prodB.SetProfile("prod-b")
+
+	_, err := GetInstanceInRegions([]*Client{prodA, prodB}, "web", nil, nil)
+
+	var ambiguous *AmbiguousError
+	require.True(t, errors.As(err, &ambiguous))
+	assert.Equal(t, map[string]string{"i-a": "prod-a", "i-b": "prod-b"}, ambiguous.Profiles)
+	assert.Regexp(t, `i-a\s+web\s+prod-a\s`, err.Error())
+	assert.NotContains(t, err.Error(), "us-east-1 ", "single-region output has no REGION column")
+}
+
+func TestFindInstancesInRegions_NoMatchesNamesProfiles(t *testing.T) {
+	t.Parallel()
+
+	prodA := regionalClient("us-east-1")
+	prodA.SetProfile("prod-a")
+
+	_, err := FindInstancesInRegions([]*Client{prodA, regionalClient("eu-west-1")}, "web", nil)
+
+	require.ErrorIs(t, err, ErrNoMatches)
+	assert.Contains(t, err.Error(), "prod-a/us-east-1, eu-west-1")
+}
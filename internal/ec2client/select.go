@@ -0,0 +1,191 @@
+package ec2client
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// SelectorKind represents how a single instance is picked from several matches.
+type SelectorKind int
+
+const (
+	SelectNewest SelectorKind = iota
+	SelectOldest
+	SelectIndex
+)
+
+// Selector picks one instance when a destination matches several.
+// Use a pointer to Selector where nil means multiple matches are an error.
+type Selector struct {
+	Kind  SelectorKind
+	Index int // 1-based position in launch order, for SelectIndex
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts "newest", "oldest" or a 1-based index.
+func (s *Selector) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "newest":
+		*s = Selector{Kind: SelectNewest}
+		return nil
+	case "oldest":
+		*s = Selector{Kind: SelectOldest}
+		return nil
+	}
+
+	index, err := strconv.Atoi(string(text))
+	if err != nil || index < 1 {
+		return fmt.Errorf("unknown selector: %s", text)
+	}
+	*s = Selector{Kind: SelectIndex, Index: index}
+	return nil
+}
+
+// ErrAmbiguous is returned when several instances match and no selector is given.
+var ErrAmbiguous = errors.New("multiple matching instances found")
+
+// AmbiguousError lists the candidates of an ambiguous destination in launch order.
+type AmbiguousError struct {
+	Candidates []types.Instance
+	Regions    map[string]string // Instance ID → region; nil if not known
+	Profiles   map[string]string // Instance ID → profile; nil if not known
+}
+
+// spansSeveral reports whether the candidates have more than one distinct
+// value in locations (an Instance ID → region or profile map).
+func spansSeveral(locations map[string]string) bool {
+	var first *string
+	for _, location := range locations {
+		if first == nil {
+			first = &location
+		} else if location != *first {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *AmbiguousError) Error() string {
+	var sb strings.Builder
+
+	_, _ = fmt.Fprintf(&sb, "%v (%d), use --select newest|oldest|N or destination#N:\n", ErrAmbiguous, len(e.Candidates))
+
+	multiProfile := spansSeveral(e.Profiles)
+	multiRegion := spansSeveral(e.Regions)
+
+	writer := tabwriter.NewWriter(&sb, 0, 1, 2, ' ', 0)
+	for i, instance := range e.Candidates {
+		var az *string
+		if instance.Placement != nil {
+			az = instance.Placement.AvailabilityZone
+		}
+
+		var launchTime string
+		if instance.LaunchTime != nil {
+			launchTime = instance.LaunchTime.UTC().Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(writer, "  #%d\t%s\t%s\t", i+1,
+			valueOrDash(instance.InstanceId),
+			valueOrDash(GetInstanceName(instance)),
+		)
+		if multiProfile {
+			profile := e.Profiles[*instance.InstanceId]
+			_, _ = fmt.Fprintf(writer, "%s\t", valueOrDash(&profile))
+		}
+		if multiRegion {
+			region := e.Regions[*instance.InstanceId]
+			_, _ = fmt.Fprintf(writer, "%s\t", valueOrDash(&region))
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n",
+			valueOrDash(az),
+			valueOrDash(instance.PrivateIpAddress),
+			valueOrDash(&launchTime),
+		)
+	}
+	_ = writer.Flush()
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (e *AmbiguousError) Unwrap() error {
+	return ErrAmbiguous
+}
+
+func valueOrDash(value *string) string {
+	if value == nil || *value == "" {
+		return "-"
+	}
+	return *value
+}
+
+// destinationIndexRe matches a trailing "#N" index suffix, e.g. "web#2".
+var destinationIndexRe = regexp.MustCompile(`^(.+)#([1-9][0-9]*)$`)
+
+// SplitDestinationIndex strips a trailing "#N" suffix from the destination.
+// Returns the bare destination and an index selector, or nil if there is no suffix.
+func SplitDestinationIndex(destination string) (string, *Selector) {
+	match := destinationIndexRe.FindStringSubmatch(destination)
+	if match == nil {
+		return destination, nil
+	}
+	index, _ := strconv.Atoi(match[2])
+	return match[1], &Selector{Kind: SelectIndex, Index: index}
+}
+
+// sortByLaunchTime orders instances oldest first, breaking ties by instance ID.
+func sortByLaunchTime(instances []types.Instance) {
+	slices.SortStableFunc(instances, func(a, b types.Instance) int {
+		var ta, tb time.Time
+		if a.LaunchTime != nil {
+			ta = *a.LaunchTime
+		}
+		if b.LaunchTime != nil {
+			tb = *b.LaunchTime
+		}
+		if c := ta.Compare(tb); c != 0 {
+			return c
+		}
+		return strings.Compare(*a.InstanceId, *b.InstanceId)
+	})
+}
+
+// SelectInstance picks one instance from the matches.
+// A single match is always returned; several matches require a selector.
+func SelectInstance(instances []types.Instance, selector *Selector) (types.Instance, error) {
+	if len(instances) == 0 {
+		return types.Instance{}, ErrNoMatches
+	}
+
+	sorted := slices.Clone(instances)
+	sortByLaunchTime(sorted)
+
+	if selector == nil {
+		if len(sorted) > 1 {
+			return types.Instance{}, &AmbiguousError{Candidates: sorted}
+		}
+		return sorted[0], nil
+	}
+
+	switch selector.Kind {
+	case SelectNewest:
+		return sorted[len(sorted)-1], nil
+	case SelectOldest:
+		return sorted[0], nil
+	case SelectIndex:
+		if selector.Index < 1 || selector.Index > len(sorted) {
+			return types.Instance{}, fmt.Errorf("instance index #%d out of range (%d matches)", selector.Index, len(sorted))
+		}
+		return sorted[selector.Index-1], nil
+	default:
+		panic(fmt.Sprintf("unexpected SelectorKind: %d", selector.Kind))
+	}
+}
@@ -0,0 +1,232 @@
+package ec2client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelector_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    Selector
+		wantErr bool
+	}{
+		"newest": {
+			input: "newest",
+			want:  Selector{Kind: SelectNewest},
+		},
+		"oldest": {
+			input: "oldest",
+			want:  Selector{Kind: SelectOldest},
+		},
+		"index": {
+			input: "3",
+			want:  Selector{Kind: SelectIndex, Index: 3},
+		},
+		"zero index": {
+			input:   "0",
+			wantErr: true,
+		},
+		"negative index": {
+			input:   "-1",
+			wantErr: true,
+		},
+		"empty string": {
+			input:   "",
+			wantErr: true,
+		},
+		"unknown": {
+			input:   "latest",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got Selector
+			err := got.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown selector")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSplitDestinationIndex(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input     string
+		wantDst   string
+		wantIndex int // 0 = no selector
+	}{
+		"name with index":     {input: "web#2", wantDst: "web", wantIndex: 2},
+		"multi-digit index":   {input: "web#12", wantDst: "web", wantIndex: 12},
+		"plain name":          {input: "web", wantDst: "web"},
+		"zero is not index":   {input: "web#0", wantDst: "web#0"},
+		"non-numeric suffix":  {input: "web#a", wantDst: "web#a"},
+		"hash only":           {input: "#2", wantDst: "#2"},
+		"hash inside name":    {input: "web#2-db", wantDst: "web#2-db"},
+		"instance id indexed": {input: "i-123#1", wantDst: "i-123", wantIndex: 1},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dst, selector := SplitDestinationIndex(tc.input)
+
+			assert.Equal(t, tc.wantDst, dst)
+			if tc.wantIndex == 0 {
+				assert.Nil(t, selector)
+				return
+			}
+			require.NotNil(t, selector)
+			assert.Equal(t, Selector{Kind: SelectIndex, Index: tc.wantIndex}, *selector)
+		})
+	}
+}
+
+func TestSelectInstance(t *testing.T) {
+	t.Parallel()
+
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	t3 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// Deliberately out of launch order
+	instances := []types.Instance{
+		MakeInstance("i-middle", WithLaunchTime(t2)),
+		MakeInstance("i-newest", WithLaunchTime(t3)),
+		MakeInstance("i-oldest", WithLaunchTime(t1)),
+	}
+
+	tests := map[string]struct {
+		instances []types.Instance
+		selector  *Selector
+		wantID    string
+		wantErr   error
+	}{
+		"single match without selector": {
+			instances: instances[:1],
+			wantID:    "i-middle",
+		},
+		"single match ignores out-of-range index": {
+			instances: instances[:1],
+			selector:  &Selector{Kind: SelectIndex, Index: 1},
+			wantID:    "i-middle",
+		},
+		"newest": {
+			instances: instances,
+			selector:  &Selector{Kind: SelectNewest},
+			wantID:    "i-newest",
+		},
+		"oldest": {
+			instances: instances,
+			selector:  &Selector{Kind: SelectOldest},
+			wantID:    "i-oldest",
+		},
+		"index follows launch order": {
+			instances: instances,
+			selector:  &Selector{Kind: SelectIndex, Index: 2},
+			wantID:    "i-middle",
+		},
+		"ties broken by instance id": {
+			instances: []types.Instance{
+				MakeInstance("i-b", WithLaunchTime(t1)),
+				MakeInstance("i-a", WithLaunchTime(t1)),
+			},
+			selector: &Selector{Kind: SelectIndex, Index: 1},
+			wantID:   "i-a",
+		},
+		"no matches": {
+			instances: nil,
+			wantErr:   ErrNoMatches,
+		},
+		"multiple matches without selector": {
+			instances: instances,
+			wantErr:   ErrAmbiguous,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			instance, err := SelectInstance(tc.instances, tc.selector)
+
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantID, *instance.InstanceId)
+		})
+	}
+}
+
+func TestSelectInstance_IndexOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	instances := []types.Instance{MakeInstance("i-1"), MakeInstance("i-2")}
+
+	_, err := SelectInstance(instances, &Selector{Kind: SelectIndex, Index: 3})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "#3 out of range (2 matches)")
+}
+
+func TestAmbiguousError_ListsCandidates(t *testing.T) {
+	t.Parallel()
+
+	instances := []types.Instance{
+		MakeInstance("i-second", WithNameTag("web"), WithAZ("us-east-1b"), WithPrivateIP("10.0.0.2"),
+			WithLaunchTime(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))),
+		MakeInstance("i-first", WithNameTag("web"), WithAZ("us-east-1a"), WithPrivateIP("10.0.0.1"),
+			WithLaunchTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))),
+	}
+
+	_, err := SelectInstance(instances, nil)
+
+	var ambiguous *AmbiguousError
+	require.True(t, errors.As(err, &ambiguous))
+	require.Len(t, ambiguous.Candidates, 2)
+	assert.Equal(t, "i-first", *ambiguous.Candidates[0].InstanceId)
+
+	msg := err.Error()
+	assert.Contains(t, msg, "multiple matching instances found (2)")
+	assert.Regexp(t, `#1\s+i-first\s+web\s+us-east-1a\s+10\.0\.0\.1\s+2024-01-01T12:00:00Z`, msg)
+	assert.Regexp(t, `#2\s+i-second\s+web\s+us-east-1b\s+10\.0\.0\.2\s+2024-06-01T12:00:00Z`, msg)
+}
+
+func TestAmbiguousError_MultiRegionAddsRegionColumn(t *testing.T) {
+	t.Parallel()
+
+	err := &AmbiguousError{
+		Candidates: []types.Instance{
+			MakeInstance("i-us", WithNameTag("web"), WithAZ("us-east-1a")),
+			MakeInstance("i-eu", WithNameTag("web"), WithAZ("eu-west-1b")),
+		},
+		Regions: map[string]string{"i-us": "us-east-1", "i-eu": "eu-west-1"},
+	}
+
+	msg := err.Error()
+	assert.Regexp(t, `#1\s+i-us\s+web\s+us-east-1\s+us-east-1a`, msg)
+	assert.Regexp(t, `#2\s+i-eu\s+web\s+eu-west-1\s+eu-west-1b`, msg)
+}
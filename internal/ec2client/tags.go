@@ -0,0 +1,55 @@
+package ec2client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// ErrTagExpression is returned when a tag expression cannot be parsed.
+var ErrTagExpression = errors.New("invalid tag expression")
+
+// IsTagExpression reports whether the destination looks like a tag expression.
+// Hostnames, IPs and instance IDs never contain "=".
+func IsTagExpression(dst string) bool {
+	return strings.Contains(dst, "=")
+}
+
+// ParseTagExpression converts a tag expression into DescribeInstances filters.
+//
+// The expression is a comma-separated list of terms, all of which must match:
+//   - Key=Value matches an exact tag value; * and ? wildcards are allowed
+//   - Key matches any instance that has the tag, regardless of value
+//
+// Example: "Env=prod,Role=api*,Backup".
+func ParseTagExpression(expression string) ([]types.Filter, error) {
+	var filters []types.Filter
+
+	for _, term := range strings.Split(expression, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("%w: empty term in %q", ErrTagExpression, expression)
+		}
+
+		key, value, hasValue := strings.Cut(term, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("%w: missing tag key in %q", ErrTagExpression, term)
+		}
+
+		// Key-exists term: any value, including empty
+		if !hasValue {
+			value = "*"
+		}
+
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + key),
+			Values: []string{strings.TrimSpace(value)},
+		})
+	}
+
+	return filters, nil
+}
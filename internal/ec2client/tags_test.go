@@ -0,0 +1,120 @@
+package ec2client
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTagExpression(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		expression string
+		want       []types.Filter
+		wantErr    bool
+	}{
+		"single pair": {
+			expression: "Env=prod",
+			want: []types.Filter{
+				{Name: aws.String("tag:Env"), Values: []string{"prod"}},
+			},
+		},
+		"multiple pairs": {
+			expression: "Env=prod,Role=api",
+			want: []types.Filter{
+				{Name: aws.String("tag:Env"), Values: []string{"prod"}},
+				{Name: aws.String("tag:Role"), Values: []string{"api"}},
+			},
+		},
+		"wildcard value": {
+			expression: "Role=api-*",
+			want: []types.Filter{
+				{Name: aws.String("tag:Role"), Values: []string{"api-*"}},
+			},
+		},
+		"key exists": {
+			expression: "Backup",
+			want: []types.Filter{
+				{Name: aws.String("tag:Backup"), Values: []string{"*"}},
+			},
+		},
+		"mixed terms with spaces": {
+			expression: "Env = prod , Backup",
+			want: []types.Filter{
+				{Name: aws.String("tag:Env"), Values: []string{"prod"}},
+				{Name: aws.String("tag:Backup"), Values: []string{"*"}},
+			},
+		},
+		"empty value": {
+			expression: "Owner=",
+			want: []types.Filter{
+				{Name: aws.String("tag:Owner"), Values: []string{""}},
+			},
+		},
+		"value containing equals": {
+			expression: "Query=a=b",
+			want: []types.Filter{
+				{Name: aws.String("tag:Query"), Values: []string{"a=b"}},
+			},
+		},
+		"empty expression": {
+			expression: "",
+			wantErr:    true,
+		},
+		"empty term": {
+			expression: "Env=prod,,Role=api",
+			wantErr:    true,
+		},
+		"trailing comma": {
+			expression: "Env=prod,",
+			wantErr:    true,
+		},
+		"missing key": {
+			expression: "=prod",
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseTagExpression(tc.expression)
+
+			if tc.wantErr {
+				require.ErrorIs(t, err, ErrTagExpression)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestIsTagExpression(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dst  string
+		want bool
+	}{
+		"tag pair":     {dst: "Env=prod", want: true},
+		"tag list":     {dst: "Env=prod,Role=api", want: true},
+		"name tag":     {dst: "web-server", want: false},
+		"instance id":  {dst: "i-1234567890abcdef0", want: false},
+		"ip address":   {dst: "10.0.0.1", want: false},
+		"key only tag": {dst: "Backup", want: false}, // requires --destination-type tags
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, IsTagExpression(tc.dst))
+		})
+	}
+}
@@ -42,6 +42,33 @@ func (m *MockEC2API) DescribeInstanceConnectEndpoints(ctx context.Context, param
 	return args.Get(0).(*ec2.DescribeInstanceConnectEndpointsOutput), args.Error(1)
 }
 
+// DescribeRegions mocks the EC2 DescribeRegions API call.
+func (m *MockEC2API) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ec2.DescribeRegionsOutput), args.Error(1)
+}
+
+// DescribeImages mocks the EC2 DescribeImages API call.
+func (m *MockEC2API) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ec2.DescribeImagesOutput), args.Error(1)
+}
+
+// GetConsoleOutput mocks the EC2 GetConsoleOutput API call.
+func (m *MockEC2API) GetConsoleOutput(ctx context.Context, params *ec2.GetConsoleOutputInput, optFns ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ec2.GetConsoleOutputOutput), args.Error(1)
+}
+
 // MockEC2InstanceConnectAPI is a mock implementation of EC2InstanceConnectAPI.
 type MockEC2InstanceConnectAPI struct {
 	mock.Mock
@@ -78,12 +105,18 @@ func (m *MockHTTPRequestSigner) PresignHTTP(ctx context.Context, credentials aws
 // NewTestClient creates a Client with mock dependencies for testing.
 // This is intended for use by test code in other packages.
 func NewTestClient(ec2API EC2API, connectAPI EC2InstanceConnectAPI, signer HTTPRequestSigner) *Client {
+	return NewTestClientInRegion("us-east-1", ec2API, connectAPI, signer)
+}
+
+// NewTestClientInRegion creates a Client with mock dependencies bound to the given region.
+func NewTestClientInRegion(region string, ec2API EC2API, connectAPI EC2InstanceConnectAPI, signer HTTPRequestSigner) *Client {
 	return &Client{
 		ec2Client:     ec2API,
 		connectClient: connectAPI,
 		signer:        signer,
 		credentials:   aws.Credentials{},
-		region:        "us-east-1",
+		config:        aws.Config{Region: region},
+		region:        region,
 		logger:        log.New(io.Discard, "", 0),
 	}
 }
@@ -139,6 +172,20 @@ func WithSubnet(subnetID string) func(*types.Instance) {
 	}
 }
 
+// WithAZ sets the availability zone.
+func WithAZ(az string) func(*types.Instance) {
+	return func(i *types.Instance) {
+		i.Placement = &types.Placement{AvailabilityZone: aws.String(az)}
+	}
+}
+
+// WithLaunchTime sets the launch time.
+func WithLaunchTime(launchTime time.Time) func(*types.Instance) {
+	return func(i *types.Instance) {
+		i.LaunchTime = aws.Time(launchTime)
+	}
+}
+
 // WithNameTag adds a Name tag.
 func WithNameTag(name string) func(*types.Instance) {
 	return func(i *types.Instance) {
@@ -168,6 +215,15 @@ func MakeReservation(instances ...types.Instance) types.Reservation {
 	return types.Reservation{Instances: instances}
 }
 
+// MakeDescribeRegionsOutput creates DescribeRegionsOutput with the given region names.
+func MakeDescribeRegionsOutput(names ...string) *ec2.DescribeRegionsOutput {
+	output := &ec2.DescribeRegionsOutput{}
+	for _, name := range names {
+		output.Regions = append(output.Regions, types.Region{RegionName: aws.String(name)})
+	}
+	return output
+}
+
 // MakeDescribeOutput creates DescribeInstancesOutput with given reservations.
 func MakeDescribeOutput(reservations ...types.Reservation) *ec2.DescribeInstancesOutput {
 	return &ec2.DescribeInstancesOutput{Reservations: reservations}
@@ -25,14 +25,30 @@ const (
 	IntentSSMTunnel
 	// IntentList lists EC2 instances in the region.
 	IntentList
+	// IntentProxy proxies stdin/stdout to an instance, as an ssh ProxyCommand.
+	IntentProxy
+	// IntentExportSSHConfig writes ssh_config Host blocks for EC2 instances.
+	IntentExportSSHConfig
+	// IntentInventory writes an Ansible dynamic inventory of EC2 instances.
+	IntentInventory
+	// IntentKnownHosts maintains known_hosts entries of EC2 instances.
+	IntentKnownHosts
+	// IntentForward forwards local ports through an instance over SSM.
+	IntentForward
+	// IntentEICEListen forwards a local port to an instance port over EICE.
+	IntentEICEListen
+	// IntentSOCKS runs a SOCKS5 proxy through an instance over EICE or SSM.
+	IntentSOCKS
 )
 
 // Resolve determines the intent from the binary name and command-line arguments.
 // The intent is determined by:
-//  1. First argument override (--ssh, --list, --help, --eice-tunnel) - wins silently
+//  1. First argument override (--ssh, --list, --proxy, --help, --eice-tunnel) - wins silently
 //  2. Binary name (ec2list -> list, ec2ssh and others -> ssh)
 //
-// Returns the resolved intent and the remaining arguments (with override flag stripped if present).
+// Returns the resolved intent and the remaining arguments (with override flag
+// stripped if present). --forward, --eice-listen and --socks are kept, as they
+// also carry their spec.
 func Resolve(binPath string, args []string) (Intent, []string) {
 	// Step 1: Check first arg for override (wins silently over binary name)
 	if len(args) > 0 {
@@ -55,6 +71,20 @@ func Resolve(binPath string, args []string) (Intent, []string) {
 			return IntentSSMSession, args[1:]
 		case "--ssm-tunnel":
 			return IntentSSMTunnel, args[1:]
+		case "--proxy":
+			return IntentProxy, args[1:]
+		case "--export-ssh-config":
+			return IntentExportSSHConfig, args[1:]
+		case "--inventory":
+			return IntentInventory, args[1:]
+		case "--known-hosts":
+			return IntentKnownHosts, args[1:]
+		case "--forward":
+			return IntentForward, args
+		case "--eice-listen":
+			return IntentEICEListen, args
+		case "--socks":
+			return IntentSOCKS, args
 		}
 	}
 
@@ -96,6 +126,20 @@ func (i Intent) String() string {
 		return "ssm-tunnel"
 	case IntentList:
 		return "list"
+	case IntentProxy:
+		return "proxy"
+	case IntentExportSSHConfig:
+		return "export-ssh-config"
+	case IntentInventory:
+		return "inventory"
+	case IntentKnownHosts:
+		return "known-hosts"
+	case IntentForward:
+		return "forward"
+	case IntentEICEListen:
+		return "eice-listen"
+	case IntentSOCKS:
+		return "socks"
 	default:
 		return "unknown"
 	}
@@ -108,6 +108,48 @@ func TestResolve(t *testing.T) {
 			wantIntent: IntentSSMTunnel,
 			wantArgs:   []string{"--instance-id", "i-123"},
 		},
+		"--proxy flag": {
+			binPath:    "/usr/bin/ec2ssh",
+			args:       []string{"--proxy", "ec2-user@i-123:22"},
+			wantIntent: IntentProxy,
+			wantArgs:   []string{"ec2-user@i-123:22"},
+		},
+		"--export-ssh-config flag": {
+			binPath:    "/usr/bin/ec2ssh",
+			args:       []string{"--export-ssh-config", "--all-profiles"},
+			wantIntent: IntentExportSSHConfig,
+			wantArgs:   []string{"--all-profiles"},
+		},
+		"--inventory flag": {
+			binPath:    "/usr/bin/ec2ssh",
+			args:       []string{"--inventory", "--list"},
+			wantIntent: IntentInventory,
+			wantArgs:   []string{"--list"},
+		},
+		"--known-hosts flag": {
+			binPath:    "/usr/bin/ec2ssh",
+			args:       []string{"--known-hosts", "prune", "--dry-run"},
+			wantIntent: IntentKnownHosts,
+			wantArgs:   []string{"prune", "--dry-run"},
+		},
+		"--forward flag keeps the spec": {
+			binPath:    "/usr/bin/ec2ssh",
+			args:       []string{"--forward", "5432:db:5432", "bastion"},
+			wantIntent: IntentForward,
+			wantArgs:   []string{"--forward", "5432:db:5432", "bastion"},
+		},
+		"--eice-listen flag keeps the spec": {
+			binPath:    "/usr/bin/ec2ssh",
+			args:       []string{"--eice-listen", "3389:3389", "win-host"},
+			wantIntent: IntentEICEListen,
+			wantArgs:   []string{"--eice-listen", "3389:3389", "win-host"},
+		},
+		"--socks flag keeps the spec": {
+			binPath:    "/usr/bin/ec2ssh",
+			args:       []string{"--socks", "1080", "--use-ssm", "bastion"},
+			wantIntent: IntentSOCKS,
+			wantArgs:   []string{"--socks", "1080", "--use-ssm", "bastion"},
+		},
 
 		// Help flags
 		"--help flag": {
@@ -192,6 +234,13 @@ func TestIntent_String(t *testing.T) {
 		"ssm":         {intent: IntentSSMSession, want: "ssm"},
 		"ssm-tunnel":  {intent: IntentSSMTunnel, want: "ssm-tunnel"},
 		"list":        {intent: IntentList, want: "list"},
+		"proxy":       {intent: IntentProxy, want: "proxy"},
+		"export":      {intent: IntentExportSSHConfig, want: "export-ssh-config"},
+		"inventory":   {intent: IntentInventory, want: "inventory"},
+		"known-hosts": {intent: IntentKnownHosts, want: "known-hosts"},
+		"forward":     {intent: IntentForward, want: "forward"},
+		"eice-listen": {intent: IntentEICEListen, want: "eice-listen"},
+		"socks":       {intent: IntentSOCKS, want: "socks"},
 		"unknown":     {intent: Intent(99), want: "unknown"},
 	}
 
@@ -0,0 +1,300 @@
+// Package picker provides a minimal interactive fuzzy finder for terminals.
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ErrCancelled is returned when the user dismisses the picker without choosing.
+var ErrCancelled = errors.New("selection cancelled")
+
+// maxVisibleRows caps the number of rows drawn below the prompt.
+const maxVisibleRows = 15
+
+// Match reports whether every rune of query appears in s in order, ignoring case.
+// The score is lower for tighter matches: contiguous runs and early starts win.
+func Match(s, query string) (score int, ok bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	haystack := []rune(strings.ToLower(s))
+	needle := []rune(strings.ToLower(query))
+
+	first, last := -1, -1
+	pos := 0
+	for _, r := range needle {
+		for pos < len(haystack) && haystack[pos] != r {
+			pos++
+		}
+		if pos == len(haystack) {
+			return 0, false
+		}
+		if first == -1 {
+			first = pos
+		}
+		last = pos
+		pos++
+	}
+
+	// Span of the match dominates; the start position breaks ties
+	return (last-first+1-len(needle))*len(haystack) + first, true
+}
+
+// Filter returns the indexes of rows matching query, best match first.
+// Rows with equal scores keep their original order.
+func Filter(rows []string, query string) []int {
+	type scored struct {
+		index int
+		score int
+	}
+
+	var matches []scored
+	for i, row := range rows {
+		if score, ok := Match(row, query); ok {
+			matches = append(matches, scored{index: i, score: score})
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		return a.score - b.score
+	})
+
+	indexes := make([]int, len(matches))
+	for i, m := range matches {
+		indexes[i] = m.index
+	}
+	return indexes
+}
+
+// keyKind identifies a decoded keypress.
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyEnter
+	keyCancel
+	keyBackspace
+	keyClear
+	keyUp
+	keyDown
+)
+
+type key struct {
+	kind keyKind
+	r    rune // for keyRune
+}
+
+// parseKeys decodes raw terminal input into keypresses.
+// Unknown escape sequences and control characters are dropped.
+func parseKeys(buf []byte) []key {
+	var keys []key
+
+	for len(buf) > 0 {
+		switch {
+		case buf[0] == 0x1b && len(buf) >= 3 && (buf[1] == '[' || buf[1] == 'O'):
+			switch buf[2] {
+			case 'A':
+				keys = append(keys, key{kind: keyUp})
+			case 'B':
+				keys = append(keys, key{kind: keyDown})
+			}
+			buf = buf[3:]
+			continue
+		case buf[0] == 0x1b:
+			keys = append(keys, key{kind: keyCancel})
+		case buf[0] == 0x03 || buf[0] == 0x04: // Ctrl-C, Ctrl-D
+			keys = append(keys, key{kind: keyCancel})
+		case buf[0] == '\r' || buf[0] == '\n':
+			keys = append(keys, key{kind: keyEnter})
+		case buf[0] == 0x7f || buf[0] == 0x08:
+			keys = append(keys, key{kind: keyBackspace})
+		case buf[0] == 0x15: // Ctrl-U
+			keys = append(keys, key{kind: keyClear})
+		case buf[0] == 0x10: // Ctrl-P
+			keys = append(keys, key{kind: keyUp})
+		case buf[0] == 0x0e: // Ctrl-N
+			keys = append(keys, key{kind: keyDown})
+		case buf[0] >= 0x20:
+			r, size := utf8.DecodeRune(buf)
+			if unicode.IsPrint(r) {
+				keys = append(keys, key{kind: keyRune, r: r})
+			}
+			buf = buf[size:]
+			continue
+		}
+		buf = buf[1:]
+	}
+
+	return keys
+}
+
+// state is the picker model: the query, the filtered rows and the highlighted one.
+type state struct {
+	rows    []string
+	query   []rune
+	matches []int // indexes into rows, best first
+	cursor  int   // position in matches
+}
+
+func newState(rows []string) *state {
+	st := &state{rows: rows}
+	st.refilter()
+	return st
+}
+
+func (st *state) refilter() {
+	st.matches = Filter(st.rows, string(st.query))
+	st.cursor = 0
+}
+
+// handle applies a keypress. It returns done=true once the user has chosen
+// a row (selected is its index) or cancelled (err is ErrCancelled).
+func (st *state) handle(k key) (done bool, selected int, err error) {
+	switch k.kind {
+	case keyRune:
+		st.query = append(st.query, k.r)
+		st.refilter()
+	case keyBackspace:
+		if len(st.query) > 0 {
+			st.query = st.query[:len(st.query)-1]
+			st.refilter()
+		}
+	case keyClear:
+		st.query = nil
+		st.refilter()
+	case keyUp:
+		if st.cursor > 0 {
+			st.cursor--
+		}
+	case keyDown:
+		if st.cursor < len(st.matches)-1 {
+			st.cursor++
+		}
+	case keyEnter:
+		if len(st.matches) > 0 {
+			return true, st.matches[st.cursor], nil
+		}
+	case keyCancel:
+		return true, -1, ErrCancelled
+	}
+	return false, -1, nil
+}
+
+// render draws the prompt, header and visible rows, returning the number of lines written.
+// Lines are truncated to width so that the line count matches what the terminal shows.
+func (st *state) render(w io.Writer, header string, width int) int {
+	lines := []string{
+		fmt.Sprintf("> %s  (%d/%d)", string(st.query), len(st.matches), len(st.rows)),
+		"  " + header,
+	}
+
+	// Scroll so that the cursor stays visible
+	start := 0
+	if st.cursor >= maxVisibleRows {
+		start = st.cursor - maxVisibleRows + 1
+	}
+	end := min(start+maxVisibleRows, len(st.matches))
+
+	for i := start; i < end; i++ {
+		prefix := "  "
+		if i == st.cursor {
+			prefix = "> "
+		}
+		lines = append(lines, prefix+st.rows[st.matches[i]])
+	}
+
+	for i, line := range lines {
+		line = truncate(line, width)
+		if i == st.cursor-start+2 {
+			line = "\x1b[7m" + line + "\x1b[0m" // Reverse video for the highlighted row
+		}
+		if i > 0 {
+			_, _ = io.WriteString(w, "\r\n")
+		}
+		_, _ = io.WriteString(w, line)
+	}
+
+	return len(lines)
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) < width {
+		return s
+	}
+	return string(runes[:width-1])
+}
+
+// erase erases the previously rendered lines and leaves the cursor at their start.
+func erase(w io.Writer, lines int) {
+	if lines > 1 {
+		_, _ = fmt.Fprintf(w, "\x1b[%dA", lines-1)
+	}
+	_, _ = io.WriteString(w, "\r\x1b[J")
+}
+
+// run drives the picker from raw input until a row is chosen or the user cancels.
+func run(in io.Reader, out io.Writer, header string, rows []string, width int) (int, error) {
+	st := newState(rows)
+	buf := make([]byte, 256)
+
+	_, _ = io.WriteString(out, "\x1b[?25l") // Hide cursor
+	defer func() { _, _ = io.WriteString(out, "\x1b[?25h") }()
+
+	lines := st.render(out, header, width)
+	defer func() { erase(out, lines) }()
+
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			for _, k := range parseKeys(buf[:n]) {
+				if done, selected, err := st.handle(k); done {
+					return selected, err
+				}
+			}
+			erase(out, lines)
+			lines = st.render(out, header, width)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return -1, ErrCancelled
+			}
+			return -1, err
+		}
+	}
+}
+
+// Pick shows rows under header on the terminal and returns the index of the chosen row.
+// Input is read from in, which must be a terminal; the picker is drawn on out.
+// Returns ErrCancelled if the user presses Esc or Ctrl-C.
+func Pick(in, out *os.File, header string, rows []string) (int, error) {
+	if len(rows) == 0 {
+		return -1, errors.New("nothing to pick from")
+	}
+
+	oldState, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return -1, fmt.Errorf("unable to switch terminal to raw mode: %w", err)
+	}
+	defer func() { _ = term.Restore(int(in.Fd()), oldState) }()
+
+	width, _, err := term.GetSize(int(out.Fd()))
+	if err != nil {
+		width = 0 // Unknown width, don't truncate
+	}
+
+	return run(in, out, header, rows, width)
+}
@@ -0,0 +1,173 @@
+package picker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		s      string
+		query  string
+		wantOK bool
+	}{
+		"empty query":         {s: "anything", query: "", wantOK: true},
+		"substring":           {s: "web-server-01", query: "server", wantOK: true},
+		"subsequence":         {s: "web-server-01", query: "wsv01", wantOK: true},
+		"case insensitive":    {s: "Web-Server", query: "wEBs", wantOK: true},
+		"out of order":        {s: "web-server", query: "rw", wantOK: false},
+		"missing rune":        {s: "web-server", query: "webx", wantOK: false},
+		"query longer than s": {s: "web", query: "webserver", wantOK: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, ok := Match(tc.s, tc.query)
+			assert.Equal(t, tc.wantOK, ok)
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	rows := []string{
+		"i-1  worker-east  running",
+		"i-2  web          running",
+		"i-3  w-e-b        running",
+		"i-4  database     stopped",
+	}
+
+	tests := map[string]struct {
+		query string
+		want  []int
+	}{
+		"empty query keeps order": {query: "", want: []int{0, 1, 2, 3}},
+		"contiguous match first":  {query: "web", want: []int{1, 2}},
+		"no match":                {query: "xyz", want: []int{}},
+		"matches state column":    {query: "stopped", want: []int{3}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, Filter(rows, tc.query))
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input string
+		want  []key
+	}{
+		"printable":      {input: "ab", want: []key{{kind: keyRune, r: 'a'}, {kind: keyRune, r: 'b'}}},
+		"utf8":           {input: "é", want: []key{{kind: keyRune, r: 'é'}}},
+		"enter cr":       {input: "\r", want: []key{{kind: keyEnter}}},
+		"enter lf":       {input: "\n", want: []key{{kind: keyEnter}}},
+		"backspace":      {input: "\x7f", want: []key{{kind: keyBackspace}}},
+		"ctrl-u":         {input: "\x15", want: []key{{kind: keyClear}}},
+		"arrow up":       {input: "\x1b[A", want: []key{{kind: keyUp}}},
+		"arrow down":     {input: "\x1bOB", want: []key{{kind: keyDown}}},
+		"ctrl-p ctrl-n":  {input: "\x10\x0e", want: []key{{kind: keyUp}, {kind: keyDown}}},
+		"escape":         {input: "\x1b", want: []key{{kind: keyCancel}}},
+		"ctrl-c":         {input: "\x03", want: []key{{kind: keyCancel}}},
+		"unknown escape": {input: "\x1b[Cx", want: []key{{kind: keyRune, r: 'x'}}},
+		"other control":  {input: "\x01", want: nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, parseKeys([]byte(tc.input)))
+		})
+	}
+}
+
+func TestState_Handle(t *testing.T) {
+	t.Parallel()
+
+	rows := []string{"alpha", "beta", "gamma"}
+
+	tests := map[string]struct {
+		keys         string
+		wantSelected int
+		wantErr      error
+	}{
+		"enter selects first":    {keys: "\r", wantSelected: 0},
+		"down moves cursor":      {keys: "\x1b[B\x1b[B\r", wantSelected: 2},
+		"down stops at last":     {keys: "\x1b[B\x1b[B\x1b[B\r", wantSelected: 2},
+		"up stops at first":      {keys: "\x1b[A\r", wantSelected: 0},
+		"query filters":          {keys: "gam\r", wantSelected: 2},
+		"query resets cursor":    {keys: "\x1b[Bg\r", wantSelected: 2},
+		"backspace widens":       {keys: "gx\x7f\r", wantSelected: 2},
+		"clear resets query":     {keys: "gam\x15\r", wantSelected: 0},
+		"enter ignored no match": {keys: "zzz\r\x15\r", wantSelected: 0},
+		"escape cancels":         {keys: "a\x1b", wantSelected: -1, wantErr: ErrCancelled},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			st := newState(rows)
+			for _, k := range parseKeys([]byte(tc.keys)) {
+				if done, selected, err := st.handle(k); done {
+					assert.Equal(t, tc.wantSelected, selected)
+					assert.ErrorIs(t, err, tc.wantErr)
+					return
+				}
+			}
+			t.Fatal("picker did not finish")
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	rows := []string{"i-1  web", "i-2  db"}
+
+	var out bytes.Buffer
+	selected, err := run(iotest.OneByteReader(strings.NewReader("db\r")), &out, "ID   NAME", rows, 80)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, selected)
+	assert.Contains(t, out.String(), "ID   NAME")
+	assert.Contains(t, out.String(), "(1/2)")
+}
+
+func TestRun_EOFCancels(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	_, err := run(strings.NewReader("we"), &out, "ID", []string{"web"}, 80)
+
+	require.ErrorIs(t, err, ErrCancelled)
+}
+
+func TestRender_TruncatesToWidth(t *testing.T) {
+	t.Parallel()
+
+	st := newState([]string{strings.Repeat("x", 100)})
+
+	var out bytes.Buffer
+	lines := st.render(&out, "HEADER", 20)
+
+	assert.Equal(t, 3, lines)
+	for _, line := range strings.Split(out.String(), "\r\n") {
+		line = strings.TrimPrefix(line, "\x1b[7m")
+		line = strings.TrimSuffix(line, "\x1b[0m")
+		assert.Less(t, len(line), 20)
+	}
+}
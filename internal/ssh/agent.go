@@ -0,0 +1,140 @@
+package ssh
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// agentKeyCommentPrefix marks ephemeral keys added to ssh-agent by ec2ssh.
+// The comment ends with the key's expiry as a Unix time, which the agent
+// itself does not report.
+const agentKeyCommentPrefix = "ec2ssh-ephemeral expires="
+
+// agentKeyReuseMargin is the least lifetime left for an agent key to be reused,
+// enough to push it and finish the SSH handshake.
+const agentKeyReuseMargin = time.Minute
+
+// agentKeyTypes are the key types EC2 Instance Connect accepts.
+var agentKeyTypes = []string{gossh.KeyAlgoED25519, gossh.KeyAlgoRSA}
+
+// withAgent runs fn with a client for the agent at SSH_AUTH_SOCK.
+func withAgent(fn func(agent.ExtendedAgent) error) error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return errors.New("SSH_AUTH_SOCK is not set, is ssh-agent running?")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("unable to connect to ssh-agent: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	return fn(agent.NewClient(conn))
+}
+
+// AddAgentKey adds an ephemeral key of the given type to ssh-agent that the
+// agent drops after lifetime, so no private key is written to disk. A key of
+// the same algorithm added by an earlier run is reused while it has time left.
+// Returns the public key contents.
+func AddAgentKey(keyType KeyType, lifetime time.Duration) (publicKey string, err error) {
+	err = withAgent(func(client agent.ExtendedAgent) error {
+		keys, err := client.List()
+		if err != nil {
+			return fmt.Errorf("unable to list ssh-agent keys: %w", err)
+		}
+
+		for _, key := range keys {
+			if agentKeyMatches(key, keyType) && agentKeyUsable(key.Comment, time.Now()) {
+				publicKey = string(gossh.MarshalAuthorizedKey(key))
+				return nil
+			}
+		}
+
+		privateKey, sshPublicKey, err := keyType.generate()
+		if err != nil {
+			return fmt.Errorf("failed to generate keypair: %w", err)
+		}
+
+		expires := time.Now().Add(lifetime)
+		err = client.Add(agent.AddedKey{
+			PrivateKey:   privateKey,
+			Comment:      agentKeyCommentPrefix + strconv.FormatInt(expires.Unix(), 10),
+			LifetimeSecs: uint32(lifetime / time.Second),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to add key to ssh-agent: %w", err)
+		}
+
+		publicKey = string(gossh.MarshalAuthorizedKey(sshPublicKey))
+		return nil
+	})
+	return publicKey, err
+}
+
+// agentKeyMatches reports whether the agent key is of keyType, including the
+// modulus size for RSA, where the algorithm name alone does not tell.
+func agentKeyMatches(key *agent.Key, keyType KeyType) bool {
+	if key.Type() != keyType.sshAlgorithm() {
+		return false
+	}
+	if keyType.Algorithm != KeyRSA {
+		return true
+	}
+
+	publicKey, err := gossh.ParsePublicKey(key.Blob)
+	if err != nil {
+		return false
+	}
+	cryptoKey, ok := publicKey.(gossh.CryptoPublicKey)
+	if !ok {
+		return false
+	}
+	rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey)
+	return ok && rsaKey.Size()*8 == keyType.bits()
+}
+
+// agentKeyUsable reports whether comment marks an ec2ssh key that is still
+// valid for agentKeyReuseMargin after now.
+func agentKeyUsable(comment string, now time.Time) bool {
+	value, ok := strings.CutPrefix(comment, agentKeyCommentPrefix)
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Unix(expires, 0).After(now.Add(agentKeyReuseMargin))
+}
+
+// GetAgentPublicKey returns the public key of the first ssh-agent identity
+// that EC2 Instance Connect accepts (ed25519 or RSA, not certificates).
+func GetAgentPublicKey() (publicKey string, err error) {
+	err = withAgent(func(client agent.ExtendedAgent) error {
+		keys, err := client.List()
+		if err != nil {
+			return fmt.Errorf("unable to list ssh-agent keys: %w", err)
+		}
+
+		for _, key := range keys {
+			if slices.Contains(agentKeyTypes, key.Type()) {
+				publicKey = string(gossh.MarshalAuthorizedKey(key))
+				return nil
+			}
+		}
+
+		return errors.New("ssh-agent has no ed25519 or RSA identity")
+	})
+	return publicKey, err
+}
@@ -0,0 +1,190 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startAgent serves an in-memory keyring on a socket and points SSH_AUTH_SOCK at it.
+func startAgent(t *testing.T) agent.Agent {
+	t.Helper()
+
+	keyring := agent.NewKeyring()
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	return keyring
+}
+
+func TestAddAgentKey(t *testing.T) {
+	// No t.Parallel() - sets SSH_AUTH_SOCK
+	keyring := startAgent(t)
+
+	publicKey, err := AddAgentKey(KeyType{}, 10*time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(publicKey, "ssh-ed25519 "))
+
+	keys, err := keyring.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, publicKey, string(gossh.MarshalAuthorizedKey(keys[0])))
+	assert.True(t, strings.HasPrefix(keys[0].Comment, agentKeyCommentPrefix))
+
+	// A second run inside the lifetime reuses the key
+	again, err := AddAgentKey(KeyType{}, 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, again)
+
+	keys, err = keyring.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestAddAgentKey_ExpiringKeyNotReused(t *testing.T) {
+	// No t.Parallel() - sets SSH_AUTH_SOCK
+	keyring := startAgent(t)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	expires := time.Now().Add(10 * time.Second).Unix()
+	require.NoError(t, keyring.Add(agent.AddedKey{
+		PrivateKey: privateKey,
+		Comment:    agentKeyCommentPrefix + strconv.FormatInt(expires, 10),
+	}))
+
+	publicKey, err := AddAgentKey(KeyType{}, 10*time.Minute)
+	require.NoError(t, err)
+
+	keys, err := keyring.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, publicKey, string(gossh.MarshalAuthorizedKey(keys[1])))
+}
+
+func TestAddAgentKey_OtherTypeNotReused(t *testing.T) {
+	// No t.Parallel() - sets SSH_AUTH_SOCK
+	keyring := startAgent(t)
+
+	edKey, err := AddAgentKey(KeyType{}, 10*time.Minute)
+	require.NoError(t, err)
+
+	rsaKey, err := AddAgentKey(KeyType{Algorithm: KeyRSA}, 10*time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rsaKey, "ssh-rsa "))
+	assert.NotEqual(t, edKey, rsaKey)
+
+	keys, err := keyring.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
+
+func TestAddAgentKey_OtherRSASizeNotReused(t *testing.T) {
+	// No t.Parallel() - sets SSH_AUTH_SOCK
+	keyring := startAgent(t)
+
+	smallKey, err := AddAgentKey(KeyType{Algorithm: KeyRSA}, 10*time.Minute)
+	require.NoError(t, err)
+
+	largeKey, err := AddAgentKey(KeyType{Algorithm: KeyRSA, Bits: 4096}, 10*time.Minute)
+	require.NoError(t, err)
+	assert.NotEqual(t, smallKey, largeKey)
+
+	// Each size is reused for its own type
+	again, err := AddAgentKey(KeyType{Algorithm: KeyRSA, Bits: 4096}, 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, largeKey, again)
+	again, err = AddAgentKey(KeyType{Algorithm: KeyRSA, Bits: 2048}, 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, smallKey, again)
+
+	keys, err := keyring.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
+
+func TestAddAgentKey_NoAgent(t *testing.T) {
+	// No t.Parallel() - sets SSH_AUTH_SOCK
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	_, err := AddAgentKey(KeyType{}, time.Minute)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SSH_AUTH_SOCK is not set")
+}
+
+func TestAgentKeyUsable(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := map[string]struct {
+		comment string
+		want    bool
+	}{
+		"fresh":         {comment: agentKeyCommentPrefix + "1700000600", want: true},
+		"within margin": {comment: agentKeyCommentPrefix + "1700000030", want: false},
+		"expired":       {comment: agentKeyCommentPrefix + "1699999000", want: false},
+		"other key":     {comment: "user@laptop", want: false},
+		"bad expiry":    {comment: agentKeyCommentPrefix + "soon", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, agentKeyUsable(tc.comment, now))
+		})
+	}
+}
+
+func TestGetAgentPublicKey(t *testing.T) {
+	// No t.Parallel() - sets SSH_AUTH_SOCK
+	keyring := startAgent(t)
+
+	_, err := GetAgentPublicKey()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no ed25519 or RSA identity")
+
+	// ECDSA is skipped: Instance Connect does not accept it
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: ecdsaKey}))
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: edPrivate, Comment: "user@laptop"}))
+
+	publicKey, err := GetAgentPublicKey()
+	require.NoError(t, err)
+
+	want, err := gossh.NewPublicKey(edPublic)
+	require.NoError(t, err)
+	assert.Equal(t, string(gossh.MarshalAuthorizedKey(want)), publicKey)
+}
@@ -0,0 +1,152 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// CAKeyAgent selects a CA key held in ssh-agent instead of a key file, as
+// "agent" for the first identity or "agent:<fingerprint or comment>".
+const CAKeyAgent = "agent"
+
+// certClockSkew backdates certificates so that a target whose clock is
+// slightly behind still accepts them.
+const certClockSkew = time.Minute
+
+// certExtensions are the permissions of a plain ssh-keygen user certificate.
+var certExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// CertRequest describes a user certificate to issue for an ephemeral key.
+type CertRequest struct {
+	PublicKey string        // Key to certify, in authorized_keys format
+	Principal string        // Login the certificate is valid for
+	KeyID     string        // Logged by sshd, identifies the certificate
+	Validity  time.Duration // Lifetime from now
+}
+
+// SignUserCert signs a user certificate with the CA key at caKey, or in
+// ssh-agent when caKey is CAKeyAgent or starts with "agent:".
+// Returns the certificate in authorized_keys format, as for CertificateFile.
+func SignUserCert(caKey string, request CertRequest) (string, error) {
+	publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(request.PublicKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	cert := &gossh.Certificate{
+		Key:             publicKey,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        gossh.UserCert,
+		KeyId:           request.KeyID,
+		ValidPrincipals: []string{request.Principal},
+		ValidAfter:      uint64(now.Add(-certClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(request.Validity).Unix()),
+		Permissions:     gossh.Permissions{Extensions: certExtensions},
+	}
+
+	sign := func(signer gossh.Signer) error {
+		if err := cert.SignCert(rand.Reader, signer); err != nil {
+			return fmt.Errorf("unable to sign certificate: %w", err)
+		}
+		return nil
+	}
+
+	if selector, ok := agentCASelector(caKey); ok {
+		err = withAgent(func(client agent.ExtendedAgent) error {
+			signer, err := selectAgentSigner(client, selector)
+			if err != nil {
+				return err
+			}
+			return sign(signer)
+		})
+	} else {
+		var signer gossh.Signer
+		signer, err = loadSigner(caKey)
+		if err == nil {
+			err = sign(signer)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(gossh.MarshalAuthorizedKey(cert)), nil
+}
+
+// agentCASelector reports whether caKey names an agent key, and which:
+// empty for the first one.
+func agentCASelector(caKey string) (string, bool) {
+	if caKey == CAKeyAgent {
+		return "", true
+	}
+	return strings.CutPrefix(caKey, CAKeyAgent+":")
+}
+
+// selectAgentSigner returns the agent key whose SHA256 fingerprint or comment
+// is selector, or the first key when selector is empty.
+func selectAgentSigner(client agent.ExtendedAgent, selector string) (gossh.Signer, error) {
+	keys, err := client.List()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list ssh-agent keys: %w", err)
+	}
+	signers, err := client.Signers()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list ssh-agent keys: %w", err)
+	}
+
+	// Signers come in the same order as List
+	for i, key := range keys {
+		if i < len(signers) && (selector == "" || selector == key.Comment || selector == gossh.FingerprintSHA256(key)) {
+			return signers[i], nil
+		}
+	}
+
+	if selector == "" {
+		return nil, errors.New("ssh-agent has no keys")
+	}
+	return nil, fmt.Errorf("ssh-agent has no key %s", selector)
+}
+
+// loadSigner reads a private key file, prompting for its passphrase if it is encrypted.
+func loadSigner(privateKeyPath string) (gossh.Signer, error) {
+	data, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key: %w", err)
+	}
+
+	signer, err := gossh.ParsePrivateKey(data)
+
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, perr := readPassphrase(privateKeyPath)
+		if perr != nil {
+			return nil, perr
+		}
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(data, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse key %s: %w", privateKeyPath, err)
+	}
+
+	return signer, nil
+}
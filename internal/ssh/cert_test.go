@@ -0,0 +1,144 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// parseCert parses a certificate in authorized_keys format.
+func parseCert(t *testing.T, certificate string) *gossh.Certificate {
+	t.Helper()
+
+	parsed, _, _, _, err := gossh.ParseAuthorizedKey([]byte(certificate))
+	require.NoError(t, err)
+	cert, ok := parsed.(*gossh.Certificate)
+	require.True(t, ok, "expected a certificate, got %s", parsed.Type())
+	return cert
+}
+
+func TestSignUserCert_KeyFile(t *testing.T) {
+	t.Parallel()
+
+	_, caPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := gossh.MarshalPrivateKey(caPrivate, "ca")
+	require.NoError(t, err)
+	caPath := filepath.Join(t.TempDir(), "ca")
+	require.NoError(t, os.WriteFile(caPath, pem.EncodeToMemory(block), 0o600))
+	caSigner, err := gossh.NewSignerFromKey(caPrivate)
+	require.NoError(t, err)
+
+	_, publicKey, err := GenerateKeypair(t.TempDir(), KeyType{})
+	require.NoError(t, err)
+
+	before := time.Now()
+	certificate, err := SignUserCert(caPath, CertRequest{
+		PublicKey: publicKey,
+		Principal: "ec2-user",
+		KeyID:     "ec2ssh ec2-user@i-123",
+		Validity:  5 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	cert := parseCert(t, certificate)
+	assert.Equal(t, uint32(gossh.UserCert), cert.CertType)
+	assert.Equal(t, []string{"ec2-user"}, cert.ValidPrincipals)
+	assert.Equal(t, "ec2ssh ec2-user@i-123", cert.KeyId)
+	assert.Equal(t, string(caSigner.PublicKey().Marshal()), string(cert.SignatureKey.Marshal()))
+	assert.Equal(t, publicKey, string(gossh.MarshalAuthorizedKey(cert.Key)))
+	assert.Contains(t, cert.Extensions, "permit-pty")
+
+	// Valid now, for about the requested lifetime, backdated for clock skew
+	assert.LessOrEqual(t, cert.ValidAfter, uint64(before.Add(-certClockSkew).Unix()))
+	assert.InDelta(t, before.Add(5*time.Minute).Unix(), int64(cert.ValidBefore), 5)
+
+	checker := gossh.CertChecker{}
+	require.NoError(t, checker.CheckCert("ec2-user", cert))
+	require.Error(t, checker.CheckCert("root", cert))
+}
+
+func TestSignUserCert_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, publicKey, err := GenerateKeypair(t.TempDir(), KeyType{})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		caKey       string
+		publicKey   string
+		errContains string
+	}{
+		"missing CA file": {caKey: "/nonexistent/ca", publicKey: publicKey, errContains: "unable to read key"},
+		"bad public key":  {caKey: "/nonexistent/ca", publicKey: "not a key", errContains: "invalid public key"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := SignUserCert(tc.caKey, CertRequest{PublicKey: tc.publicKey, Principal: "ec2-user", Validity: time.Minute})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.errContains)
+		})
+	}
+}
+
+func TestSignUserCert_Agent(t *testing.T) {
+	// No t.Parallel() - sets SSH_AUTH_SOCK
+	keyring := startAgent(t)
+
+	_, publicKey, err := GenerateKeypair(t.TempDir(), KeyType{})
+	require.NoError(t, err)
+	request := CertRequest{PublicKey: publicKey, Principal: "ubuntu", Validity: time.Minute}
+
+	_, err = SignUserCert("agent", request)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ssh-agent has no keys")
+
+	var caKeys []gossh.PublicKey
+	for _, comment := range []string{"user@laptop", "company-ca"} {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: private, Comment: comment}))
+		signer, err := gossh.NewSignerFromKey(private)
+		require.NoError(t, err)
+		caKeys = append(caKeys, signer.PublicKey())
+	}
+
+	tests := map[string]struct {
+		caKey       string
+		wantCA      gossh.PublicKey
+		errContains string
+	}{
+		"first key":      {caKey: "agent", wantCA: caKeys[0]},
+		"by comment":     {caKey: "agent:company-ca", wantCA: caKeys[1]},
+		"by fingerprint": {caKey: "agent:" + gossh.FingerprintSHA256(caKeys[1]), wantCA: caKeys[1]},
+		"unknown":        {caKey: "agent:other-ca", errContains: "ssh-agent has no key other-ca"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			certificate, err := SignUserCert(tc.caKey, request)
+
+			if tc.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			cert := parseCert(t, certificate)
+			assert.Equal(t, string(tc.wantCA.Marshal()), string(cert.SignatureKey.Marshal()))
+		})
+	}
+}
@@ -1,44 +1,100 @@
 package ssh
 
 import (
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
 )
 
-const sshKeyType = "ed25519"
+// readPassphrase asks for the passphrase of an encrypted key, overridden in tests.
+var readPassphrase = promptPassphrase
 
-// GenerateKeypair generates an ed25519 SSH keypair in the given directory.
+// GenerateKeypair generates an SSH keypair of the given type in the given directory.
+// The private key is written in OpenSSH format with 0600 permissions.
 // Returns the private key path and the public key contents.
-func GenerateKeypair(tmpDir string) (privateKeyPath, publicKey string, err error) {
-
-	privateKeyPath = path.Join(tmpDir, "id_"+sshKeyType)
-	publicKeyPath := privateKeyPath + ".pub"
-	cmd := exec.Command("ssh-keygen", "-q", "-t", sshKeyType, "-f", privateKeyPath, "-N", "")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return "", "", fmt.Errorf("failed to generate keypair using ssh-keygen: %w", err)
+func GenerateKeypair(tmpDir string, keyType KeyType) (privateKeyPath, publicKey string, err error) {
+	privateKeyRaw, sshPublicKey, err := keyType.generate()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate keypair: %w", err)
 	}
 
-	publicKeyBytes, err := os.ReadFile(publicKeyPath)
+	block, err := gossh.MarshalPrivateKey(privateKeyRaw, "")
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read generated public key file: %w", err)
+		return "", "", fmt.Errorf("failed to generate keypair: %w", err)
+	}
+	publicKey = string(gossh.MarshalAuthorizedKey(sshPublicKey))
+
+	privateKeyPath = path.Join(tmpDir, "id_"+keyType.Algorithm.String())
+	if err := writeNewFile(privateKeyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		return "", "", fmt.Errorf("failed to generate keypair: %w", err)
+	}
+	if err := writeNewFile(privateKeyPath+".pub", []byte(publicKey), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to generate keypair: %w", err)
 	}
 
-	return privateKeyPath, string(publicKeyBytes), nil
+	return privateKeyPath, publicKey, nil
 }
 
 // GetPublicKey extracts the public key from an existing private key file.
+// OpenSSH keys carry their public key in the clear; other encrypted keys
+// prompt for the passphrase on the terminal.
 func GetPublicKey(privateKeyPath string) (string, error) {
-	cmd := exec.Command("ssh-keygen", "-y", "-f", privateKeyPath)
+	data, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get public key: %w", err)
+	}
 
-	outputBytes, err := cmd.Output()
+	signer, err := gossh.ParsePrivateKey(data)
+
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if missing.PublicKey != nil {
+			return string(gossh.MarshalAuthorizedKey(missing.PublicKey)), nil
+		}
+
+		passphrase, perr := readPassphrase(privateKeyPath)
+		if perr != nil {
+			return "", fmt.Errorf("failed to get public key: %w", perr)
+		}
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(data, passphrase)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get public key using ssh-keygen: %w", err)
+		return "", fmt.Errorf("failed to get public key from %s: %w", privateKeyPath, err)
 	}
 
-	return string(outputBytes), nil
+	return string(gossh.MarshalAuthorizedKey(signer.PublicKey())), nil
+}
+
+// promptPassphrase reads a passphrase from the controlling terminal, like ssh does,
+// so that it works while stdin and stdout are redirected.
+func promptPassphrase(privateKeyPath string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("key %s is encrypted and there is no terminal to ask for its passphrase", privateKeyPath)
+	}
+	defer func() { _ = tty.Close() }()
+
+	_, _ = fmt.Fprintf(tty, "Enter passphrase for key '%s': ", privateKeyPath)
+	passphrase, err := term.ReadPassword(int(tty.Fd()))
+	_, _ = fmt.Fprintln(tty)
+
+	return passphrase, err
+}
+
+// writeNewFile writes data to a file that must not exist yet.
+func writeNewFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -1,6 +1,12 @@
 package ssh
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -8,16 +14,15 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
 )
 
-// These tests require real ssh-keygen to be installed
-
 func TestGenerateKeypair(t *testing.T) {
 	t.Parallel()
 
 	tmpDir := t.TempDir()
 
-	privateKeyPath, publicKey, err := GenerateKeypair(tmpDir)
+	privateKeyPath, publicKey, err := GenerateKeypair(tmpDir, KeyType{})
 	require.NoError(t, err)
 
 	// Check private key file was created
@@ -47,7 +52,7 @@ func TestGenerateKeypair_InvalidDirectory(t *testing.T) {
 	t.Parallel()
 
 	// Try to generate keypair in non-existent directory
-	_, _, err := GenerateKeypair("/nonexistent/path/that/does/not/exist")
+	_, _, err := GenerateKeypair("/nonexistent/path/that/does/not/exist", KeyType{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to generate keypair")
 }
@@ -61,10 +66,10 @@ func TestGenerateKeypair_MultipleGenerations(t *testing.T) {
 	tmpDir1 := t.TempDir()
 	tmpDir2 := t.TempDir()
 
-	path1, pub1, err := GenerateKeypair(tmpDir1)
+	path1, pub1, err := GenerateKeypair(tmpDir1, KeyType{})
 	require.NoError(t, err)
 
-	path2, pub2, err := GenerateKeypair(tmpDir2)
+	path2, pub2, err := GenerateKeypair(tmpDir2, KeyType{})
 	require.NoError(t, err)
 
 	// Different directories should produce different paths
@@ -74,12 +79,56 @@ func TestGenerateKeypair_MultipleGenerations(t *testing.T) {
 	assert.NotEqual(t, pub1, pub2)
 }
 
+func TestGenerateKeypair_KeyTypes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		keyType    KeyType
+		wantPath   string
+		wantPrefix string
+	}{
+		"ed25519":   {keyType: KeyType{Algorithm: KeyEd25519}, wantPath: "id_ed25519", wantPrefix: "ssh-ed25519 "},
+		"rsa":       {keyType: KeyType{Algorithm: KeyRSA}, wantPath: "id_rsa", wantPrefix: "ssh-rsa "},
+		"ecdsa 384": {keyType: KeyType{Algorithm: KeyECDSA, Bits: 384}, wantPath: "id_ecdsa", wantPrefix: "ecdsa-sha2-nistp384 "},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			privateKeyPath, publicKey, err := GenerateKeypair(tmpDir, tc.keyType)
+			require.NoError(t, err)
+
+			assert.Equal(t, filepath.Join(tmpDir, tc.wantPath), privateKeyPath)
+			assert.True(t, strings.HasPrefix(publicKey, tc.wantPrefix), "public key %q", publicKey)
+
+			// The private key file yields the same public key
+			derived, err := GetPublicKey(privateKeyPath)
+			require.NoError(t, err)
+			assert.Equal(t, publicKey, derived)
+		})
+	}
+}
+
+func TestGenerateKeypair_RSABits(t *testing.T) {
+	t.Parallel()
+
+	_, publicKey, err := GenerateKeypair(t.TempDir(), KeyType{Algorithm: KeyRSA, Bits: 4096})
+	require.NoError(t, err)
+
+	parsed, _, _, _, err := gossh.ParseAuthorizedKey([]byte(publicKey))
+	require.NoError(t, err)
+	rsaKey := parsed.(gossh.CryptoPublicKey).CryptoPublicKey().(*rsa.PublicKey)
+	assert.Equal(t, 4096, rsaKey.N.BitLen())
+}
+
 func TestGetPublicKey(t *testing.T) {
 	t.Parallel()
 
 	// First generate a keypair
 	tmpDir := t.TempDir()
-	privateKeyPath, expectedPublicKey, err := GenerateKeypair(tmpDir)
+	privateKeyPath, expectedPublicKey, err := GenerateKeypair(tmpDir, KeyType{})
 	require.NoError(t, err)
 
 	// Now extract the public key using GetPublicKey
@@ -116,7 +165,7 @@ func TestGenerateKeypair_KeyType(t *testing.T) {
 	t.Parallel()
 
 	tmpDir := t.TempDir()
-	privateKeyPath, _, err := GenerateKeypair(tmpDir)
+	privateKeyPath, _, err := GenerateKeypair(tmpDir, KeyType{})
 	require.NoError(t, err)
 
 	// Read the private key file to verify it's ed25519
@@ -126,3 +175,118 @@ func TestGenerateKeypair_KeyType(t *testing.T) {
 	// Ed25519 keys start with this header
 	assert.Contains(t, string(content), "OPENSSH PRIVATE KEY")
 }
+
+// writeKey writes pemBlock to a file and returns its path and the expected public key.
+func writeKey(t *testing.T, block *pem.Block, publicKey any) (string, string) {
+	t.Helper()
+
+	keyPath := filepath.Join(t.TempDir(), "id")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600))
+
+	sshPublicKey, err := gossh.NewPublicKey(publicKey)
+	require.NoError(t, err)
+
+	return keyPath, string(gossh.MarshalAuthorizedKey(sshPublicKey))
+}
+
+func TestGetPublicKey_KeyFormats(t *testing.T) {
+	t.Parallel()
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		marshal   func() (*pem.Block, error)
+		publicKey any
+		wantType  string
+	}{
+		"ed25519 openssh": {
+			marshal:   func() (*pem.Block, error) { return gossh.MarshalPrivateKey(edPrivate, "") },
+			publicKey: edPublic,
+			wantType:  "ssh-ed25519 ",
+		},
+		"rsa openssh": {
+			marshal:   func() (*pem.Block, error) { return gossh.MarshalPrivateKey(rsaPrivate, "") },
+			publicKey: &rsaPrivate.PublicKey,
+			wantType:  "ssh-rsa ",
+		},
+		"rsa pem": {
+			marshal: func() (*pem.Block, error) {
+				return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate)}, nil
+			},
+			publicKey: &rsaPrivate.PublicKey,
+			wantType:  "ssh-rsa ",
+		},
+		"encrypted openssh needs no passphrase": {
+			marshal: func() (*pem.Block, error) {
+				return gossh.MarshalPrivateKeyWithPassphrase(edPrivate, "", []byte("secret"))
+			},
+			publicKey: edPublic,
+			wantType:  "ssh-ed25519 ",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			block, err := tc.marshal()
+			require.NoError(t, err)
+			keyPath, want := writeKey(t, block, tc.publicKey)
+
+			publicKey, err := GetPublicKey(keyPath)
+			require.NoError(t, err)
+			assert.Equal(t, want, publicKey)
+			assert.True(t, strings.HasPrefix(publicKey, tc.wantType))
+		})
+	}
+}
+
+// No t.Parallel() - modifies global readPassphrase
+func TestGetPublicKey_EncryptedPEM(t *testing.T) {
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	//nolint:staticcheck // Legacy PEM encryption is what old ssh-keygen wrote
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivate), []byte("secret"), x509.PEMCipherAES128)
+	require.NoError(t, err)
+	keyPath, want := writeKey(t, block, &rsaPrivate.PublicKey)
+
+	orig := readPassphrase
+	t.Cleanup(func() { readPassphrase = orig })
+
+	tests := map[string]struct {
+		passphrase  []byte
+		promptErr   error
+		wantErr     bool
+		errContains string
+	}{
+		"correct passphrase": {passphrase: []byte("secret")},
+		"wrong passphrase":   {passphrase: []byte("wrong"), wantErr: true, errContains: "failed to get public key"},
+		"no terminal":        {promptErr: errors.New("no terminal"), wantErr: true, errContains: "no terminal"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var prompted string
+			readPassphrase = func(privateKeyPath string) ([]byte, error) {
+				prompted = privateKeyPath
+				return tc.passphrase, tc.promptErr
+			}
+
+			publicKey, err := GetPublicKey(keyPath)
+			assert.Equal(t, keyPath, prompted)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, want, publicKey)
+		})
+	}
+}
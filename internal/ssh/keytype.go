@@ -0,0 +1,164 @@
+package ssh
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// KeyAlgorithm is the algorithm of a generated key.
+type KeyAlgorithm int
+
+const (
+	KeyEd25519 KeyAlgorithm = iota
+	KeyRSA
+	KeyECDSA
+)
+
+func (a KeyAlgorithm) String() string {
+	switch a {
+	case KeyEd25519:
+		return "ed25519"
+	case KeyRSA:
+		return "rsa"
+	case KeyECDSA:
+		return "ecdsa"
+	default:
+		panic(fmt.Sprintf("unexpected KeyAlgorithm: %d", int(a)))
+	}
+}
+
+// Default and allowed sizes per algorithm. EC2 Instance Connect accepts
+// 2048 and 4096-bit RSA keys; the smaller one is much faster to generate.
+const (
+	defaultRSABits   = 2048
+	defaultECDSABits = 256
+)
+
+var (
+	rsaBits   = []int{2048, 4096}
+	ecdsaBits = []int{256, 384, 521}
+)
+
+// KeyType is the algorithm and size of a generated key.
+// The zero value is ed25519.
+type KeyType struct {
+	Algorithm KeyAlgorithm
+	Bits      int // RSA modulus or ECDSA curve size, 0 = default
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for CLI flag parsing.
+// Accepts ed25519, rsa, rsa:<bits>, ecdsa or ecdsa:<bits>.
+func (k *KeyType) UnmarshalText(text []byte) error {
+	name, size, hasSize := strings.Cut(string(text), ":")
+
+	var keyType KeyType
+	var allowed []int
+	switch name {
+	case "ed25519":
+		keyType.Algorithm = KeyEd25519
+	case "rsa":
+		keyType.Algorithm, allowed = KeyRSA, rsaBits
+	case "ecdsa":
+		keyType.Algorithm, allowed = KeyECDSA, ecdsaBits
+	default:
+		return fmt.Errorf("unknown key type: %s", name)
+	}
+
+	if hasSize {
+		bits, err := strconv.Atoi(size)
+		if err != nil || !slices.Contains(allowed, bits) {
+			return fmt.Errorf("invalid %s key size: %s", name, size)
+		}
+		keyType.Bits = bits
+	}
+
+	*k = keyType
+	return nil
+}
+
+// String returns the flag value of the key type, e.g. "rsa:2048".
+func (k KeyType) String() string {
+	if k.Algorithm == KeyEd25519 {
+		return k.Algorithm.String()
+	}
+	return fmt.Sprintf("%s:%d", k.Algorithm, k.bits())
+}
+
+func (k KeyType) bits() int {
+	switch {
+	case k.Bits != 0:
+		return k.Bits
+	case k.Algorithm == KeyRSA:
+		return defaultRSABits
+	case k.Algorithm == KeyECDSA:
+		return defaultECDSABits
+	default:
+		return 0
+	}
+}
+
+// InstanceConnectAccepts reports whether EC2 Instance Connect accepts keys of
+// this type: ed25519 and RSA, but not ECDSA.
+func (k KeyType) InstanceConnectAccepts() bool {
+	return k.Algorithm != KeyECDSA
+}
+
+// sshAlgorithm returns the SSH public key algorithm name, e.g. "ssh-ed25519".
+func (k KeyType) sshAlgorithm() string {
+	switch k.Algorithm {
+	case KeyEd25519:
+		return gossh.KeyAlgoED25519
+	case KeyRSA:
+		return gossh.KeyAlgoRSA
+	case KeyECDSA:
+		return map[int]string{256: gossh.KeyAlgoECDSA256, 384: gossh.KeyAlgoECDSA384, 521: gossh.KeyAlgoECDSA521}[k.bits()]
+	default:
+		panic(fmt.Sprintf("unexpected KeyAlgorithm: %d", int(k.Algorithm)))
+	}
+}
+
+// generate returns a new private key of this type and its SSH public key.
+func (k KeyType) generate() (crypto.PrivateKey, gossh.PublicKey, error) {
+	var privateKey crypto.PrivateKey
+	var publicKey crypto.PublicKey
+
+	switch k.Algorithm {
+	case KeyEd25519:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, publicKey = private, public
+	case KeyRSA:
+		private, err := rsa.GenerateKey(rand.Reader, k.bits())
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, publicKey = private, &private.PublicKey
+	case KeyECDSA:
+		curves := map[int]elliptic.Curve{256: elliptic.P256(), 384: elliptic.P384(), 521: elliptic.P521()}
+		private, err := ecdsa.GenerateKey(curves[k.bits()], rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, publicKey = private, &private.PublicKey
+	default:
+		panic(fmt.Sprintf("unexpected KeyAlgorithm: %d", int(k.Algorithm)))
+	}
+
+	sshPublicKey, err := gossh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, sshPublicKey, nil
+}
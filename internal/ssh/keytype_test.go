@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyType_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input       string
+		want        KeyType
+		wantString  string
+		wantErr     bool
+		errContains string
+	}{
+		"ed25519":        {input: "ed25519", want: KeyType{Algorithm: KeyEd25519}, wantString: "ed25519"},
+		"rsa default":    {input: "rsa", want: KeyType{Algorithm: KeyRSA}, wantString: "rsa:2048"},
+		"rsa 4096":       {input: "rsa:4096", want: KeyType{Algorithm: KeyRSA, Bits: 4096}, wantString: "rsa:4096"},
+		"ecdsa default":  {input: "ecdsa", want: KeyType{Algorithm: KeyECDSA}, wantString: "ecdsa:256"},
+		"ecdsa 384":      {input: "ecdsa:384", want: KeyType{Algorithm: KeyECDSA, Bits: 384}, wantString: "ecdsa:384"},
+		"rsa 3072":       {input: "rsa:3072", wantErr: true, errContains: "invalid rsa key size"},
+		"rsa garbage":    {input: "rsa:big", wantErr: true, errContains: "invalid rsa key size"},
+		"ed25519 sized":  {input: "ed25519:256", wantErr: true, errContains: "invalid ed25519 key size"},
+		"unknown":        {input: "dsa", wantErr: true, errContains: "unknown key type"},
+		"uppercase":      {input: "RSA", wantErr: true, errContains: "unknown key type"},
+		"empty":          {input: "", wantErr: true, errContains: "unknown key type"},
+		"ecdsa bad size": {input: "ecdsa:512", wantErr: true, errContains: "invalid ecdsa key size"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var keyType KeyType
+			err := keyType.UnmarshalText([]byte(tc.input))
+
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, keyType)
+			assert.Equal(t, tc.wantString, keyType.String())
+		})
+	}
+}
+
+func TestKeyType_InstanceConnectAccepts(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, KeyType{Algorithm: KeyEd25519}.InstanceConnectAccepts())
+	assert.True(t, KeyType{Algorithm: KeyRSA, Bits: 4096}.InstanceConnectAccepts())
+	assert.False(t, KeyType{Algorithm: KeyECDSA}.InstanceConnectAccepts())
+}
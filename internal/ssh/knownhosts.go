@@ -0,0 +1,231 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// knownHostsName is the known_hosts file for host keys fetched from AWS,
+// kept apart from ~/.ssh/known_hosts so that only those keys are trusted.
+const knownHostsName = "ec2ssh_known_hosts"
+
+// Markers around the host public keys cloud-init prints to the console.
+const (
+	consoleKeysBegin = "-----BEGIN SSH HOST KEY KEYS-----"
+	consoleKeysEnd   = "-----END SSH HOST KEY KEYS-----"
+)
+
+// KnownHostsPath returns the path of the known_hosts file for host keys
+// fetched from AWS, ~/.ssh/ec2ssh_known_hosts.
+func KnownHostsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", knownHostsName), nil
+}
+
+// ConsoleHostKeys extracts the host public keys that cloud-init prints to the
+// console at boot. When the output spans several boots the last block wins.
+func ConsoleHostKeys(output string) []string {
+	var keys []string
+	inBlock := false
+	for line := range strings.Lines(output) {
+		switch {
+		case strings.Contains(line, consoleKeysBegin):
+			keys, inBlock = nil, true
+		case strings.Contains(line, consoleKeysEnd):
+			inBlock = false
+		case inBlock:
+			if key, ok := parseHostKeyLine(line); ok && !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// ParseHostKeys extracts host public keys from text in .pub file format,
+// one per line, as printed by cat /etc/ssh/ssh_host_*_key.pub.
+func ParseHostKeys(text string) []string {
+	var keys []string
+	for line := range strings.Lines(text) {
+		if key, ok := parseHostKeyLine(line); ok && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// parseHostKeyLine finds a "type base64" public key in line, which may carry
+// a prefix such as "ec2: " or a trailing comment. Returns the key without comment.
+func parseHostKeyLine(line string) (string, bool) {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(fields[i] + " " + fields[i+1]))
+		if err == nil {
+			return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))), true
+		}
+	}
+	return "", false
+}
+
+// KnownHostKeys returns the keys recorded for host in the known_hosts file at
+// path, none if the file does not exist.
+func KnownHostKeys(path, host string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	var keys []string
+	for rest := data; len(bytes.TrimSpace(rest)) > 0; {
+		var hosts []string
+		var key gossh.PublicKey
+		_, hosts, key, _, rest, err = gossh.ParseKnownHosts(rest)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+		}
+		if slices.Contains(hosts, host) {
+			keys = append(keys, strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))))
+		}
+	}
+	return keys, nil
+}
+
+// AddKnownHostKeys appends keys for host to the known_hosts file at path,
+// creating it and its directory if needed.
+func AddKnownHostKeys(path, host string, keys []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create %s: %w", filepath.Dir(path), err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	var lines strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&lines, "%s %s\n", host, key)
+	}
+	if _, err := f.WriteString(lines.String()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// knownHostsLineHosts returns the host patterns of a known_hosts line, nil
+// for blank lines and comments. Hashed hosts are returned as they are.
+func knownHostsLineHosts(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+	// Skip a @cert-authority or @revoked marker
+	if strings.HasPrefix(fields[0], "@") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return strings.Split(fields[0], ",")
+}
+
+// KnownHostsHosts returns the distinct host patterns in the known_hosts file at path.
+func KnownHostsHosts(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	var hosts []string
+	for line := range strings.Lines(string(data)) {
+		for _, host := range knownHostsLineHosts(line) {
+			if !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// PruneKnownHosts removes the entries of the known_hosts file at path whose
+// hosts are all stale, keeping other lines as they are. With dryRun the file
+// is left alone. Returns the number of entries removed and in total.
+func PruneKnownHosts(path string, stale func(host string) bool, dryRun bool) (removed, total int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	var kept strings.Builder
+	for line := range strings.Lines(string(data)) {
+		hosts := knownHostsLineHosts(line)
+		if hosts != nil {
+			total++
+			if !slices.ContainsFunc(hosts, func(host string) bool { return !stale(host) }) {
+				removed++
+				continue
+			}
+		}
+		kept.WriteString(line)
+	}
+
+	if dryRun || removed == 0 {
+		return removed, total, nil
+	}
+	return removed, total, replaceFile(path, []byte(kept.String()))
+}
+
+// replaceFile atomically replaces the file at path with data, keeping its
+// mode. A symlink is followed so that the link itself survives.
+func replaceFile(path string, data []byte) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("unable to rewrite %s: %w", path, err)
+	}
+	path = target
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to rewrite %s: %w", path, err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to rewrite %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to rewrite %s: %w", path, err)
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to rewrite %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to rewrite %s: %w", path, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("unable to rewrite %s: %w", path, err)
+	}
+	return nil
+}
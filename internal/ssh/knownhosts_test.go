@@ -0,0 +1,210 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// newHostKey returns a fresh public key of the given type, as "type base64".
+func newHostKey(t *testing.T, keyType KeyType) string {
+	t.Helper()
+
+	_, publicKey, err := keyType.generate()
+	require.NoError(t, err)
+	return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(publicKey)))
+}
+
+func TestConsoleHostKeys(t *testing.T) {
+	t.Parallel()
+
+	ed25519Key := newHostKey(t, KeyType{})
+	ecdsaKey := newHostKey(t, KeyType{Algorithm: KeyECDSA})
+	oldKey := newHostKey(t, KeyType{})
+
+	tests := map[string]struct {
+		output string
+		want   []string
+	}{
+		"cloud-init block": {
+			output: "Cloud-init v. 22.2.2 running 'modules:final'\n" +
+				"-----BEGIN SSH HOST KEY FINGERPRINTS-----\n" +
+				"256 SHA256:abc root@ip-10-0-0-1 (ED25519)\n" +
+				"-----END SSH HOST KEY FINGERPRINTS-----\n" +
+				"-----BEGIN SSH HOST KEY KEYS-----\n" +
+				ecdsaKey + " root@ip-10-0-0-1\n" +
+				ed25519Key + " root@ip-10-0-0-1\n" +
+				"-----END SSH HOST KEY KEYS-----\n",
+			want: []string{ecdsaKey, ed25519Key},
+		},
+		"amazon linux prefix and carriage returns": {
+			output: "ec2: -----BEGIN SSH HOST KEY KEYS-----\r\n" +
+				"ec2: " + ed25519Key + " \r\n" +
+				"ec2: -----END SSH HOST KEY KEYS-----\r\n",
+			want: []string{ed25519Key},
+		},
+		"last boot wins": {
+			output: "-----BEGIN SSH HOST KEY KEYS-----\n" + oldKey + "\n-----END SSH HOST KEY KEYS-----\n" +
+				"reboot\n" +
+				"-----BEGIN SSH HOST KEY KEYS-----\n" + ed25519Key + "\n-----END SSH HOST KEY KEYS-----\n",
+			want: []string{ed25519Key},
+		},
+		"keys outside block ignored": {
+			output: ed25519Key + "\n",
+			want:   nil,
+		},
+		"no keys": {
+			output: "Booting Linux\nlogin:\n",
+			want:   nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, ConsoleHostKeys(tc.output))
+		})
+	}
+}
+
+func TestParseHostKeys(t *testing.T) {
+	t.Parallel()
+
+	ed25519Key := newHostKey(t, KeyType{})
+	rsaKey := newHostKey(t, KeyType{Algorithm: KeyRSA, Bits: 2048})
+
+	text := rsaKey + " root@web\n" + ed25519Key + " root@web\n" + ed25519Key + "\ncat: /etc/ssh/ssh_host_dsa_key.pub: No such file\n"
+
+	assert.Equal(t, []string{rsaKey, ed25519Key}, ParseHostKeys(text))
+	assert.Empty(t, ParseHostKeys(""))
+}
+
+func TestKnownHostKeys(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".ssh", "ec2ssh_known_hosts")
+	webKey := newHostKey(t, KeyType{})
+	dbKey := newHostKey(t, KeyType{})
+	dbRSAKey := newHostKey(t, KeyType{Algorithm: KeyRSA, Bits: 2048})
+
+	// Missing file has no keys
+	keys, err := KnownHostKeys(path, "i-web")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, AddKnownHostKeys(path, "i-web", []string{webKey}))
+	require.NoError(t, AddKnownHostKeys(path, "i-db", []string{dbKey, dbRSAKey}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "i-web "+webKey+"\ni-db "+dbKey+"\ni-db "+dbRSAKey+"\n", string(content))
+
+	keys, err = KnownHostKeys(path, "i-db")
+	require.NoError(t, err)
+	assert.Equal(t, []string{dbKey, dbRSAKey}, keys)
+
+	keys, err = KnownHostKeys(path, "i-other")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestKnownHostKeys_Invalid(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(path, []byte("i-web ssh-ed25519 not-base64\n"), 0o600))
+
+	_, err := KnownHostKeys(path, "i-web")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to parse")
+}
+
+func TestKnownHostsHosts(t *testing.T) {
+	t.Parallel()
+
+	key := newHostKey(t, KeyType{})
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := "# comment\n\n" +
+		"i-0123456789abcdef0 " + key + "\n" +
+		"web,10.0.0.1 " + key + "\n" +
+		"@cert-authority *.example.com " + key + "\n" +
+		"i-0123456789abcdef0 " + key + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	hosts, err := KnownHostsHosts(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-0123456789abcdef0", "web", "10.0.0.1", "*.example.com"}, hosts)
+
+	_, err = KnownHostsHosts(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestPruneKnownHosts(t *testing.T) {
+	t.Parallel()
+
+	key := newHostKey(t, KeyType{})
+	content := "# work hosts\n" +
+		"i-gone " + key + "\n" +
+		"i-live " + key + "\n" +
+		"i-gone,web " + key + "\n" +
+		"@revoked i-gone " + key + "\n" +
+		"|1|aGFzaA==|c2FsdA== " + key + "\n"
+	kept := "# work hosts\n" +
+		"i-live " + key + "\n" +
+		"i-gone,web " + key + "\n" +
+		"|1|aGFzaA==|c2FsdA== " + key + "\n"
+	stale := func(host string) bool { return host == "i-gone" }
+
+	tests := map[string]struct {
+		dryRun   bool
+		symlink  bool
+		wantFile string
+	}{
+		"prune":   {wantFile: kept},
+		"dry run": {dryRun: true, wantFile: content},
+		"symlink": {symlink: true, wantFile: kept},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			file := filepath.Join(dir, "known_hosts")
+			require.NoError(t, os.WriteFile(file, []byte(content), 0o640))
+			path := file
+			if tc.symlink {
+				path = filepath.Join(dir, "link")
+				require.NoError(t, os.Symlink(file, path))
+			}
+
+			removed, total, err := PruneKnownHosts(path, stale, tc.dryRun)
+			require.NoError(t, err)
+			assert.Equal(t, 2, removed)
+			assert.Equal(t, 5, total)
+
+			data, err := os.ReadFile(file)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFile, string(data))
+
+			info, err := os.Stat(file)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+			if tc.symlink {
+				info, err := os.Lstat(path)
+				require.NoError(t, err)
+				assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+			}
+		})
+	}
+}
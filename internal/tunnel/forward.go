@@ -0,0 +1,125 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Forwarder forwards the connections accepted on a listener, each one
+// through its own TunnelConnection from Dial. Dial gets the accepted
+// connection, for protocols such as SOCKS that choose the destination on it.
+type Forwarder struct {
+	Dial     func(local net.Conn) (TunnelConnection, error)
+	MaxConns int       // Connections forwarded at once, 0 = unlimited; more wait to be accepted
+	Log      io.Writer // Gets a line per connection when it ends, nil = none
+}
+
+// Serve accepts connections on listener until ctx is done. A connection that
+// fails is logged and does not stop Serve. Returns nil once ctx is done.
+func (f *Forwarder) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stop()
+
+	var slots chan struct{}
+	if f.MaxConns > 0 {
+		slots = make(chan struct{}, f.MaxConns)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		// At the cap, stop accepting until a connection ends; new ones queue
+		// in the listen backlog meanwhile
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Go(func() {
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			f.serveConn(ctx, conn)
+		})
+	}
+}
+
+// serveConn forwards one connection and logs how it went.
+func (f *Forwarder) serveConn(ctx context.Context, conn net.Conn) {
+	start := time.Now()
+	sent, received, err := forward(ctx, conn, f.Dial)
+	if f.Log == nil {
+		return
+	}
+
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		_, _ = fmt.Fprintf(f.Log, "%s: failed after %s: %v\n", conn.RemoteAddr(), elapsed, err)
+		return
+	}
+	_, _ = fmt.Fprintf(f.Log, "%s: closed after %s, %d bytes sent, %d received\n", conn.RemoteAddr(), elapsed, sent, received)
+}
+
+// forward pipes local through a connection from dial until either side hangs
+// up or ctx is done, then closes both. Returns the bytes sent to and received
+// from the tunnel.
+func forward(ctx context.Context, local net.Conn, dial func(net.Conn) (TunnelConnection, error)) (sent, received int64, err error) {
+	defer func() { _ = local.Close() }()
+
+	remote, err := dial(local)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			_ = local.Close()
+			remote.Close()
+		})
+	}
+	defer closeBoth()
+	stop := context.AfterFunc(ctx, closeBoth)
+	defer stop()
+
+	// Buffered channel to collect both results without blocking
+	errCh := make(chan error, 2) //nolint:mnd
+	go func() {
+		var err error
+		sent, err = io.Copy(remote.Writer(), local)
+		errCh <- err
+	}()
+	go func() {
+		var err error
+		received, err = io.Copy(local, remote.Reader())
+		errCh <- err
+	}()
+
+	// The first side to finish ends the connection; the other one then fails
+	// on the closed connection, which is not worth reporting
+	err = <-errCh
+	closeBoth()
+	<-errCh
+
+	if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+		return sent, received, nil
+	}
+	return sent, received, err
+}
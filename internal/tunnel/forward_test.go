@@ -0,0 +1,169 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// startEchoServer listens on a loopback port and echoes whatever it receives.
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// startForwarder serves f on a loopback port until the test ends.
+func startForwarder(t *testing.T, f *Forwarder) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- f.Serve(ctx, listener) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	return listener.Addr().String()
+}
+
+// echo sends message over conn and returns the reply of the same length.
+func echo(t *testing.T, conn net.Conn, message string) string {
+	t.Helper()
+
+	_, err := conn.Write([]byte(message))
+	require.NoError(t, err)
+	reply := make([]byte, len(message))
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	return string(reply)
+}
+
+func TestForwarder_Serve(t *testing.T) {
+	t.Parallel()
+
+	echoAddr := startEchoServer(t)
+	log := new(syncBuffer)
+	addr := startForwarder(t, &Forwarder{
+		Dial: func(net.Conn) (TunnelConnection, error) { return DialTCP(echoAddr) },
+		Log:  log,
+	})
+
+	// Two connections at once, each through its own tunnel
+	first, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	second, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", echo(t, first, "hello"))
+	assert.Equal(t, "world!", echo(t, second, "world!"))
+
+	// Hanging up is logged with the bytes each way
+	require.NoError(t, first.Close())
+	require.NoError(t, second.Close())
+	assert.Eventually(t, func() bool {
+		return regexp.MustCompile(`closed after \S+, 5 bytes sent, 5 received`).MatchString(log.String()) &&
+			regexp.MustCompile(`closed after \S+, 6 bytes sent, 6 received`).MatchString(log.String())
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestForwarder_Serve_DialError(t *testing.T) {
+	t.Parallel()
+
+	log := new(syncBuffer)
+	addr := startForwarder(t, &Forwarder{
+		Dial: func(net.Conn) (TunnelConnection, error) { return nil, errors.New("session refused") },
+		Log:  log,
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	// The failed connection is closed, and Serve keeps going
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Eventually(t, func() bool {
+		return regexp.MustCompile(`failed after \S+: session refused`).MatchString(log.String())
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestForwarder_Serve_MaxConns(t *testing.T) {
+	t.Parallel()
+
+	echoAddr := startEchoServer(t)
+	dialed := make(chan struct{}, 2)
+	addr := startForwarder(t, &Forwarder{
+		Dial: func(net.Conn) (TunnelConnection, error) {
+			dialed <- struct{}{}
+			return DialTCP(echoAddr)
+		},
+		MaxConns: 1,
+	})
+
+	first, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	assert.Equal(t, "one", echo(t, first, "one"))
+	<-dialed
+
+	// The second connection waits in the backlog while the first is open
+	second, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer func() { _ = second.Close() }()
+	select {
+	case <-dialed:
+		t.Fatal("second connection forwarded above the cap")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, first.Close())
+	assert.Equal(t, "two", echo(t, second, "two"))
+}
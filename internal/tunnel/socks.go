@@ -0,0 +1,134 @@
+package tunnel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 reply codes, RFC 1928 section 6.
+const (
+	SOCKSSucceeded           byte = 0x00
+	SOCKSGeneralFailure      byte = 0x01
+	SOCKSHostUnreachable     byte = 0x04
+	SOCKSCommandNotSupported byte = 0x07
+	SOCKSAddressNotSupported byte = 0x08
+)
+
+// SOCKS5 protocol constants, RFC 1928 sections 3 and 4.
+const (
+	socksVersion      byte = 0x05
+	socksNoAuth       byte = 0x00
+	socksNoMethods    byte = 0xff
+	socksCmdConnect   byte = 0x01
+	socksAddrIPv4     byte = 0x01
+	socksAddrDomain   byte = 0x03
+	socksAddrIPv6     byte = 0x04
+	socksReservedByte byte = 0x00
+)
+
+// ErrSOCKS is returned for SOCKS clients that speak something ec2ssh does not.
+var ErrSOCKS = errors.New("unsupported SOCKS request")
+
+// ReadSOCKSRequest performs the SOCKS5 handshake on conn without
+// authentication and returns the destination of its CONNECT request, as
+// host:port with the host an IP address or a name. Requests that cannot be
+// served are answered with a failure reply. The handshake must complete
+// within timeout, so silent or slow clients do not hold on to the connection.
+func ReadSOCKSRequest(conn net.Conn, timeout time.Duration) (string, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+	target, err := readSOCKSRequest(conn)
+	if err != nil {
+		return "", err
+	}
+	return target, conn.SetDeadline(time.Time{})
+}
+
+// readSOCKSRequest implements ReadSOCKSRequest without the deadline.
+func readSOCKSRequest(conn io.ReadWriter) (string, error) {
+	// Greeting: VER NMETHODS METHODS...
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("%w: version %d", ErrSOCKS, header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	method := socksNoMethods
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksNoMethods {
+		return "", fmt.Errorf("%w: client requires authentication", ErrSOCKS)
+	}
+
+	// Request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[0] != socksVersion {
+		return "", fmt.Errorf("%w: version %d", ErrSOCKS, request[0])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		size := net.IPv4len
+		if request[3] == socksAddrIPv6 {
+			size = net.IPv6len
+		}
+		addr := make([]byte, size)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	case socksAddrDomain:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return "", err
+		}
+		name := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		_ = WriteSOCKSReply(conn, SOCKSAddressNotSupported)
+		return "", fmt.Errorf("%w: address type %d", ErrSOCKS, request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+
+	if request[1] != socksCmdConnect {
+		_ = WriteSOCKSReply(conn, SOCKSCommandNotSupported)
+		return "", fmt.Errorf("%w: command %d", ErrSOCKS, request[1])
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// WriteSOCKSReply answers a CONNECT request with code. The bound address is
+// left unspecified, as the connection is made from the far end of a tunnel.
+func WriteSOCKSReply(w io.Writer, code byte) error {
+	_, err := w.Write([]byte{socksVersion, code, socksReservedByte, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
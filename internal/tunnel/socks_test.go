@@ -0,0 +1,141 @@
+package tunnel
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSOCKSRequest(t *testing.T) {
+	t.Parallel()
+
+	greeting := []byte{0x05, 0x01, 0x00}
+	accepted := []byte{0x05, 0x00}
+
+	tests := map[string]struct {
+		input     []byte
+		want      string
+		wantReply []byte
+		wantErr   string
+	}{
+		"ipv4": {
+			input:     append(greeting, 0x05, 0x01, 0x00, 0x01, 10, 0, 1, 20, 0x00, 0x50),
+			want:      "10.0.1.20:80",
+			wantReply: accepted,
+		},
+		"ipv6": {
+			input:     append(greeting, 0x05, 0x01, 0x00, 0x04, 0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 0x01, 0xbb),
+			want:      "[fd00::10]:443",
+			wantReply: accepted,
+		},
+		"domain name": {
+			input:     append(greeting, 0x05, 0x01, 0x00, 0x03, 12, 'a', 'p', 'i', '.', 'i', 'n', 't', 'e', 'r', 'n', 'a', 'l', 0x15, 0x38),
+			want:      "api.internal:5432",
+			wantReply: accepted,
+		},
+		"no-auth among several methods": {
+			input:     []byte{0x05, 0x02, 0x02, 0x00, 0x05, 0x01, 0x00, 0x01, 10, 0, 0, 1, 0x00, 0x16},
+			want:      "10.0.0.1:22",
+			wantReply: accepted,
+		},
+		"authentication required": {
+			input:     []byte{0x05, 0x01, 0x02},
+			wantReply: []byte{0x05, 0xff},
+			wantErr:   "client requires authentication",
+		},
+		"socks4 client": {
+			input:   []byte{0x04, 0x01, 0x00, 0x50, 10, 0, 0, 1, 0x00},
+			wantErr: "version 4",
+		},
+		"bind command": {
+			input:     append(greeting, 0x05, 0x02, 0x00, 0x01, 10, 0, 0, 1, 0x00, 0x50),
+			wantReply: append(accepted, 0x05, SOCKSCommandNotSupported, 0x00, 0x01, 0, 0, 0, 0, 0, 0),
+			wantErr:   "command 2",
+		},
+		"unknown address type": {
+			input:     append(greeting, 0x05, 0x01, 0x00, 0x07),
+			wantReply: append(accepted, 0x05, SOCKSAddressNotSupported, 0x00, 0x01, 0, 0, 0, 0, 0, 0),
+			wantErr:   "address type 7",
+		},
+		"truncated request": {
+			input:     append(greeting, 0x05, 0x01, 0x00, 0x01, 10, 0),
+			wantReply: accepted,
+			wantErr:   "unexpected EOF",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reply := new(bytes.Buffer)
+			conn := struct {
+				io.Reader
+				io.Writer
+			}{bytes.NewReader(tc.input), reply}
+
+			got, err := readSOCKSRequest(conn)
+
+			assert.Equal(t, tc.wantReply, reply.Bytes())
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestReadSOCKSRequest_Timeout(t *testing.T) {
+	t.Parallel()
+
+	// A client that connects and sends nothing
+	client, server := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	t.Cleanup(func() { _ = server.Close() })
+
+	_, err := ReadSOCKSRequest(server, 10*time.Millisecond)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestReadSOCKSRequest_ClearsDeadline(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	t.Cleanup(func() { _ = server.Close() })
+
+	go func() {
+		_, _ = client.Write([]byte{0x05, 0x01, 0x00})
+		_, _ = io.ReadFull(client, make([]byte, 2))
+		_, _ = client.Write([]byte{0x05, 0x01, 0x00, 0x01, 10, 0, 1, 20, 0x00, 0x50})
+	}()
+
+	target, err := ReadSOCKSRequest(server, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.20:80", target)
+
+	// Tunneled traffic is not cut off by the handshake deadline
+	time.Sleep(100 * time.Millisecond)
+	go func() { _, _ = client.Write([]byte("ping")) }()
+	got := make([]byte, 4)
+	_, err = io.ReadFull(server, got)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(got))
+}
+
+func TestWriteSOCKSReply(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteSOCKSReply(buf, SOCKSHostUnreachable))
+	assert.Equal(t, []byte{0x05, 0x04, 0x00, 0x01, 0, 0, 0, 0, 0, 0}, buf.Bytes())
+}
@@ -0,0 +1,67 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/mmmorris1975/ssm-session-client/datachannel"
+)
+
+// SSM documents for port forwarding to the instance itself, and through it to
+// another host. The latter needs SSM Agent 3.1.1374.0 or later.
+const (
+	ssmPortForwardingDocument = "AWS-StartPortForwardingSession"
+	ssmRemoteHostDocument     = "AWS-StartPortForwardingSessionToRemoteHost"
+)
+
+// SSMConnection is a TunnelConnection over an SSM Session Manager port
+// forwarding session.
+type SSMConnection struct {
+	channel *datachannel.SsmDataChannel
+}
+
+// DialSSM starts a port forwarding session through the instance to host:port,
+// or to the instance's own port when host is empty.
+func DialSSM(ctx context.Context, cfg aws.Config, instanceID, host string, port int) (TunnelConnection, error) {
+	input := &ssm.StartSessionInput{
+		DocumentName: aws.String(ssmPortForwardingDocument),
+		Target:       aws.String(instanceID),
+		Parameters:   map[string][]string{"portNumber": {strconv.Itoa(port)}},
+	}
+	if host != "" {
+		input.DocumentName = aws.String(ssmRemoteHostDocument)
+		input.Parameters["host"] = []string{host}
+	}
+
+	channel := new(datachannel.SsmDataChannel)
+	if err := channel.Open(cfg, input); err != nil {
+		return nil, fmt.Errorf("unable to start SSM session: %w", err)
+	}
+	if err := channel.WaitForHandshakeComplete(ctx); err != nil {
+		_ = channel.TerminateSession()
+		_ = channel.Close()
+		return nil, fmt.Errorf("unable to start SSM session: %w", err)
+	}
+
+	return &SSMConnection{channel: channel}, nil
+}
+
+// Close terminates the session.
+func (c *SSMConnection) Close() {
+	_ = c.channel.TerminateSession()
+	_ = c.channel.Close()
+}
+
+// Reader returns the data channel for reading.
+func (c *SSMConnection) Reader() io.Reader {
+	return c.channel
+}
+
+// Writer returns the data channel for writing.
+func (c *SSMConnection) Writer() io.Writer {
+	return c.channel
+}
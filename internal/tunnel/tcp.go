@@ -0,0 +1,36 @@
+package tunnel
+
+import (
+	"io"
+	"net"
+)
+
+// TCPConnection is a TunnelConnection over a plain TCP connection.
+type TCPConnection struct {
+	conn net.Conn
+}
+
+// DialTCP connects to a host:port address. It is a Dialer for direct
+// connections, where the "URI" is the address.
+func DialTCP(addr string) (TunnelConnection, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &TCPConnection{conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *TCPConnection) Close() {
+	_ = c.conn.Close()
+}
+
+// Reader returns the connection for reading.
+func (c *TCPConnection) Reader() io.Reader {
+	return c.conn
+}
+
+// Writer returns the connection for writing.
+func (c *TCPConnection) Writer() io.Writer {
+	return c.conn
+}
@@ -0,0 +1,57 @@
+package tunnel
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithIO_DialTCP(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	// Echo one line back upper-cased, then hang up
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte(strings.ToUpper(string(buf))))
+	}()
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	err = RunWithIO(listener.Addr().String(), DialTCP, strings.NewReader("hello"), stdout, stderr)
+
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestDialTCP_Error(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	_, err = DialTCP(addr)
+
+	require.Error(t, err)
+}